@@ -9,9 +9,14 @@ import (
 	"github.com/Masterminds/cookoo"
 	clog "github.com/Masterminds/cookoo/log"
 	"github.com/deis/deis/builder/sshd"
+	docli "github.com/fsouza/go-dockerclient"
 
+	"errors"
 	"log"
 	"os"
+	"sync"
+	"syscall"
+	"time"
 )
 
 // Return codes that will be sent to the shell.
@@ -20,15 +25,19 @@ const (
 	StatusLocalError
 )
 
-// Run starts the Builder service.
+// Builder is a Builder service whose background services have booted.
+type Builder struct {
+	reg    *cookoo.Registry
+	router *cookoo.Router
+	cxt    cookoo.Context
+}
+
+// Boot starts the Builder service's background services.
 //
 // The Builder service is responsible for setting up the local container
-// environment and then listening for new builds. The main listening service
-// is SSH. Builder listens for new Git commands and then sends those on to
-// Git.
-//
-// Run returns on of the Status* status code constants.
-func Run(cmd string) int {
+// environment and then listening for new builds. Boot sets up the environment
+// by running the cmd route, and Serve then listens for new builds.
+func Boot(cmd string) (*Builder, error) {
 	reg, router, ocxt := cookoo.Cookoo()
 	log.SetFlags(0) // Time is captured elsewhere.
 
@@ -42,11 +51,20 @@ func Run(cmd string) int {
 	routes(reg)
 
 	// Bootstrap the background services. If this fails, we stop.
-	if err := router.HandleRequest("boot", cxt, false); err != nil {
+	if err := router.HandleRequest(cmd, cxt, false); err != nil {
 		clog.Errf(cxt, "Fatal errror on boot: %s", err)
-		return StatusLocalError
+		return nil, err
 	}
+	return &Builder{reg: reg, router: router, cxt: cxt}, nil
+}
 
+// Serve listens for new builds.
+//
+// The main listening service is SSH. Builder listens for new Git commands
+// and then sends those on to Git.
+//
+// Serve returns one of the Status* status code constants.
+func (b *Builder) Serve() int {
 	// Set up the SSH service.
 	ip := os.Getenv("SSH_HOST_IP")
 	if ip == "" {
@@ -57,22 +75,97 @@ func Run(cmd string) int {
 		port = "2223"
 	}
 
-	cxt.Put(sshd.Address, ip+":"+port)
+	b.cxt.Put(sshd.Address, ip+":"+port)
 
 	// Supply route names for handling various internal routing. While this
 	// isn't necessary for Cookoo, it makes it easy for us to mock these
 	// routes in tests. c.f. sshd/server.go
-	cxt.Put("route.sshd.pubkeyAuth", "pubkeyAuth")
-	cxt.Put("route.sshd.sshPing", "sshPing")
-	cxt.Put("route.sshd.sshGitReceive", "sshGitReceive")
+	b.cxt.Put("route.sshd.pubkeyAuth", "pubkeyAuth")
+	b.cxt.Put("route.sshd.sshPing", "sshPing")
+	b.cxt.Put("route.sshd.sshGitReceive", "sshGitReceive")
 
 	// Start the SSH service.
 	// TODO: We could refactor Serve to be a command, and then run this as
 	// a route.
-	if err := sshd.Serve(reg, router, cxt); err != nil {
-		clog.Errf(cxt, "SSH server failed: %s", err)
+	if err := sshd.Serve(b.reg, b.router, b.cxt); err != nil {
+		clog.Errf(b.cxt, "SSH server failed: %s", err)
 		return StatusLocalError
 	}
 
 	return StatusOk
 }
+
+// PingDocker checks that the Docker daemon started by Boot answers.
+func (b *Builder) PingDocker() error {
+	cli, ok := b.cxt.Get("docker", nil).(*docli.Client)
+	if !ok {
+		return errors.New("no Docker client")
+	}
+	return cli.Ping()
+}
+
+// Stop shuts the builder down within timeout. It stops accepting SSH
+// connections, waits for the pushes already in progress to finish, and only
+// then stops the Docker daemon started by Boot, giving it what is left of
+// timeout to stop its containers before it is killed.
+func (b *Builder) Stop(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	b.stopSSH(deadline)
+	b.stopDocker(deadline)
+}
+
+// stopSSH closes the SSH listener and waits until deadline for the open
+// connections to finish.
+func (b *Builder) stopSSH(deadline time.Time) {
+	closer, ok := b.cxt.Get("sshd.Closer", nil).(chan interface{})
+	if !ok {
+		return
+	}
+	clog.Info(b.cxt, "Stopping SSH")
+	select {
+	case closer <- true:
+	default:
+	}
+
+	conns, ok := b.cxt.Get("sshd.Connections", nil).(*sync.WaitGroup)
+	if !ok {
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		conns.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(deadline.Sub(time.Now())):
+		clog.Warn(b.cxt, "SSH connections still open, stopping anyway")
+	}
+}
+
+// stopDocker sends the Docker daemon SIGTERM and kills it if it has not
+// exited by deadline.
+func (b *Builder) stopDocker(deadline time.Time) {
+	pid, ok := b.cxt.Get("dockerstart", 0).(int)
+	if !ok || pid <= 0 {
+		return
+	}
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return
+	}
+
+	clog.Infof(b.cxt, "Stopping Docker (pid=%d)", pid)
+	proc.Signal(syscall.SIGTERM)
+	exited := make(chan struct{})
+	go func() {
+		proc.Wait()
+		close(exited)
+	}()
+	select {
+	case <-exited:
+	case <-time.After(deadline.Sub(time.Now())):
+		clog.Warn(b.cxt, "Docker did not stop in time, killing it")
+		proc.Kill()
+	}
+}
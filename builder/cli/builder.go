@@ -1,13 +1,139 @@
 package main
 
 import (
-	"os"
-	"runtime"
+	"fmt"
+	"strconv"
 
 	"github.com/deis/deis/builder"
+	"github.com/deis/deis/pkg/boot"
+	"github.com/deis/deis/pkg/boot/types"
+	"github.com/deis/deis/pkg/health"
+	logger "github.com/deis/deis/pkg/log"
+	oswrapper "github.com/deis/deis/pkg/os"
 )
 
+var (
+	etcdPath = oswrapper.Getopt("ETCD_PATH", "/deis/builder")
+	log      = logger.New()
+	service  *builder.Builder
+)
+
+func init() {
+	boot.RegisterComponent(new(BuilderBoot), "boot")
+}
+
 func main() {
-	runtime.GOMAXPROCS(runtime.NumCPU())
-	os.Exit(builder.Run("boot"))
+	// without an EXTERNAL_PORT the builder is not published
+	port, _ := strconv.Atoi(oswrapper.Getopt("EXTERNAL_PORT", "0"))
+	boot.Start(etcdPath, port)
+}
+
+// BuilderBoot struct to boot the builder.
+type BuilderBoot struct{}
+
+// MkdirsEtcd creates a directory in etcd.
+func (bb *BuilderBoot) MkdirsEtcd() []string {
+	return []string{etcdPath}
+}
+
+// EtcdDefaults returns default values for etcd.
+func (bb *BuilderBoot) EtcdDefaults() map[string]string {
+	return map[string]string{}
+}
+
+// PreBoot starts Docker and the builder's other background services, then
+// serves SSH in the background. If either fails, the builder is shut down
+// through boot, so that it is unpublished and Docker is stopped.
+func (bb *BuilderBoot) PreBoot(currentBoot *types.CurrentBoot) {
+	var err error
+	service, err = builder.Boot("boot")
+	if err != nil {
+		boot.Fail(fmt.Errorf("booting the builder: %v", err))
+		return
+	}
+	go func() {
+		// Serve only returns StatusOk once PreShutdown has closed it.
+		if status := service.Serve(); status != builder.StatusOk {
+			boot.Fail(fmt.Errorf("SSH server exited with status %d", status))
+		}
+	}()
+}
+
+// PreBootScripts runs preboot scripts.
+func (bb *BuilderBoot) PreBootScripts(currentBoot *types.CurrentBoot) []*types.Script {
+	return []*types.Script{}
+}
+
+// UseConfd returns false, since the boot route runs confd itself.
+func (bb *BuilderBoot) UseConfd() (bool, bool) {
+	return false, false
+}
+
+// BootDaemons returns no daemons, since SSH is served by this process.
+func (bb *BuilderBoot) BootDaemons(currentBoot *types.CurrentBoot) []*types.ServiceDaemon {
+	return []*types.ServiceDaemon{}
+}
+
+// WaitForPorts returns the port SSH is served on.
+func (bb *BuilderBoot) WaitForPorts() []int {
+	port, err := strconv.Atoi(oswrapper.Getopt("SSH_HOST_PORT", "2223"))
+	if err != nil {
+		return []int{}
+	}
+	return []int{port}
+}
+
+// HealthChecks checks that Docker answers and that SSH accepts connections.
+func (bb *BuilderBoot) HealthChecks(currentBoot *types.CurrentBoot) []*types.HealthCheck {
+	checks := []*types.HealthCheck{
+		&types.HealthCheck{Name: "docker", Check: func() error {
+			if service == nil {
+				return fmt.Errorf("builder is not running")
+			}
+			return service.PingDocker()
+		}},
+	}
+	for _, port := range bb.WaitForPorts() {
+		checks = append(checks, &types.HealthCheck{
+			Name:  "ssh",
+			Check: health.TCPCheck("127.0.0.1:" + strconv.Itoa(port)),
+		})
+	}
+	return checks
+}
+
+// PublishKeys publishes the builder's host and port, if it has an external port.
+func (bb *BuilderBoot) PublishKeys(currentBoot *types.CurrentBoot) map[string]string {
+	if currentBoot.Port == 0 {
+		return map[string]string{}
+	}
+	return currentBoot.HostPortKeys(currentBoot.EtcdPath)
+}
+
+// PostBootScripts returns type script.
+func (bb *BuilderBoot) PostBootScripts(currentBoot *types.CurrentBoot) []*types.Script {
+	return []*types.Script{}
+}
+
+// PostBoot logs that the builder is running.
+func (bb *BuilderBoot) PostBoot(currentBoot *types.CurrentBoot) {
+	log.Info("deis-builder running...")
+}
+
+// ScheduleTasks returns a cron job.
+func (bb *BuilderBoot) ScheduleTasks(currentBoot *types.CurrentBoot) []*types.Cron {
+	return []*types.Cron{}
+}
+
+// PreShutdown stops accepting pushes, lets the ones in progress finish and
+// then stops Docker, all within the grace period.
+func (bb *BuilderBoot) PreShutdown(currentBoot *types.CurrentBoot) {
+	if service != nil {
+		service.Stop(currentBoot.GracePeriod)
+	}
+}
+
+// PreShutdownScripts returns type script.
+func (bb *BuilderBoot) PreShutdownScripts(currentBoot *types.CurrentBoot) []*types.Script {
+	return []*types.Script{}
 }
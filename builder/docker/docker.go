@@ -136,6 +136,7 @@ type BuildImg struct {
 // ParallelBuild runs multiple docker builds at the same time.
 //
 // Params:
+// 	-client (*docker.Client): Docker client.
 //	-images ([]BuildImg): Images to build
 // 	-alwaysFetch (bool): Default false. If set to true, this will always fetch
 // 		the Docker image even if it already exists in the registry.
@@ -149,6 +150,10 @@ type BuildImg struct {
 // This puts 'ParallelBuild.failN" (int) into the context to indicate how many failures
 // occurred during fetches.
 func ParallelBuild(c cookoo.Context, p *cookoo.Params) (interface{}, cookoo.Interrupt) {
+	if ok, missing := p.RequiresValue("client"); !ok {
+		return nil, &cookoo.FatalError{"Missing required fields: " + strings.Join(missing, ", ")}
+	}
+	client := p.Get("client", nil).(*docli.Client)
 	images := p.Get("images", []BuildImg{}).([]BuildImg)
 
 	var wg sync.WaitGroup
@@ -160,7 +165,7 @@ func ParallelBuild(c cookoo.Context, p *cookoo.Params) (interface{}, cookoo.Inte
 		wg.Add(1)
 		safely.GoDo(c, func() {
 			log.Infof(c, "Starting build for %s (tag: %s)", img.Path, img.Tag)
-			if _, err := buildImg(c, img.Path, img.Tag); err != nil {
+			if _, err := buildImg(c, client, img.Path, img.Tag); err != nil {
 				log.Errf(c, "Failed to build docker image: %s", err)
 				m.Lock()
 				fails++
@@ -225,53 +230,121 @@ func Wait(c cookoo.Context, p *cookoo.Params) (interface{}, cookoo.Interrupt) {
 // 	docker build -t TAG PATH
 //
 // Params:
+// 	- client (*docker.Client): Docker client.
 // 	- path (string): The path to the image. REQUIRED
 // 	- tag (string): The tag to build.
 func BuildImage(c cookoo.Context, p *cookoo.Params) (interface{}, cookoo.Interrupt) {
+	if ok, missing := p.RequiresValue("client", "path"); !ok {
+		return nil, &cookoo.FatalError{"Missing required fields: " + strings.Join(missing, ", ")}
+	}
+	client := p.Get("client", nil).(*docli.Client)
 	path := p.Get("path", "").(string)
 	tag := p.Get("tag", "").(string)
 
 	log.Infof(c, "Building docker image %s (tag: %s)", path, tag)
 
-	return buildImg(c, path, tag)
+	return buildImg(c, client, path, tag)
 }
 
-func buildImg(c cookoo.Context, path, tag string) ([]byte, error) {
-	dargs := []string{"build"}
-	if len(tag) > 0 {
-		dargs = append(dargs, "-t", tag)
+// buildImg sends the build context at path to Docker and builds an image.
+func buildImg(c cookoo.Context, client *docli.Client, path, tag string) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tarDir(path, &buf); err != nil {
+		return nil, fmt.Errorf("Build context %s: %s", path, err)
 	}
 
-	dargs = append(dargs, path)
+	var out bytes.Buffer
+	options := docli.BuildImageOptions{
+		Name:           tag,
+		InputStream:    &buf,
+		OutputStream:   &out,
+		RmTmpContainer: true,
+	}
+	err := client.BuildImage(options)
+	if out.Len() > 0 {
+		log.Infof(c, "Docker: %s", out.Bytes())
+	}
+	return out.Bytes(), err
+}
+
+// tarDir writes the contents of dir to w as a tar archive.
+//
+// Paths in the archive are relative to dir, as Docker expects of a build
+// context.
+func tarDir(dir string, w io.Writer) error {
+	tw := tar.NewWriter(w)
+	err := filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(dir, file)
+		if err != nil || name == "." {
+			return err
+		}
+
+		var link string
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(file); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(name)
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
 
-	out, err := exec.Command("docker", dargs...).CombinedOutput()
-	if len(out) > 0 {
-		log.Infof(c, "Docker: %s", out)
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
 	}
-	return out, err
+	return tw.Close()
 }
 
 // Push pushes an image to the registry.
 //
-// This finds the appropriate registry by looking it up in etcd.
+// This finds the appropriate registry by looking it up in etcd. If
+// $ETCD_PATH/registryUsername is set, it and $ETCD_PATH/registryPassword are
+// used to authenticate with the registry.
 //
 // Params:
 // - client (etcd.Getter): Client to do etcd lookups.
+// - dockerClient (*docker.Client): Docker client.
 // - tag (string): Tag to push.
+// - basepath (string): Base path in etcd (ETCD_PATH).
+// - retries (int): Number of times to retry a failed push. Default 3.
 //
 // Returns:
+// - string: The digest of the pushed image, if the registry reports one.
 //
 func Push(c cookoo.Context, p *cookoo.Params) (interface{}, cookoo.Interrupt) {
-	// docker tag deis/slugrunner:lastest HOST:PORT/deis/slugrunner:latest
-	// docker push HOST:PORT/deis/slugrunner:latest
 	client := p.Get("client", nil).(etcd.Getter)
+	dclient := p.Get("dockerClient", nil).(*docli.Client)
+	basepath := p.Get("basepath", "/deis/builder").(string)
+	retries := p.Get("retries", 3).(int)
 
 	host, err := client.Get("/deis/registry/host", false, false)
 	if err != nil || host.Node == nil {
 		return nil, err
 	}
 	port, err := client.Get("/deis/registry/port", false, false)
-	if err != nil || host.Node == nil {
+	if err != nil || port.Node == nil {
 		return nil, err
 	}
 
@@ -281,56 +354,44 @@ func Push(c cookoo.Context, p *cookoo.Params) (interface{}, cookoo.Interrupt) {
 	log.Infof(c, "Pushing %s to %s. This may take some time.", tag, registry)
 	rem := path.Join(registry, tag)
 
-	out, err := exec.Command("docker", "tag", "-f", tag, rem).CombinedOutput()
-	if err != nil {
-		log.Warnf(c, "Failed to tag %s on host %s: %s (%s)", tag, rem, err, out)
+	name, version := ParseTag(rem)
+	if err := dclient.TagImage(tag, docli.TagImageOptions{Repo: name, Tag: version, Force: true}); err != nil {
+		log.Warnf(c, "Failed to tag %s on host %s: %s", tag, rem, err)
 	}
-	out, err = exec.Command("docker", "-D", "push", rem).CombinedOutput()
 
-	if err != nil {
-		log.Warnf(c, "Failed to push %s to host %s: %s (%s)", tag, rem, err, out)
-		return nil, err
+	opts := PushOptions{
+		Name:    name,
+		Tag:     version,
+		Auth:    RegistryAuth(client, basepath),
+		Retries: retries,
 	}
-	log.Infof(c, "Finished pushing %s to %s.", tag, registry)
-	return nil, nil
-}
-
-/*
- * This function only works for very simple docker files that do not have
- * local resources.
- * Need to suck in all of the files in ADD directives, too.
- */
-// build takes a Dockerfile and builds an image.
-func build(c cookoo.Context, path, tag string, client *docli.Client) error {
-	dfile := filepath.Join(path, "Dockerfile")
-
-	// Stat the file
-	info, err := os.Stat(dfile)
-	if err != nil {
-		return fmt.Errorf("Dockerfile stat: %s", err)
+	var out bytes.Buffer
+	digest, err := PushImage(dclient, opts, &out)
+	if out.Len() > 0 {
+		log.Infof(c, "Docker: %s", out.Bytes())
 	}
-	file, err := os.Open(dfile)
 	if err != nil {
-		return fmt.Errorf("Dockerfile open: %s", err)
-	}
-	defer file.Close()
-
-	var buf bytes.Buffer
-	tw := tar.NewWriter(&buf)
-	tw.WriteHeader(&tar.Header{
-		Name:    "Dockerfile",
-		Size:    info.Size(),
-		ModTime: info.ModTime(),
-	})
-	io.Copy(tw, file)
-	if err := tw.Close(); err != nil {
-		return fmt.Errorf("Dockerfile tar: %s", err)
+		log.Warnf(c, "Failed to push %s to host %s: %s", tag, rem, err)
+		return nil, err
 	}
+	log.Infof(c, "Finished pushing %s to %s (%s).", tag, registry, digest)
+	return digest, nil
+}
 
-	options := docli.BuildImageOptions{
-		Name:         tag,
-		InputStream:  &buf,
-		OutputStream: os.Stdout,
+// RegistryAuth reads registry credentials from etcd.
+//
+// If no credentials are stored, an empty configuration is returned, which
+// Docker treats as an anonymous push.
+func RegistryAuth(client etcd.Getter, basepath string) docli.AuthConfiguration {
+	auth := docli.AuthConfiguration{}
+	for key, val := range map[string]*string{
+		"registryUsername": &auth.Username,
+		"registryPassword": &auth.Password,
+		"registryEmail":    &auth.Email,
+	} {
+		if res, err := client.Get(basepath+"/"+key, false, false); err == nil && res.Node != nil {
+			*val = res.Node.Value
+		}
 	}
-	return client.BuildImage(options)
+	return auth
 }
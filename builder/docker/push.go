@@ -0,0 +1,170 @@
+package docker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"time"
+
+	docli "github.com/fsouza/go-dockerclient"
+)
+
+// digestRe matches the digest that Docker reports at the end of a push.
+var digestRe = regexp.MustCompile(`digest: (sha256:[0-9a-f]{64})`)
+
+// PushOptions describes an image push.
+type PushOptions struct {
+	// Name is the repository, including the registry host.
+	Name string
+	// Tag is the tag to push. If empty, all tags are pushed.
+	Tag string
+	// Auth is used to authenticate with the registry.
+	Auth docli.AuthConfiguration
+	// Retries is the number of times to retry a push that failed for
+	// transient reasons.
+	Retries int
+	// RetryDelay is the delay before the first retry. It doubles with each
+	// attempt. Defaults to one second.
+	RetryDelay time.Duration
+}
+
+// progressMessage is a single message from Docker's JSON progress stream.
+type progressMessage struct {
+	ID       string `json:"id"`
+	Status   string `json:"status"`
+	Progress string `json:"progress"`
+	Error    string `json:"error"`
+	// Aux carries the pushed tag's digest on Docker 1.10 and later.
+	Aux *struct {
+		Digest string `json:"Digest"`
+	} `json:"aux"`
+}
+
+// PushImage pushes an image to a registry using the Docker API.
+//
+// Progress is written to out as it is received. Pushes that fail for reasons
+// other than authentication are retried up to opts.Retries times.
+//
+// The digest reported by the registry is returned. Registries that do not
+// support digests report none, in which case the empty string is returned.
+func PushImage(client *docli.Client, opts PushOptions, out io.Writer) (string, error) {
+	delay := opts.RetryDelay
+	if delay == 0 {
+		delay = time.Second
+	}
+
+	for attempt := 0; ; attempt++ {
+		digest, err := pushOnce(client, opts, out)
+		if err == nil {
+			return digest, nil
+		}
+		if attempt >= opts.Retries || !isTransient(err) {
+			return "", err
+		}
+		fmt.Fprintf(out, "Push failed: %s. Retrying in %s.\n", err, delay)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// pushOnce makes a single push attempt, relaying its progress to out.
+func pushOnce(client *docli.Client, opts PushOptions, out io.Writer) (string, error) {
+	pr, pw := io.Pipe()
+	type result struct {
+		digest string
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		digest, err := readProgress(pr, out)
+		// Drain anything left so that the push never blocks on the pipe.
+		io.Copy(ioutil.Discard, pr)
+		done <- result{digest, err}
+	}()
+
+	pushOpts := docli.PushImageOptions{
+		Name:          opts.Name,
+		Tag:           opts.Tag,
+		OutputStream:  pw,
+		RawJSONStream: true,
+	}
+	err := client.PushImage(pushOpts, opts.Auth)
+	pw.Close()
+
+	res := <-done
+	if err != nil {
+		return "", err
+	}
+	return res.digest, res.err
+}
+
+// readProgress relays a JSON progress stream to out as plain text.
+//
+// Per-layer progress bars are dropped so that remote clients are not flooded
+// with updates. It returns the digest reported in the stream, and the first
+// error reported in the stream.
+func readProgress(r io.Reader, out io.Writer) (string, error) {
+	var digest string
+	var streamErr error
+
+	dec := json.NewDecoder(r)
+	for {
+		var msg progressMessage
+		if err := dec.Decode(&msg); err == io.EOF {
+			return digest, streamErr
+		} else if err != nil {
+			return digest, err
+		}
+
+		if msg.Error != "" {
+			if streamErr == nil {
+				streamErr = errors.New(msg.Error)
+			}
+			continue
+		}
+		if msg.Aux != nil {
+			if msg.Aux.Digest != "" {
+				digest = msg.Aux.Digest
+			}
+			continue
+		}
+		if m := digestRe.FindStringSubmatch(msg.Status); m != nil {
+			digest = m[1]
+		}
+		if msg.Progress != "" {
+			continue
+		}
+		if msg.ID != "" {
+			fmt.Fprintf(out, "%s: %s\n", msg.ID, msg.Status)
+		} else {
+			fmt.Fprintln(out, msg.Status)
+		}
+	}
+}
+
+// isTransient returns false for errors that retrying will not fix.
+func isTransient(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, permanent := range []string{"unauthorized", "authentication required", "no such image", "does not exist"} {
+		if strings.Contains(msg, permanent) {
+			return false
+		}
+	}
+	return err != docli.ErrNoSuchImage
+}
+
+// ParseTag splits an image reference into its repository and tag.
+//
+// The registry port is not mistaken for a tag, so
+// "example.com:5000/app:v1" yields "example.com:5000/app" and "v1".
+func ParseTag(image string) (string, string) {
+	i := strings.LastIndex(image, ":")
+	if i < 0 || strings.Contains(image[i+1:], "/") {
+		return image, ""
+	}
+	return image[:i], image[i+1:]
+}
@@ -0,0 +1,80 @@
+package docker
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const pushStream = `{"status":"The push refers to a repository [10.0.0.1:5000/app] (len: 1)"}
+{"status":"Pushing","progressDetail":{"current":512,"total":1024},"progress":"[=====>     ]","id":"abc123"}
+{"status":"Image successfully pushed","id":"abc123"}
+{"status":"git-1234: digest: sha256:8a1f6d4e3c2b9a0f7e6d5c4b3a29180f7e6d5c4b3a29180f7e6d5c4b3a291807 size: 2744"}
+`
+
+func TestReadProgress(t *testing.T) {
+	var out bytes.Buffer
+	digest, err := readProgress(strings.NewReader(pushStream), &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "sha256:8a1f6d4e3c2b9a0f7e6d5c4b3a29180f7e6d5c4b3a29180f7e6d5c4b3a291807"
+	if digest != expected {
+		t.Errorf("Expected digest %s, got %s", expected, digest)
+	}
+	if strings.Contains(out.String(), "=====>") {
+		t.Errorf("Expected progress bars to be dropped, got %s", out.String())
+	}
+	if !strings.Contains(out.String(), "abc123: Image successfully pushed") {
+		t.Errorf("Expected layer status in output, got %s", out.String())
+	}
+}
+
+func TestReadProgressAuxDigest(t *testing.T) {
+	stream := `{"status":"Image successfully pushed","id":"abc123"}
+{"status":"git-1234: digest: sha256:8a1f6d4e3c2b9a0f7e6d5c4b3a29180f7e6d5c4b3a29180f7e6d5c4b3a291807 size: 2744"}
+{"progressDetail":{},"aux":{"Tag":"git-1234","Digest":"sha256:8a1f6d4e3c2b9a0f7e6d5c4b3a29180f7e6d5c4b3a29180f7e6d5c4b3a291807","Size":2744}}
+`
+	var out bytes.Buffer
+	digest, err := readProgress(strings.NewReader(stream), &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "sha256:8a1f6d4e3c2b9a0f7e6d5c4b3a29180f7e6d5c4b3a29180f7e6d5c4b3a291807"
+	if digest != expected {
+		t.Errorf("Expected digest %s, got %s", expected, digest)
+	}
+	if strings.HasSuffix(out.String(), "\n\n") {
+		t.Errorf("Expected the aux message not to be printed, got %q", out.String())
+	}
+}
+
+func TestReadProgressError(t *testing.T) {
+	stream := `{"status":"Pushing"}
+{"errorDetail":{"message":"unauthorized"},"error":"unauthorized"}
+`
+	var out bytes.Buffer
+	_, err := readProgress(strings.NewReader(stream), &out)
+	if err == nil {
+		t.Fatal("Expected an error from the stream.")
+	}
+	if isTransient(err) {
+		t.Errorf("Expected %s to be permanent.", err)
+	}
+}
+
+func TestParseTag(t *testing.T) {
+	tests := []struct{ image, name, tag string }{
+		{"10.0.0.1:5000/app:git-1234", "10.0.0.1:5000/app", "git-1234"},
+		{"10.0.0.1:5000/deis/slugrunner", "10.0.0.1:5000/deis/slugrunner", ""},
+		{"deis/slugrunner:latest", "deis/slugrunner", "latest"},
+	}
+	for _, tt := range tests {
+		name, tag := ParseTag(tt.image)
+		if name != tt.name || tag != tt.tag {
+			t.Errorf("Expected %s to parse to %s and %s, got %s and %s", tt.image, tt.name, tt.tag, name, tag)
+		}
+	}
+}
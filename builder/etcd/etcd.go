@@ -7,7 +7,6 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
-	"os"
 	"os/exec"
 	"strings"
 	"time"
@@ -172,14 +171,22 @@ func Set(c cookoo.Context, p *cookoo.Params) (interface{}, cookoo.Interrupt) {
 // Params:
 // 	- client (EtcdGetter)
 // 	- fingerprint (string): The fingerprint of the SSH key.
+// 	- cache (*PermCache): Optional cache of previous lookups.
 //
 // Returns:
 // - username (string)
 func FindSSHUser(c cookoo.Context, p *cookoo.Params) (interface{}, cookoo.Interrupt) {
 	client := p.Get("client", nil).(Getter)
 	fingerprint := p.Get("fingerprint", nil).(string)
+	cache, _ := p.Get("cache", nil).(*PermCache)
 
-	res, err := client.Get("/deis/builder/users", false, true)
+	if cache != nil {
+		if username, ok := cache.User(fingerprint); ok {
+			return username, nil
+		}
+	}
+
+	res, err := client.Get(usersPath, false, true)
 	if err != nil {
 		log.Warnf(c, "Error querying etcd: %s", err)
 		return "", err
@@ -194,6 +201,9 @@ func FindSSHUser(c cookoo.Context, p *cookoo.Params) (interface{}, cookoo.Interr
 				parts := strings.Split(user.Key, "/")
 				username := parts[len(parts)-1]
 				log.Infof(c, "Found user %s for fingerprint %s", username, fingerprint)
+				if cache != nil {
+					cache.SetUser(fingerprint, username)
+				}
 				return username, nil
 			}
 		}
@@ -312,70 +322,6 @@ func genSSHKeys(c cookoo.Context) error {
 	return nil
 }
 
-// UpdateHostPort intermittently notifies etcd of the builder's address.
-//
-// If `port` is specified, this will notify etcd at 10 second intervals that
-// the builder is listening at $HOST:$PORT, setting the TTL to 20 seconds.
-//
-// This will notify etcd as long as the local sshd is running.
-//
-// Params:
-// 	- base (string): The base path to write the data: $base/host and $base/port.
-// 	- host (string): The hostname
-// 	- port (string): The port
-// 	- client (Setter): The client to use to write the data to etcd.
-// 	- sshPid (int): The PID for SSHD. If SSHD dies, this stops notifying.
-func UpdateHostPort(c cookoo.Context, p *cookoo.Params) (interface{}, cookoo.Interrupt) {
-	base := p.Get("base", "").(string)
-	host := p.Get("host", "").(string)
-	port := p.Get("port", "").(string)
-	client := p.Get("client", nil).(Setter)
-	sshd := p.Get("sshdPid", 0).(int)
-
-	// If no port is specified, we don't do anything.
-	if len(port) == 0 {
-		log.Infof(c, "No external port provided. Not publishing details.")
-		return false, nil
-	}
-
-	var ttl uint64 = 20
-
-	if err := setHostPort(client, base, host, port, ttl); err != nil {
-		log.Errf(c, "Etcd error setting host/port: %s", err)
-		return false, err
-	}
-
-	// Update etcd every ten seconds with this builder's host/port.
-	safely.GoDo(c, func() {
-		ticker := time.NewTicker(10 * time.Second)
-		for range ticker.C {
-			//log.Infof(c, "Setting SSHD host/port")
-			if _, err := os.FindProcess(sshd); err != nil {
-				log.Errf(c, "Lost SSHd process: %s", err)
-				break
-			} else {
-				if err := setHostPort(client, base, host, port, ttl); err != nil {
-					log.Errf(c, "Etcd error setting host/port: %s", err)
-					break
-				}
-			}
-		}
-		ticker.Stop()
-	})
-
-	return true, nil
-}
-
-func setHostPort(client Setter, base, host, port string, ttl uint64) error {
-	if _, err := client.Set(base+"/host", host, ttl); err != nil {
-		return err
-	}
-	if _, err := client.Set(base+"/port", port, ttl); err != nil {
-		return err
-	}
-	return nil
-}
-
 // MakeDir makes a directory in Etcd.
 //
 // Params:
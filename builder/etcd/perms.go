@@ -0,0 +1,210 @@
+package etcd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Masterminds/cookoo"
+	"github.com/Masterminds/cookoo/log"
+	"github.com/Masterminds/cookoo/safely"
+)
+
+// The controller publishes app collaborators (including the owner) to
+// $appsPath/$APP/$USER and platform administrators to $adminsPath/$USER.
+const (
+	usersPath  = "/deis/builder/users"
+	appsPath   = "/deis/builder/apps"
+	adminsPath = "/deis/builder/admins"
+)
+
+// ErrPushDenied indicates that a user is not permitted to push to an app.
+var ErrPushDenied = errors.New("push denied")
+
+// PermCache caches SSH user lookups and push authorization decisions.
+//
+// Entries never expire on their own. Instead, WatchPerms flushes the cache
+// whenever the users, apps, or admins trees change in etcd.
+type PermCache struct {
+	mx    sync.RWMutex
+	users map[string]string
+	perms map[string]bool
+}
+
+// NewPermCache creates an empty PermCache.
+func NewPermCache() *PermCache {
+	return &PermCache{
+		users: map[string]string{},
+		perms: map[string]bool{},
+	}
+}
+
+// User returns the cached username for a key fingerprint.
+func (p *PermCache) User(fingerprint string) (string, bool) {
+	p.mx.RLock()
+	defer p.mx.RUnlock()
+	u, ok := p.users[fingerprint]
+	return u, ok
+}
+
+// SetUser caches the username for a key fingerprint.
+func (p *PermCache) SetUser(fingerprint, username string) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	p.users[fingerprint] = username
+}
+
+// Allowed returns the cached push decision for a user and app.
+func (p *PermCache) Allowed(username, app string) (allowed, ok bool) {
+	p.mx.RLock()
+	defer p.mx.RUnlock()
+	allowed, ok = p.perms[app+"/"+username]
+	return
+}
+
+// SetAllowed caches the push decision for a user and app.
+func (p *PermCache) SetAllowed(username, app string, allowed bool) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	p.perms[app+"/"+username] = allowed
+}
+
+// Flush discards all cached entries.
+func (p *PermCache) Flush() {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	p.users = map[string]string{}
+	p.perms = map[string]bool{}
+}
+
+// WatchPerms creates a PermCache and keeps it in sync with etcd.
+//
+// The returned cache is flushed each time a key under the builder's users,
+// apps, or admins directories changes. Each watch resumes after the last
+// change it saw, so changes made while the cache is being flushed aren't
+// missed. The watchers run on their own goroutines.
+//
+// Params:
+// 	- client (Watcher): An Etcd client.
+//
+// Returns:
+// 	- *PermCache
+func WatchPerms(c cookoo.Context, p *cookoo.Params) (interface{}, cookoo.Interrupt) {
+	cli, ok := p.Has("client")
+	if !ok {
+		return nil, errors.New("No etcd client found.")
+	}
+	client := cli.(Watcher)
+	cache := NewPermCache()
+
+	for _, path := range []string{usersPath, appsPath, adminsPath} {
+		path := path
+		safely.GoDo(c, func() {
+			var index uint64
+			for {
+				res, err := client.Watch(path, index, true, nil, nil)
+				if err != nil {
+					// Changes may have been missed, so forget everything
+					// and watch from the current index.
+					log.Errf(c, "Etcd Watch of %s failed: %s", path, err)
+					index = 0
+					cache.Flush()
+					time.Sleep(50 * time.Millisecond)
+					continue
+				}
+				if res.Node != nil {
+					index = res.Node.ModifiedIndex + 1
+				}
+				log.Infof(c, "Permissions changed under %s. Flushing cache.", path)
+				cache.Flush()
+			}
+		})
+	}
+
+	return cache, nil
+}
+
+// CanPush checks whether a Deis user may push to an app.
+//
+// A user may push if they are a platform administrator, or if the controller
+// has published them as the owner or a collaborator of the app. If the
+// controller has not published any app permissions at all, the decision is
+// deferred to the controller's push hook.
+//
+// Params:
+// 	- client (Getter)
+// 	- user (string): The Deis username.
+// 	- repoName (string): The repository name, in the form '/APP.git'.
+// 	- cache (*PermCache): Optional cache of previous decisions.
+//
+// Returns:
+// 	- bool true if the push is allowed. If it is not allowed, an error
+// 	  describing why is returned.
+func CanPush(c cookoo.Context, p *cookoo.Params) (interface{}, cookoo.Interrupt) {
+	client := p.Get("client", nil).(Getter)
+	user := p.Get("user", "").(string)
+	app := appName(p.Get("repoName", "").(string))
+	cache, _ := p.Get("cache", nil).(*PermCache)
+
+	if len(app) == 0 {
+		return false, errors.New("No app given.")
+	}
+	if len(user) == 0 {
+		return false, fmt.Errorf("%s: no Deis user is associated with this key", ErrPushDenied)
+	}
+
+	if cache != nil {
+		if allowed, ok := cache.Allowed(user, app); ok {
+			return pushResult(user, app, allowed)
+		}
+	}
+
+	allowed, err := canPush(c, client, user, app)
+	if err != nil {
+		return false, err
+	}
+	if cache != nil {
+		cache.SetAllowed(user, app, allowed)
+	}
+	return pushResult(user, app, allowed)
+}
+
+// canPush looks up push permissions for a user and app in etcd.
+func canPush(c cookoo.Context, client Getter, user, app string) (bool, error) {
+	if !exists(client, "/deis/services/"+app) {
+		return false, fmt.Errorf("%s: app %s does not exist", ErrPushDenied, app)
+	}
+	if exists(client, adminsPath+"/"+user) {
+		log.Infof(c, "User %s is an administrator.", user)
+		return true, nil
+	}
+	if exists(client, appsPath+"/"+app+"/"+user) {
+		return true, nil
+	}
+	if !exists(client, appsPath) {
+		log.Warnf(c, "No app permissions found in etcd. Deferring to the controller.")
+		return true, nil
+	}
+	return false, nil
+}
+
+func pushResult(user, app string, allowed bool) (bool, error) {
+	if !allowed {
+		return false, fmt.Errorf("%s: user %s does not have access to app %s", ErrPushDenied, user, app)
+	}
+	return true, nil
+}
+
+// exists returns true if the given key or directory is present in etcd.
+func exists(client Getter, key string) bool {
+	res, err := client.Get(key, false, false)
+	return err == nil && res.Node != nil
+}
+
+// appName converts a repository name like '/APP.git' into an app name.
+func appName(repo string) string {
+	repo = strings.Trim(repo, "'/")
+	return strings.TrimSuffix(repo, ".git")
+}
@@ -0,0 +1,172 @@
+package etcd
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Masterminds/cookoo"
+	"github.com/coreos/go-etcd/etcd"
+)
+
+func TestCanPush(t *testing.T) {
+	client := &keyClient{keys: map[string]bool{
+		"/deis/services/foo":          true,
+		"/deis/services/bar":          true,
+		"/deis/builder/apps":          true,
+		"/deis/builder/apps/foo/alice": true,
+		"/deis/builder/admins/root":   true,
+	}}
+
+	tests := []struct {
+		user, repo string
+		allowed    bool
+	}{
+		{"alice", "'/foo.git'", true},
+		{"alice", "bar", false},
+		{"bob", "/foo.git", false},
+		{"root", "bar.git", true},
+		{"root", "baz.git", false},
+		{"", "foo.git", false},
+	}
+
+	for _, tt := range tests {
+		reg, router, cxt := cookoo.Cookoo()
+		reg.Route("test", "Test route").
+			Does(CanPush, "res").
+			Using("client").WithDefault(client).
+			Using("user").WithDefault(tt.user).
+			Using("repoName").WithDefault(tt.repo)
+
+		err := router.HandleRequest("test", cxt, true)
+		if tt.allowed && err != nil {
+			t.Errorf("Expected %s to push to %s, got %s", tt.user, tt.repo, err)
+		} else if !tt.allowed && err == nil {
+			t.Errorf("Expected %s to be denied push to %s", tt.user, tt.repo)
+		}
+	}
+}
+
+func TestCanPushNoPerms(t *testing.T) {
+	// Without any published app permissions, the controller decides.
+	client := &keyClient{keys: map[string]bool{"/deis/services/foo": true}}
+	reg, router, cxt := cookoo.Cookoo()
+	reg.Route("test", "Test route").
+		Does(CanPush, "res").
+		Using("client").WithDefault(client).
+		Using("user").WithDefault("alice").
+		Using("repoName").WithDefault("foo.git")
+
+	if err := router.HandleRequest("test", cxt, true); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCanPushCache(t *testing.T) {
+	client := &keyClient{keys: map[string]bool{
+		"/deis/services/foo":          true,
+		"/deis/builder/apps/foo/alice": true,
+	}}
+	cache := NewPermCache()
+
+	reg, router, cxt := cookoo.Cookoo()
+	reg.Route("test", "Test route").
+		Does(CanPush, "res").
+		Using("client").WithDefault(client).
+		Using("user").WithDefault("alice").
+		Using("repoName").WithDefault("foo").
+		Using("cache").WithDefault(cache)
+
+	if err := router.HandleRequest("test", cxt, true); err != nil {
+		t.Fatal(err)
+	}
+	if allowed, ok := cache.Allowed("alice", "foo"); !ok || !allowed {
+		t.Error("Expected decision to be cached.")
+	}
+
+	// A cached decision is used until the cache is flushed.
+	delete(client.keys, "/deis/builder/apps/foo/alice")
+	client.keys["/deis/builder/apps"] = true
+	if err := router.HandleRequest("test", cxt, true); err != nil {
+		t.Errorf("Expected cached decision, got %s", err)
+	}
+	cache.Flush()
+	if err := router.HandleRequest("test", cxt, true); err == nil {
+		t.Error("Expected push to be denied after flush.")
+	}
+}
+
+// keyClient implements Getter over a fixed set of keys.
+type keyClient struct {
+	keys map[string]bool
+}
+
+func (k *keyClient) Get(key string, sort, recurse bool) (*etcd.Response, error) {
+	if !k.keys[key] {
+		return nil, errors.New("Key not found")
+	}
+	return &etcd.Response{Action: "get", Node: &etcd.Node{Key: key}}, nil
+}
+
+// watchClient replays changes to usersPath and records the indexes watched.
+type watchClient struct {
+	mx      sync.Mutex
+	indexes []uint64
+	start   chan bool
+	done    chan bool
+}
+
+func (w *watchClient) Watch(prefix string, index uint64, recursive bool, receiver chan *etcd.Response, stop chan bool) (*etcd.Response, error) {
+	if prefix != usersPath {
+		select {}
+	}
+	w.mx.Lock()
+	w.indexes = append(w.indexes, index)
+	n := len(w.indexes)
+	w.mx.Unlock()
+
+	switch n {
+	case 1:
+		<-w.start
+		return &etcd.Response{Node: &etcd.Node{ModifiedIndex: 5}}, nil
+	case 2:
+		return nil, errors.New("The event in requested index is outdated and cleared")
+	case 3:
+		return &etcd.Response{Node: &etcd.Node{ModifiedIndex: 9}}, nil
+	}
+	close(w.done)
+	select {}
+}
+
+func TestWatchPermsResumes(t *testing.T) {
+	client := &watchClient{start: make(chan bool), done: make(chan bool)}
+	reg, router, cxt := cookoo.Cookoo()
+	reg.Route("test", "Test route").
+		Does(WatchPerms, "cache").
+		Using("client").WithDefault(client)
+
+	if err := router.HandleRequest("test", cxt, true); err != nil {
+		t.Fatal(err)
+	}
+	cache := cxt.Get("cache", nil).(*PermCache)
+	cache.SetUser("fingerprint", "alice")
+	close(client.start)
+
+	select {
+	case <-client.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the watch")
+	}
+
+	client.mx.Lock()
+	defer client.mx.Unlock()
+	expected := []uint64{0, 6, 0, 10}
+	if !reflect.DeepEqual(client.indexes, expected) {
+		t.Errorf("Expected watches from %v, got %v", expected, client.indexes)
+	}
+	if _, ok := cache.User("fingerprint"); ok {
+		t.Error("Expected the cache to be flushed")
+	}
+}
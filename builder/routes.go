@@ -172,6 +172,8 @@ func routes(reg *cookoo.Registry) {
 				Using: []cookoo.Param{
 					{Name: "tag", DefaultValue: "deis/slugrunner:latest"},
 					{Name: "client", From: "cxt:client"},
+					{Name: "dockerClient", From: "cxt:docker"},
+					{Name: "basepath", From: "cxt:ETCD_PATH"},
 				},
 			},
 
@@ -184,33 +186,13 @@ func routes(reg *cookoo.Registry) {
 					{Name: "client", From: "cxt:client"},
 				},
 			},
-			// If there's an EXTERNAL_PORT, we publish info to etcd.
-			cookoo.Cmd{
-				Name: "externalport",
-				Fn:   env.Get,
-				Using: []cookoo.Param{
-					{Name: "EXTERNAL_PORT", DefaultValue: ""},
-				},
-			},
+			// Cache SSH user lookups and push permissions, flushing the cache
+			// whenever the controller publishes changes to etcd.
 			cookoo.Cmd{
-				Name: "etcdupdate",
-				Fn:   etcd.UpdateHostPort,
+				Name: "permCache",
+				Fn:   etcd.WatchPerms,
 				Using: []cookoo.Param{
-					{Name: "base", From: "cxt:ETCD_PATH"},
-					{Name: "host", From: "cxt:HOST"},
-					{Name: "port", From: "cxt:EXTERNAL_PORT"},
 					{Name: "client", From: "cxt:client"},
-					{Name: "sshdPid", From: "cxt:sshd"},
-				},
-			},
-
-			// DAEMON: Finally, we wait around for a signal, and then cleanup.
-			cookoo.Cmd{
-				Name: "listen",
-				Fn:   KillOnExit,
-				Using: []cookoo.Param{
-					{Name: "docker", From: "cxt:dockerstart"},
-					{Name: "sshd", From: "cxt:sshdstart"},
 				},
 			},
 		},
@@ -220,11 +202,31 @@ func routes(reg *cookoo.Registry) {
 	// The rough pattern is that we parse the local authorized keys file, and
 	// then validate that the supplied user key matches an authorized key.
 	//
+	// The key must also belong to a known Deis user.
+	//
 	// This grants access to running git-receive, but does not grant access
 	// to writing to the repo. That's handled by the sshReceive.
 	reg.AddRoute(cookoo.Route{
 		Name: "pubkeyAuth",
 		Does: []cookoo.Task{
+			// Resolve the key to a Deis user.
+			cookoo.Cmd{
+				Name: "fingerprint",
+				Fn:   sshd.FingerprintKey,
+				Using: []cookoo.Param{
+					{Name: "key", From: "cxt:key"},
+				},
+			},
+			cookoo.Cmd{
+				Name: "username",
+				Fn:   etcd.FindSSHUser,
+				Using: []cookoo.Param{
+					{Name: "client", From: "cxt:client"},
+					{Name: "fingerprint", From: "cxt:fingerprint"},
+					{Name: "cache", From: "cxt:permCache"},
+				},
+			},
+
 			// Parse the authorized keys file.
 			// We do this every time because confd is constantly regenerating
 			// the auth keys file.
@@ -236,14 +238,16 @@ func routes(reg *cookoo.Registry) {
 				},
 			},
 
-			// Auth against the keys
+			// Auth against the keys. The permissions are returned to
+			// the SSH server, and passed on to sshGitReceive.
 			cookoo.Cmd{
-				Name: "authN",
+				Name: "pubkeyAuth",
 				Fn:   sshd.AuthKey,
 				Using: []cookoo.Param{
 					{Name: "metadata", From: "cxt:metadata"},
 					{Name: "key", From: "cxt:key"},
 					{Name: "authorizedKeys", From: "cxt:authorizedKeys"},
+					{Name: "username", From: "cxt:username"},
 				},
 			},
 		},
@@ -273,23 +277,19 @@ func routes(reg *cookoo.Registry) {
 		Name: "sshGitReceive",
 		Help: "Handle a git receive over an SSH connection.",
 		Does: []cookoo.Task{
-			// The Git receive handler needs the username. So we provide
-			// it by looking up the name based on the key. When the
-			// controller no longer requires username for SSH auth, we can
-			// ditch this.
-			cookoo.Cmd{
-				Name: "fingerprint",
-				Fn:   sshd.FingerprintKey,
-				Using: []cookoo.Param{
-					{Name: "key", From: "cxt:key"},
-				},
-			},
+			// The key's fingerprint and Deis user come from the
+			// permissions granted by pubkeyAuth when the connection
+			// was authenticated.
+			// Reject the push before a repo is created if the user is
+			// neither a collaborator on the app nor an administrator.
 			cookoo.Cmd{
-				Name: "username",
-				Fn:   etcd.FindSSHUser,
+				Name: "authZ",
+				Fn:   etcd.CanPush,
 				Using: []cookoo.Param{
 					{Name: "client", From: "cxt:client"},
-					{Name: "fingerprint", From: "cxt:fingerprint"},
+					{Name: "user", From: "cxt:username"},
+					{Name: "repoName", From: "cxt:repository"},
+					{Name: "cache", From: "cxt:permCache"},
 				},
 			},
 			cookoo.Cmd{
@@ -301,7 +301,7 @@ func routes(reg *cookoo.Registry) {
 					{Name: "operation", From: "cxt:operation"},
 					{Name: "repoName", From: "cxt:repository"},
 					{Name: "fingerprint", From: "cxt:fingerprint"},
-					{Name: "permissions", From: "cxt:authN"},
+					{Name: "permissions", From: "cxt:permissions"},
 					{Name: "user", From: "cxt:username"},
 				},
 			},
@@ -16,11 +16,11 @@ func assert(err error) {
 }
 
 func usage(s string) {
-	fmt.Printf("Usage: %s <sha> <receive_user> <receive_repo> <image> <procfile> <dockerfile>\n", s)
+	fmt.Printf("Usage: %s <sha> <receive_user> <receive_repo> <image> <procfile> <dockerfile> [<build_options> [<image_digest>]]\n", s)
 }
 
 func main() {
-	if len(os.Args) != 7 {
+	if len(os.Args) < 7 || len(os.Args) > 9 {
 		usage(os.Args[0])
 		os.Exit(1)
 	}
@@ -33,13 +33,25 @@ func main() {
 		dockerfile = ""
 	}
 
+	var buildOptions builder.BuildRecord
+	if len(os.Args) > 7 && os.Args[7] != "" {
+		assert(json.Unmarshal([]byte(os.Args[7]), &buildOptions))
+	}
+
+	var imageDigest string
+	if len(os.Args) > 8 {
+		imageDigest = os.Args[8]
+	}
+
 	buildHook := builder.BuildHook{
-		Sha:         os.Args[1],
-		ReceiveUser: os.Args[2],
-		ReceiveRepo: os.Args[3],
-		Image:       os.Args[4],
-		Procfile:    procfile,
-		Dockerfile:  dockerfile,
+		Sha:          os.Args[1],
+		ReceiveUser:  os.Args[2],
+		ReceiveRepo:  os.Args[3],
+		Image:        os.Args[4],
+		Procfile:     procfile,
+		Dockerfile:   dockerfile,
+		BuildOptions: buildOptions,
+		ImageDigest:  imageDigest,
 	}
 
 	b, err := json.Marshal(buildHook)
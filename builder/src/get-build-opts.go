@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/deis/deis/builder"
+	docli "github.com/fsouza/go-dockerclient"
+)
+
+func init() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: [options]\n\n")
+		flag.PrintDefaults()
+	}
+}
+
+func main() {
+	format := flag.String("format", "flags", "Output format: flags, json or dockerfile")
+	url := flag.String("url", "unix:///var/run/docker.sock", "Docker daemon URL, used to check that it supports the options")
+	flag.Parse()
+
+	if fi, _ := os.Stdin.Stat(); fi.Mode()&os.ModeNamedPipe == 0 {
+		fmt.Println("this app only works using the stdout of another process")
+		os.Exit(1)
+	}
+
+	bytes, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		panic(err)
+	}
+
+	opts, err := builder.ParseBuildOptions(bytes)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	// only ask the daemon for its version when an option may need it
+	if opts.Target != "" || len(opts.Args) > 0 {
+		if err := checkDockerVersion(*url, opts); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	switch *format {
+	case "flags":
+		for _, f := range builder.BuildFlags(opts) {
+			fmt.Println(f)
+		}
+	case "json":
+		b, err := json.Marshal(builder.RecordBuildOptions(opts))
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Println(string(b))
+	case "dockerfile":
+		if opts.Dockerfile == "" {
+			fmt.Println("Dockerfile")
+		} else {
+			fmt.Println(opts.Dockerfile)
+		}
+	default:
+		flag.Usage()
+		os.Exit(1)
+	}
+}
+
+func checkDockerVersion(url string, opts builder.BuildOptions) error {
+	client, err := docli.NewClient(url)
+	if err != nil {
+		return err
+	}
+	env, err := client.Version()
+	if err != nil {
+		return err
+	}
+	return builder.CheckBuildOptions(opts, env.Get("Version"))
+}
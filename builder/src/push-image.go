@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/coreos/go-etcd/etcd"
+	"github.com/deis/deis/builder/docker"
+	docli "github.com/fsouza/go-dockerclient"
+)
+
+func init() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: [options]\n\n")
+		flag.PrintDefaults()
+	}
+}
+
+// push-image pushes an image to a registry.
+//
+// Registry credentials are read from etcd, so that they never appear in the
+// build script or on a command line. Progress is written to stderr. On
+// success, the digest of the pushed image is written to stdout.
+func main() {
+	image := flag.String("image", "", "Image to push, including the registry host")
+	url := flag.String("url", "unix:///var/run/docker.sock", "Docker daemon URL")
+	etcdURL := flag.String("etcd", "http://127.0.0.1:4001", "etcd URL to read registry credentials from")
+	basepath := flag.String("basepath", "/deis/builder", "etcd path holding registryUsername, registryPassword and registryEmail")
+	retries := flag.Int("retries", 3, "Number of times to retry a failed push")
+
+	flag.Parse()
+
+	if *image == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	client, err := docli.NewClient(*url)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	name, tag := docker.ParseTag(*image)
+	opts := docker.PushOptions{
+		Name:    name,
+		Tag:     tag,
+		Auth:    docker.RegistryAuth(etcd.NewClient([]string{*etcdURL}), *basepath),
+		Retries: *retries,
+	}
+
+	digest, err := docker.PushImage(client, opts, os.Stderr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	fmt.Println(digest)
+}
@@ -50,7 +50,9 @@ echo "pre-receive hook END"
 // a Cookoo app. It assumes that certain things have been configured for it,
 // like an ssh.ServerConfig. Once it runs, it will block until the main
 // process terminates. If you want to stop it prior to that, you can grab
-// the closer ("sshd.Closer") out of the context and send it a signal.
+// the closer ("sshd.Closer") out of the context and send it a signal. That
+// stops new connections from being accepted; wait on "sshd.Connections" for
+// the ones already accepted to finish.
 //
 // Currently, the service is not generic. It only runs git hooks.
 //
@@ -60,7 +62,8 @@ echo "pre-receive hook END"
 // 	- ssh.ServerConfig (*ssh.ServerConfig): The server config to use.
 //
 // This puts the following variables into the context:
-// 	- ssh.Closer (chan interface{}): Send a message to this to shutdown the server.
+// 	- sshd.Closer (chan interface{}): Send a message to this to shutdown the server.
+// 	- sshd.Connections (*sync.WaitGroup): Counts the connections being served.
 func Serve(reg *cookoo.Registry, router *cookoo.Router, c cookoo.Context) cookoo.Interrupt {
 	hostkeys := c.Get(HostKeys, []ssh.Signer{}).([]ssh.Signer)
 	addr := c.Get(Address, "0.0.0.0:2223").(string)
@@ -83,6 +86,7 @@ func Serve(reg *cookoo.Registry, router *cookoo.Router, c cookoo.Context) cookoo
 
 	closer := make(chan interface{}, 1)
 	c.Put("sshd.Closer", closer)
+	c.Put("sshd.Connections", &srv.conns)
 
 	log.Infof(c, "Listening on %s", addr)
 	srv.listen(listener, cfg, closer)
@@ -96,30 +100,43 @@ type server struct {
 	gitHome    string
 	hookTpl    *template.Template
 	createLock sync.Mutex
+	conns      sync.WaitGroup
 }
 
 // listen handles accepting and managing connections. However, since closer
 // is len(1), it will not block the sender.
+//
+// A message on closer closes the listener, so that a blocked Accept returns.
+// Connections that were already accepted keep being served, and are counted
+// in s.conns until they finish.
 func (s *server) listen(l net.Listener, conf *ssh.ServerConfig, closer chan interface{}) error {
 	cxt := s.c
 	log.Info(cxt, "Accepting new connections.")
 	defer l.Close()
 
-	// FIXME: Since Accept blocks, closer may not be checked often enough.
+	closing := make(chan struct{})
+	safely.GoDo(cxt, func() {
+		<-closer
+		log.Info(cxt, "Shutting down SSHD listener.")
+		close(closing)
+		l.Close()
+	})
+
 	for {
-		log.Info(cxt, "Checking closer.")
-		if len(closer) > 0 {
-			<-closer
-			log.Info(cxt, "Shutting down SSHD listener.")
-			return nil
-		}
 		conn, err := l.Accept()
 		if err != nil {
+			select {
+			case <-closing:
+				return nil
+			default:
+			}
 			log.Warnf(cxt, "Error during Accept: %s", err)
 			// We shouldn't kill the listener because of an error.
 			return err
 		}
+		s.conns.Add(1)
 		safely.GoDo(cxt, func() {
+			defer s.conns.Done()
 			s.handleConn(conn, conf)
 		})
 	}
@@ -131,7 +148,7 @@ func (s *server) listen(l net.Listener, conf *ssh.ServerConfig, closer chan inte
 func (s *server) handleConn(conn net.Conn, conf *ssh.ServerConfig) {
 	defer conn.Close()
 	log.Info(s.c, "Accepted connection.")
-	_, chans, reqs, err := ssh.NewServerConn(conn, conf)
+	sconn, chans, reqs, err := ssh.NewServerConn(conn, conf)
 	if err != nil {
 		// Handshake failure.
 		log.Errf(s.c, "Failed handshake: %s (%v)", err, conn)
@@ -155,7 +172,11 @@ func (s *server) handleConn(conn net.Conn, conf *ssh.ServerConfig) {
 			// Should close request and move on.
 			panic(err)
 		}
-		safely.GoDo(s.c, func() { s.answer(channel, req, condata) })
+		s.conns.Add(1)
+		safely.GoDo(s.c, func() {
+			defer s.conns.Done()
+			s.answer(channel, req, condata, sconn.Permissions)
+		})
 	}
 	conn.Close()
 }
@@ -173,7 +194,7 @@ func sshConnection(conn net.Conn) string {
 }
 
 func sendExitStatus(status uint32, channel ssh.Channel) error {
-	exit := struct{ Status uint32 }{uint32(0)}
+	exit := struct{ Status uint32 }{status}
 	_, err := channel.SendRequest("exit-status", false, ssh.Marshal(exit))
 	return err
 }
@@ -186,7 +207,10 @@ func sendExitStatus(status uint32, channel ssh.Channel) error {
 // correct behavior for a failed exec is.
 //
 // Support for setting environment variables via `env` has been disabled.
-func (s *server) answer(channel ssh.Channel, requests <-chan *ssh.Request, sshConn string) error {
+//
+// The permissions are those pubkeyAuth granted the connection. Their
+// "fingerprint" and "username" extensions identify the key and Deis user.
+func (s *server) answer(channel ssh.Channel, requests <-chan *ssh.Request, sshConn string, perms *ssh.Permissions) error {
 	defer channel.Close()
 
 	// Answer all the requests on this connection.
@@ -211,6 +235,11 @@ func (s *server) answer(channel ssh.Channel, requests <-chan *ssh.Request, sshCo
 			// We need a shallow copy of the context to avoid race conditions.
 			cxt := s.c.Copy()
 			cxt.Put("SSH_CONNECTION", sshConn)
+			cxt.Put("permissions", perms)
+			if perms != nil {
+				cxt.Put("fingerprint", perms.Extensions["fingerprint"])
+				cxt.Put("username", perms.Extensions["username"])
+			}
 
 			// Only allow commands that we know about.
 			switch parts[0] {
@@ -240,6 +269,7 @@ func (s *server) answer(channel ssh.Channel, requests <-chan *ssh.Request, sshCo
 				var xs uint32
 				if err != nil {
 					log.Errf(s.c, "Failed git receive: %v", err)
+					fmt.Fprintf(channel.Stderr(), "ERROR: %s\n", err)
 					xs = 1
 				}
 				sendExitStatus(xs, channel)
@@ -98,6 +98,7 @@ func ParseHostKeys(c cookoo.Context, p *cookoo.Params) (interface{}, cookoo.Inte
 // 	- metadata (ssh.ConnMetadata)
 // 	- key (ssh.PublicKey)
 // 	- authorizedKeys ([]string): List of lines from an authorized keys file.
+// 	- username (string): The Deis user that owns the key, if known.
 //
 // Returns:
 // 	*ssh.Permissions
@@ -106,6 +107,7 @@ func AuthKey(c cookoo.Context, p *cookoo.Params) (interface{}, cookoo.Interrupt)
 	meta := p.Get("metadata", nil).(ssh.ConnMetadata)
 	key := p.Get("key", nil).(ssh.PublicKey)
 	authorized := p.Get("authorizedKeys", []string{}).([]string)
+	username := p.Get("username", "").(string)
 
 	auth := new(ssh.CertChecker)
 	auth.UserKeyFallback = func(meta ssh.ConnMetadata, pk ssh.PublicKey) (*ssh.Permissions, error) {
@@ -128,9 +130,13 @@ func AuthKey(c cookoo.Context, p *cookoo.Params) (interface{}, cookoo.Interrupt)
 				log.Infof(c, "Key accepted for user %s.", meta.User())
 				perm := &ssh.Permissions{
 					Extensions: map[string]string{
-						"user": meta.User(),
+						"user":        meta.User(),
+						"fingerprint": Fingerprint(key),
 					},
 				}
+				if len(username) > 0 {
+					perm.Extensions["username"] = username
+				}
 				return perm, nil
 			}
 		}
@@ -169,7 +175,8 @@ func Start(c cookoo.Context, p *cookoo.Params) (interface{}, cookoo.Interrupt) {
 // Configure creates a new SSH configuration object.
 //
 // Config sets a PublicKeyCallback handler that forwards public key auth
-// requests to the route named "pubkeyAuth".
+// requests to the route named "pubkeyAuth". The route's "pubkeyAuth" command
+// returns the connection's *ssh.Permissions.
 //
 // This assumes certain details about our environment, like the location of the
 // host keys. It also provides only key-based authentication.
@@ -182,12 +189,15 @@ func Configure(c cookoo.Context, p *cookoo.Params) (interface{}, cookoo.Interrup
 
 	cfg := &ssh.ServerConfig{
 		PublicKeyCallback: func(m ssh.ConnMetadata, k ssh.PublicKey) (*ssh.Permissions, error) {
-			c.Put("metadata", m)
-			c.Put("key", k)
+			// Connections authenticate concurrently, so each gets its own
+			// copy of the context.
+			cxt := c.Copy()
+			cxt.Put("metadata", m)
+			cxt.Put("key", k)
 
 			pubkeyAuth := c.Get("route.sshd.pubkeyAuth", "pubkeyAuth").(string)
-			err := router.HandleRequest(pubkeyAuth, c, true)
-			return c.Get("pubkeyAuth", &ssh.Permissions{}).(*ssh.Permissions), err
+			err := router.HandleRequest(pubkeyAuth, cxt, true)
+			return cxt.Get("pubkeyAuth", &ssh.Permissions{}).(*ssh.Permissions), err
 		},
 	}
 
@@ -18,12 +18,33 @@ type ConfigHook struct {
 
 // BuildHook represents a controller's build-hook object.
 type BuildHook struct {
-	Sha         string      `json:"sha"`
-	ReceiveUser string      `json:"receive_user"`
-	ReceiveRepo string      `json:"receive_repo"`
-	Image       string      `json:"image"`
-	Procfile    ProcessType `json:"procfile"`
-	Dockerfile  string      `json:"dockerfile"`
+	Sha          string      `json:"sha"`
+	ReceiveUser  string      `json:"receive_user"`
+	ReceiveRepo  string      `json:"receive_repo"`
+	Image        string      `json:"image"`
+	Procfile     ProcessType `json:"procfile"`
+	Dockerfile   string      `json:"dockerfile"`
+	BuildOptions BuildRecord `json:"build_options"`
+	ImageDigest  string      `json:"image_digest"`
+}
+
+// BuildOptions represents the options used to build an image from a Dockerfile.
+//
+// They are read from the application's configuration. See ParseBuildOptions.
+type BuildOptions struct {
+	Dockerfile string            `json:"dockerfile,omitempty"`
+	Target     string            `json:"target,omitempty"`
+	Args       map[string]string `json:"args,omitempty"`
+}
+
+// BuildRecord represents the build options recorded on a build.
+//
+// Only the names of build arguments are recorded, as their values may be
+// secrets.
+type BuildRecord struct {
+	Dockerfile string   `json:"dockerfile,omitempty"`
+	Target     string   `json:"target,omitempty"`
+	Args       []string `json:"args,omitempty"`
 }
 
 // BuildHookResponse represents a controller's build-hook response object.
@@ -3,10 +3,21 @@ package builder
 import (
 	"encoding/json"
 	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
 
 	"gopkg.in/yaml.v2"
 )
 
+// Application configuration keys that control Dockerfile builds.
+const (
+	DockerfilePathKey = "DEIS_DOCKERFILE_PATH"
+	BuildTargetKey    = "DEIS_BUILD_TARGET"
+	BuildArgPrefix    = "DEIS_BUILD_ARG_"
+)
+
 // YamlToJSON takes an input yaml string, parses it and returns a string formatted as json.
 func YamlToJSON(bytes []byte) (string, error) {
 	var anomaly map[string]string
@@ -105,3 +116,115 @@ func ParseControllerConfig(bytes []byte) ([]string, error) {
 	}
 	return retVal, nil
 }
+
+// ParseBuildOptions returns the Dockerfile build options from a config.
+//
+// DEIS_DOCKERFILE_PATH selects a Dockerfile relative to the root of the
+// repository, DEIS_BUILD_TARGET selects the stage to build, and each
+// DEIS_BUILD_ARG_NAME=value becomes the build argument NAME=value.
+func ParseBuildOptions(bytes []byte) (BuildOptions, error) {
+	var opts BuildOptions
+	var controllerConfig Config
+	if err := json.Unmarshal(bytes, &controllerConfig); err != nil {
+		return opts, err
+	}
+
+	for k, v := range controllerConfig.Values {
+		value := fmt.Sprintf("%v", v)
+		switch key := strings.ToUpper(k); {
+		case key == DockerfilePathKey:
+			opts.Dockerfile = value
+		case key == BuildTargetKey:
+			opts.Target = value
+		case strings.HasPrefix(key, BuildArgPrefix) && len(key) > len(BuildArgPrefix):
+			if opts.Args == nil {
+				opts.Args = map[string]string{}
+			}
+			opts.Args[k[len(BuildArgPrefix):]] = value
+		}
+	}
+
+	if opts.Dockerfile != "" {
+		clean := path.Clean(opts.Dockerfile)
+		if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+			return opts, fmt.Errorf("%s must be a path inside the repository", DockerfilePathKey)
+		}
+		opts.Dockerfile = clean
+	}
+
+	return opts, nil
+}
+
+// BuildFlags returns the `docker build` flags for a set of build options.
+//
+// Values are quoted for the shell.
+func BuildFlags(opts BuildOptions) []string {
+	retVal := []string{}
+	if opts.Dockerfile != "" {
+		retVal = append(retVal, fmt.Sprintf(" -f %s", shellQuote(opts.Dockerfile)))
+	}
+	if opts.Target != "" {
+		retVal = append(retVal, fmt.Sprintf(" --target %s", shellQuote(opts.Target)))
+	}
+
+	for _, name := range argNames(opts) {
+		retVal = append(retVal, fmt.Sprintf(" --build-arg %s", shellQuote(name+"="+opts.Args[name])))
+	}
+	return retVal
+}
+
+// RecordBuildOptions returns the build options to record on a build.
+func RecordBuildOptions(opts BuildOptions) BuildRecord {
+	return BuildRecord{
+		Dockerfile: opts.Dockerfile,
+		Target:     opts.Target,
+		Args:       argNames(opts),
+	}
+}
+
+// CheckBuildOptions returns an error if the Docker daemon, at the given
+// version, does not support the build options.
+//
+// Build arguments need Docker 1.9 and build targets need Docker 17.05.
+func CheckBuildOptions(opts BuildOptions, version string) error {
+	if opts.Target != "" && !versionAtLeast(version, 17, 5) {
+		return fmt.Errorf("%s requires Docker 17.05 or later, but the builder runs Docker %s", BuildTargetKey, version)
+	}
+	if len(opts.Args) > 0 && !versionAtLeast(version, 1, 9) {
+		return fmt.Errorf("%s* requires Docker 1.9 or later, but the builder runs Docker %s", BuildArgPrefix, version)
+	}
+	return nil
+}
+
+// versionAtLeast returns whether a Docker version such as "1.5.0" or
+// "17.05.0-ce" is at least major.minor.
+func versionAtLeast(version string, major, minor int) bool {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return false
+	}
+	vMajor, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return false
+	}
+	vMinor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return false
+	}
+	return vMajor > major || vMajor == major && vMinor >= minor
+}
+
+// argNames returns the names of the build arguments, sorted.
+func argNames(opts BuildOptions) []string {
+	names := make([]string, 0, len(opts.Args))
+	for name := range opts.Args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// shellQuote wraps a string in single quotes so that the shell treats it literally.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
@@ -3,6 +3,7 @@ package builder
 import (
 	"bytes"
 	"encoding/json"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -164,6 +165,97 @@ func TestParseControllerConfigGood(t *testing.T) {
 	}
 }
 
+func TestParseBuildOptionsGood(t *testing.T) {
+	// mock controller config response
+	resp := []byte(`{"owner": "test",
+		"app": "example-go",
+		"values": {"FOO": "bar", "DEIS_DOCKERFILE_PATH": "docker/../build/Dockerfile",
+			"DEIS_BUILD_TARGET": "release", "DEIS_BUILD_ARG_VERSION": "1.5",
+			"DEIS_BUILD_ARG_GREETING": "it's here"},
+		"memory": {},
+		"cpu": {},
+		"tags": {},
+		"created": "2014-01-01T00:00:00UTC",
+		"updated": "2014-01-01T00:00:00UTC",
+		"uuid": "de1bf5b5-4a72-4f94-a10c-d2a3741cdf75"
+	}`)
+
+	opts, err := ParseBuildOptions(resp)
+	if err != nil {
+		t.Fatalf("expected to pass, got '%v'", err)
+	}
+
+	if opts.Dockerfile != "build/Dockerfile" {
+		t.Errorf("expected 'build/Dockerfile', got '%s'", opts.Dockerfile)
+	}
+	if opts.Target != "release" {
+		t.Errorf("expected 'release', got '%s'", opts.Target)
+	}
+	if len(opts.Args) != 2 || opts.Args["VERSION"] != "1.5" {
+		t.Errorf("expected two build args, got '%v'", opts.Args)
+	}
+
+	flags := BuildFlags(opts)
+	expected := []string{
+		" -f 'build/Dockerfile'",
+		" --target 'release'",
+		" --build-arg 'GREETING=it'\\''s here'",
+		" --build-arg 'VERSION=1.5'",
+	}
+	if !reflect.DeepEqual(flags, expected) {
+		t.Errorf("expected %v, got %v", expected, flags)
+	}
+}
+
+func TestRecordBuildOptions(t *testing.T) {
+	opts := BuildOptions{
+		Dockerfile: "build/Dockerfile",
+		Args:       map[string]string{"VERSION": "1.5", "TOKEN": "secret"},
+	}
+
+	b, err := json.Marshal(RecordBuildOptions(opts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"dockerfile":"build/Dockerfile","args":["TOKEN","VERSION"]}`
+	if string(b) != expected {
+		t.Errorf("expected %s, got %s", expected, b)
+	}
+}
+
+func TestCheckBuildOptions(t *testing.T) {
+	args := BuildOptions{Args: map[string]string{"VERSION": "1.5"}}
+	target := BuildOptions{Target: "release"}
+
+	tests := []struct {
+		opts    BuildOptions
+		version string
+		ok      bool
+	}{
+		{BuildOptions{Dockerfile: "build/Dockerfile"}, "1.5.0", true},
+		{args, "1.5.0", false},
+		{args, "1.9.1", true},
+		{target, "1.9.1", false},
+		{target, "1.13.0", false},
+		{target, "17.05.0-ce", true},
+		{target, "", false},
+	}
+	for _, tt := range tests {
+		if err := CheckBuildOptions(tt.opts, tt.version); (err == nil) != tt.ok {
+			t.Errorf("%+v on Docker %q: expected ok=%t, got %v", tt.opts, tt.version, tt.ok, err)
+		}
+	}
+}
+
+func TestParseBuildOptionsBad(t *testing.T) {
+	for _, path := range []string{"/etc/Dockerfile", "../Dockerfile", "docker/../../Dockerfile"} {
+		resp := []byte(`{"values": {"DEIS_DOCKERFILE_PATH": "` + path + `"}}`)
+		if _, err := ParseBuildOptions(resp); err == nil {
+			t.Errorf("expected %s to be rejected", path)
+		}
+	}
+}
+
 func TestTimeSerialize(t *testing.T) {
 	time, err := json.Marshal(&dtime.Time{Time: time.Now().UTC()})
 
@@ -2,118 +2,129 @@ package main
 
 import (
 	"io/ioutil"
-	"log"
-	"net"
-	"os"
-	"os/exec"
-	"os/signal"
+	"strconv"
 	"strings"
-	"syscall"
-	"time"
 
-	"github.com/coreos/go-etcd/etcd"
+	"github.com/deis/deis/pkg/boot"
+	"github.com/deis/deis/pkg/boot/types"
+	"github.com/deis/deis/pkg/etcd"
+	"github.com/deis/deis/pkg/health"
+	logger "github.com/deis/deis/pkg/log"
+	oswrapper "github.com/deis/deis/pkg/os"
 )
 
 const (
-	timeout         time.Duration = 10 * time.Second
-	ttl             time.Duration = timeout * 2
-	redisWait       time.Duration = 5 * time.Second
-	redisConf       string        = "/app/redis.conf"
-	ectdKeyNotFound int           = 100
-	defaultMemory   string        = "50mb"
+	redisConf     string = "/app/redis.conf"
+	redisPort     int    = 6379
+	defaultMemory string = "50mb"
 )
 
-func main() {
-	host := getopt("HOST", "127.0.0.1")
-
-	etcdPort := getopt("ETCD_PORT", "4001")
-	etcdPath := getopt("ETCD_PATH", "/deis/cache")
-
-	externalPort := getopt("EXTERNAL_PORT", "6379")
+var (
+	etcdPath = oswrapper.Getopt("ETCD_PATH", "/deis/cache")
+	log      = logger.New()
+)
 
-	client := etcd.NewClient([]string{"http://" + host + ":" + etcdPort})
+func init() {
+	boot.RegisterComponent(new(CacheBoot), "boot")
+}
 
-	var maxmemory string
-	result, err := client.Get("/deis/cache/maxmemory", false, false)
+func main() {
+	externalPort := oswrapper.Getopt("EXTERNAL_PORT", strconv.Itoa(redisPort))
+	port, err := strconv.Atoi(externalPort)
 	if err != nil {
-		if e, ok := err.(*etcd.EtcdError); ok && e.ErrorCode == ectdKeyNotFound {
-			maxmemory = defaultMemory
-		} else {
-			log.Fatalln(err)
-		}
-	} else {
-		maxmemory = result.Node.Key
+		log.Fatalf("invalid EXTERNAL_PORT %s: %v", externalPort, err)
 	}
-	replaceMaxmemoryInConfig(maxmemory)
-
-	go launchRedis()
+	boot.Start(etcdPath, port)
+}
 
-	go publishService(client, host, etcdPath, externalPort, uint64(ttl.Seconds()))
+// CacheBoot struct to boot redis.
+type CacheBoot struct{}
 
-	// Wait for terminating signal
-	exitChan := make(chan os.Signal, 2)
-	signal.Notify(exitChan, syscall.SIGTERM, syscall.SIGINT)
-	<-exitChan
+// MkdirsEtcd creates a directory in etcd.
+func (cb *CacheBoot) MkdirsEtcd() []string {
+	return []string{etcdPath}
 }
 
-func replaceMaxmemoryInConfig(maxmemory string) {
-	input, err := ioutil.ReadFile(redisConf)
-	if err != nil {
-		log.Fatalln(err)
+// EtcdDefaults returns default values for etcd.
+func (cb *CacheBoot) EtcdDefaults() map[string]string {
+	return map[string]string{
+		etcdPath + "/maxmemory": defaultMemory,
 	}
-	output := strings.Replace(string(input), "# maxmemory <bytes>", "maxmemory "+maxmemory, 1)
-	err = ioutil.WriteFile(redisConf, []byte(output), 0644)
-	if err != nil {
-		log.Fatalln(err)
+}
+
+// PreBoot writes the configured maxmemory into the redis configuration.
+func (cb *CacheBoot) PreBoot(currentBoot *types.CurrentBoot) {
+	maxmemory := etcd.Get(currentBoot.EtcdClient, etcdPath+"/maxmemory")
+	if maxmemory == "" {
+		maxmemory = defaultMemory
 	}
+	replaceMaxmemoryInConfig(maxmemory)
+}
+
+// PreBootScripts runs preboot scripts.
+func (cb *CacheBoot) PreBootScripts(currentBoot *types.CurrentBoot) []*types.Script {
+	return []*types.Script{}
 }
 
-func launchRedis() {
-	cmd := exec.Command("redis-server", redisConf)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+// UseConfd does not use confd.
+func (cb *CacheBoot) UseConfd() (bool, bool) {
+	return false, false
+}
 
-	err := cmd.Start()
+// BootDaemons starts redis.
+func (cb *CacheBoot) BootDaemons(currentBoot *types.CurrentBoot) []*types.ServiceDaemon {
+	return []*types.ServiceDaemon{&types.ServiceDaemon{Command: "redis-server", Args: []string{redisConf}}}
+}
 
-	if err != nil {
-		log.Printf("Error starting Redis: %v", err)
-		os.Exit(1)
-	}
+// WaitForPorts returns the port redis listens on.
+func (cb *CacheBoot) WaitForPorts() []int {
+	return []int{redisPort}
+}
 
-	// Wait until the redis server is available
-	for {
-		_, err := net.DialTimeout("tcp", "127.0.0.1:6379", redisWait)
-		if err == nil {
-			log.Println("deis-cache running...")
-			break
-		}
+// HealthChecks checks that redis answers PING.
+func (cb *CacheBoot) HealthChecks(currentBoot *types.CurrentBoot) []*types.HealthCheck {
+	return []*types.HealthCheck{
+		&types.HealthCheck{Name: "redis", Check: health.RedisCheck("127.0.0.1:" + strconv.Itoa(redisPort))},
 	}
+}
 
-	err = cmd.Wait()
-	log.Printf("Redis finished by error: %v", err)
+// PublishKeys publishes the cache's host and port.
+func (cb *CacheBoot) PublishKeys(currentBoot *types.CurrentBoot) map[string]string {
+	return currentBoot.HostPortKeys(currentBoot.EtcdPath)
 }
 
-func publishService(client *etcd.Client, host string, etcdPath string,
-	externalPort string, ttl uint64) {
+// PostBootScripts returns type script.
+func (cb *CacheBoot) PostBootScripts(currentBoot *types.CurrentBoot) []*types.Script {
+	return []*types.Script{}
+}
 
-	for {
-		setEtcd(client, etcdPath+"/host", host, ttl)
-		setEtcd(client, etcdPath+"/port", externalPort, ttl)
-		time.Sleep(timeout)
-	}
+// PostBoot logs that the cache is running.
+func (cb *CacheBoot) PostBoot(currentBoot *types.CurrentBoot) {
+	log.Info("deis-cache running...")
 }
 
-func setEtcd(client *etcd.Client, key, value string, ttl uint64) {
-	_, err := client.Set(key, value, ttl)
-	if err != nil && !strings.Contains(err.Error(), "Key already exists") {
-		log.Println(err)
-	}
+// ScheduleTasks returns a cron job.
+func (cb *CacheBoot) ScheduleTasks(currentBoot *types.CurrentBoot) []*types.Cron {
+	return []*types.Cron{}
+}
+
+// PreShutdown does nothing, since redis saves its data when it is stopped.
+func (cb *CacheBoot) PreShutdown(currentBoot *types.CurrentBoot) {
+}
+
+// PreShutdownScripts returns type script.
+func (cb *CacheBoot) PreShutdownScripts(currentBoot *types.CurrentBoot) []*types.Script {
+	return []*types.Script{}
 }
 
-func getopt(name, dfault string) string {
-	value := os.Getenv(name)
-	if value == "" {
-		value = dfault
+func replaceMaxmemoryInConfig(maxmemory string) {
+	input, err := ioutil.ReadFile(redisConf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	output := strings.Replace(string(input), "# maxmemory <bytes>", "maxmemory "+maxmemory, 1)
+	err = ioutil.WriteFile(redisConf, []byte(output), 0644)
+	if err != nil {
+		log.Fatal(err)
 	}
-	return value
 }
@@ -5,6 +5,7 @@ import (
 
 	"gopkg.in/yaml.v2"
 
+	"github.com/deis/deis/client/controller/api"
 	"github.com/deis/deis/client/controller/models/builds"
 )
 
@@ -30,6 +31,10 @@ func BuildsList(appID string, results int) error {
 
 	for _, build := range builds {
 		fmt.Println(build.UUID, build.Created)
+		if build.ImageDigest != "" {
+			fmt.Println("  digest:", build.ImageDigest)
+		}
+		printBuildOptions(build.BuildOptions)
 	}
 	return nil
 }
@@ -66,3 +71,16 @@ func BuildsCreate(appID, image, procfile string) error {
 
 	return nil
 }
+
+func printBuildOptions(opts api.BuildOptions) {
+	if opts.Dockerfile != "" {
+		fmt.Println("  dockerfile:", opts.Dockerfile)
+	}
+	if opts.Target != "" {
+		fmt.Println("  target:", opts.Target)
+	}
+
+	for _, name := range opts.Args {
+		fmt.Println("  build-arg:", name)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path"
 	"regexp"
 	"sort"
 	"strings"
@@ -12,7 +13,9 @@ import (
 	"github.com/deis/deis/pkg/prettyprint"
 
 	"github.com/deis/deis/client/controller/api"
+	"github.com/deis/deis/client/controller/client"
 	"github.com/deis/deis/client/controller/models/config"
+	"github.com/deis/deis/client/pkg/dotenv"
 )
 
 // ConfigList lists an app's config.
@@ -29,6 +32,14 @@ func ConfigList(appID string, oneLine bool) error {
 		return err
 	}
 
+	// secrets are listed with the masks the controller returns for them
+	for k, v := range config.Secrets {
+		if config.Values == nil {
+			config.Values = make(map[string]interface{})
+		}
+		config.Values[k] = v
+	}
+
 	var keys []string
 	for k := range config.Values {
 		keys = append(keys, k)
@@ -56,8 +67,9 @@ func ConfigList(appID string, oneLine bool) error {
 	return nil
 }
 
-// ConfigSet sets an app's config variables.
-func ConfigSet(appID string, configVars []string) error {
+// ConfigSet sets an app's config variables. Secrets are encrypted by the
+// controller and masked when they are listed.
+func ConfigSet(appID string, configVars []string, secret bool) error {
 	c, appID, err := load(appID)
 
 	if err != nil {
@@ -66,34 +78,19 @@ func ConfigSet(appID string, configVars []string) error {
 
 	configMap := parseConfig(configVars)
 
-	value, ok := configMap["SSH_KEY"]
-
-	if ok {
-		sshKey := value.(string)
-
-		if _, err := os.Stat(value.(string)); err == nil {
-			contents, err := ioutil.ReadFile(value.(string))
-
-			if err != nil {
-				return err
-			}
-
-			sshKey = string(contents)
-		}
-
-		sshRegex := regexp.MustCompile("^-.+ .SA PRIVATE KEY-*")
-
-		if !sshRegex.MatchString(sshKey) {
-			return fmt.Errorf("Could not parse SSH private key:\n %s", sshKey)
+	if value, ok := configMap["SSH_KEY"]; ok {
+		if configMap["SSH_KEY"], err = sshKey(value.(string)); err != nil {
+			return err
 		}
-
-		configMap["SSH_KEY"] = base64.StdEncoding.EncodeToString([]byte(sshKey))
 	}
 
 	fmt.Print("Creating config... ")
 
 	quit := progress()
 	configObj := api.Config{Values: configMap}
+	if secret {
+		configObj = api.Config{Secrets: configMap}
+	}
 	configObj, err = config.Set(c, appID, configObj)
 
 	quit <- true
@@ -148,14 +145,82 @@ func ConfigUnset(appID string, configVars []string) error {
 	return ConfigList(appID, false)
 }
 
-// ConfigPull pulls an app's config to a file.
-func ConfigPull(appID string, interactive bool, overwrite bool) error {
+// ConfigPull pulls an app's config to a .env file, printing the variables it
+// adds, changes and, with prune, removes. Variables only set in .env are kept
+// unless prune is true, and secrets are left out.
+func ConfigPull(appID string, interactive bool, overwrite bool, prune bool, dryRun bool) error {
 	filename := ".env"
 
-	if !overwrite {
-		if _, err := os.Stat(filename); err == nil {
-			return fmt.Errorf("%s already exists, pass -o to overwrite", filename)
+	local, err := readEnv(filename)
+
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	c, appID, err := load(appID)
+
+	if err != nil {
+		return err
+	}
+
+	configVars, err := config.List(c, appID)
+
+	if err != nil {
+		return err
+	}
+
+	remote := configValues(configVars)
+	diff := pullDiff(local, remote, configVars.Secrets, prune)
+
+	if len(diff.Changed) > 0 && !overwrite && !interactive && !dryRun {
+		return fmt.Errorf("%s already sets %s, pass -o to overwrite or -i to choose",
+			filename, strings.Join(diff.Changed, ", "))
+	}
+
+	if diff.Empty() {
+		fmt.Printf("%s is up to date\n", filename)
+		return nil
+	}
+
+	printDiff(diff, local, remote)
+
+	if dryRun {
+		return nil
+	}
+
+	for _, key := range diff.Added {
+		local[key] = remote[key]
+	}
+
+	for _, key := range diff.Changed {
+		if interactive && !overwrite {
+			var confirm string
+			fmt.Printf("%s: overwrite %q with %q? (y/N) ", key, local[key], remote[key])
+
+			fmt.Scanln(&confirm)
+
+			if strings.ToLower(confirm) != "y" {
+				continue
+			}
 		}
+		local[key] = remote[key]
+	}
+
+	for _, key := range diff.Removed {
+		delete(local, key)
+	}
+
+	// .env holds credentials, so it is only readable by its owner
+	return ioutil.WriteFile(filename, dotenv.Format(local), 0600)
+}
+
+// ConfigPush pushes an app's config from a file, printing the variables it
+// adds, changes and, with prune, unsets. Secrets are left unchanged.
+func ConfigPush(appID string, fileName string, prune bool, dryRun bool) error {
+	local, err := readEnv(fileName)
+
+	if err != nil {
+		return err
 	}
 
 	c, appID, err := load(appID)
@@ -166,57 +231,177 @@ func ConfigPull(appID string, interactive bool, overwrite bool) error {
 
 	configVars, err := config.List(c, appID)
 
-	if interactive {
-		contents, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
 
-		if err != nil {
+	for key := range configVars.Secrets {
+		if _, ok := local[key]; ok {
+			fmt.Printf("Skipping %s, it is a secret. Use config:set --secret to change it.\n", key)
+			delete(local, key)
+		}
+	}
+
+	if value, ok := local["SSH_KEY"]; ok {
+		if local["SSH_KEY"], err = sshKey(value); err != nil {
 			return err
 		}
-		localConfigVars := strings.Split(string(contents), "\n")
+	}
+
+	remote := configValues(configVars)
+	diff := dotenv.Compare(remote, local)
 
-		configMap := parseConfig(localConfigVars[:len(localConfigVars)-1])
+	if !prune {
+		diff.Removed = nil
+	}
 
-		for key, value := range configVars.Values {
-			localValue, ok := configMap[key]
+	if diff.Empty() {
+		fmt.Println("Config is up to date")
+		return nil
+	}
 
-			if ok {
-				if value != localValue {
-					var confirm string
-					fmt.Printf("%s: overwrite %s with %s? (y/N) ", key, localValue, value)
+	printDiff(diff, remote, local)
 
-					fmt.Scanln(&confirm)
+	if dryRun {
+		return nil
+	}
 
-					if strings.ToLower(confirm) == "y" {
-						configMap[key] = value
-					}
-				}
-			} else {
-				configMap[key] = value
-			}
+	valuesMap := make(map[string]interface{})
+
+	for _, key := range append(diff.Added, diff.Changed...) {
+		valuesMap[key] = local[key]
+	}
+
+	for _, key := range diff.Removed {
+		valuesMap[key] = nil
+	}
+
+	fmt.Print("Pushing config... ")
+
+	quit := progress()
+	configObj, err := config.Set(c, appID, api.Config{Values: valuesMap})
+
+	quit <- true
+	<-quit
+
+	if err != nil {
+		return err
+	}
+
+	if release, ok := configObj.Values["DEIS_RELEASE"]; ok {
+		fmt.Printf("done, %s\n\n", release)
+	} else {
+		fmt.Print("done\n\n")
+	}
+
+	return ConfigList(appID, false)
+}
+
+// pullDiff returns the variables pulling the remote config into local adds,
+// changes and, with prune, removes. Secrets are left out of .env, so variables
+// named after them are not removed.
+func pullDiff(local map[string]string, remote map[string]string,
+	secrets map[string]interface{}, prune bool) dotenv.Diff {
+	diff := dotenv.Compare(local, remote)
+	removed := diff.Removed
+	diff.Removed = nil
+
+	if !prune {
+		return diff
+	}
+
+	for _, key := range removed {
+		if _, ok := secrets[key]; !ok {
+			diff.Removed = append(diff.Removed, key)
+		}
+	}
+
+	return diff
+}
+
+// sshKey returns the value SSH_KEY is set to: the base64 encoded private key,
+// read from a file if value is the path to one. Values that are already
+// encoded, such as the ones config:pull writes to .env, are kept.
+func sshKey(value string) (string, error) {
+	sshRegex := regexp.MustCompile("^-.+ .SA PRIVATE KEY-*")
+
+	if decoded, err := base64.StdEncoding.DecodeString(value); err == nil &&
+		sshRegex.Match(decoded) {
+		return value, nil
+	}
+
+	key := value
+
+	if strings.HasPrefix(value, "~/") {
+		value = path.Join(client.FindHome(), value[2:])
+	}
+
+	if _, err := os.Stat(value); err == nil {
+		contents, err := ioutil.ReadFile(value)
+
+		if err != nil {
+			return "", err
 		}
 
-		return ioutil.WriteFile(filename, []byte(formatConfig(configMap)), 0755)
+		key = string(contents)
 	}
 
-	return ioutil.WriteFile(filename, []byte(formatConfig(configVars.Values)), 0755)
+	if !sshRegex.MatchString(key) {
+		return "", fmt.Errorf("Could not parse SSH private key:\n %s", key)
+	}
+
+	return base64.StdEncoding.EncodeToString([]byte(key)), nil
 }
 
-// ConfigPush pushes an app's config from a file.
-func ConfigPush(appID string, fileName string) error {
+// readEnv reads the variables of a .env file. The variables are empty if the
+// file doesn't exist.
+func readEnv(fileName string) (map[string]string, error) {
 	contents, err := ioutil.ReadFile(fileName)
 
 	if err != nil {
-		return err
+		return map[string]string{}, err
+	}
+
+	env, err := dotenv.Parse(contents)
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fileName, err)
+	}
+
+	return env, nil
+}
+
+// configValues returns an app's config values as strings.
+func configValues(configVars api.Config) map[string]string {
+	values := make(map[string]string)
+
+	for key, value := range configVars.Values {
+		values[key] = fmt.Sprintf("%v", value)
+	}
+
+	return values
+}
+
+// printDiff prints the variables that changing from one set to another adds,
+// changes and removes.
+func printDiff(diff dotenv.Diff, from map[string]string, to map[string]string) {
+	for _, key := range diff.Added {
+		fmt.Printf("+ %s=%q\n", key, to[key])
 	}
 
-	config := strings.Split(string(contents), "\n")
-	return ConfigSet(appID, config[:len(config)-1])
+	for _, key := range diff.Changed {
+		fmt.Printf("~ %s: %q -> %q\n", key, from[key], to[key])
+	}
+
+	for _, key := range diff.Removed {
+		fmt.Printf("- %s\n", key)
+	}
 }
 
 func parseConfig(configVars []string) map[string]interface{} {
 	configMap := make(map[string]interface{})
 
-	regex := regexp.MustCompile("^([A-z_]+[A-z0-9_]*)=(.+)$")
+	regex := regexp.MustCompile("(?s)^([A-Za-z_]+[A-Za-z0-9_]*)=(.*)$")
 	for _, config := range configVars {
 		if regex.MatchString(config) {
 			captures := regex.FindStringSubmatch(config)
@@ -228,13 +413,3 @@ func parseConfig(configVars []string) map[string]interface{} {
 
 	return configMap
 }
-
-func formatConfig(configVars map[string]interface{}) string {
-	var formattedConfig string
-
-	for key, value := range configVars {
-		formattedConfig += fmt.Sprintf("%s=%s\n", key, value)
-	}
-
-	return formattedConfig
-}
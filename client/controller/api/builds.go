@@ -2,15 +2,25 @@ package api
 
 // Build is the structure of the build object.
 type Build struct {
-	App        string            `json:"app"`
-	Created    string            `json:"created"`
-	Dockerfile string            `json:"dockerfile,omitempty"`
-	Image      string            `json:"image,omitempty"`
-	Owner      string            `json:"owner"`
-	Procfile   map[string]string `json:"procfile"`
-	Sha        string            `json:"sha,omitempty"`
-	Updated    string            `json:"updated"`
-	UUID       string            `json:"uuid"`
+	App          string            `json:"app"`
+	BuildOptions BuildOptions      `json:"build_options,omitempty"`
+	Created      string            `json:"created"`
+	Dockerfile   string            `json:"dockerfile,omitempty"`
+	Image        string            `json:"image,omitempty"`
+	ImageDigest  string            `json:"image_digest,omitempty"`
+	Owner        string            `json:"owner"`
+	Procfile     map[string]string `json:"procfile"`
+	Sha          string            `json:"sha,omitempty"`
+	Updated      string            `json:"updated"`
+	UUID         string            `json:"uuid"`
+}
+
+// BuildOptions is the structure of the options used to build a Dockerfile app.
+// Only the names of build args are recorded.
+type BuildOptions struct {
+	Args       []string `json:"args,omitempty"`
+	Dockerfile string   `json:"dockerfile,omitempty"`
+	Target     string   `json:"target,omitempty"`
 }
 
 // CreateBuildRequest is the structure of POST /v1/apps/<app id>/builds/.
@@ -18,6 +18,7 @@ type Config struct {
 	Memory  map[string]interface{} `json:"memory,omitempty"`
 	CPU     map[string]interface{} `json:"cpu,omitempty"`
 	Tags    map[string]interface{} `json:"tags,omitempty"`
+	Secrets map[string]interface{} `json:"secrets,omitempty"`
 	Created string                 `json:"created,omitempty"`
 	Updated string                 `json:"updated,omitempty"`
 	UUID    string                 `json:"uuid,omitempty"`
@@ -22,6 +22,13 @@ const buildsFixture string = `
     "results": [
         {
             "app": "example-go",
+            "build_options": {
+                "args": [
+                    "VERSION"
+                ],
+                "dockerfile": "docker/Dockerfile",
+                "target": "release"
+            },
             "created": "2014-01-01T00:00:00UTC",
             "dockerfile": "FROM deis/slugrunner RUN mkdir -p /app WORKDIR /app ENTRYPOINT [\"/runner/init\"] ADD slug.tgz /app ENV GIT_SHA 060da68f654e75fac06dbedd1995d5f8ad9084db",
             "image": "example-go",
@@ -94,7 +101,12 @@ func TestBuildsList(t *testing.T) {
 
 	expected := []api.Build{
 		api.Build{
-			App:        "example-go",
+			App: "example-go",
+			BuildOptions: api.BuildOptions{
+				Args:       []string{"VERSION"},
+				Dockerfile: "docker/Dockerfile",
+				Target:     "release",
+			},
 			Created:    "2014-01-01T00:00:00UTC",
 			Dockerfile: "FROM deis/slugrunner RUN mkdir -p /app WORKDIR /app ENTRYPOINT [\"/runner/init\"] ADD slug.tgz /app ENV GIT_SHA 060da68f654e75fac06dbedd1995d5f8ad9084db",
 			Image:      "example-go",
@@ -31,6 +31,9 @@ const configFixture string = `
     "tags": {
       "test": "tests"
     },
+    "secrets": {
+      "PASSWORD": "********"
+    },
     "created": "2014-01-01T00:00:00UTC",
     "updated": "2014-01-01T00:00:00UTC",
     "uuid": "de1bf5b5-4a72-4f94-a10c-d2a3741cdf75"
@@ -144,6 +147,9 @@ func TestConfigSet(t *testing.T) {
 		Tags: map[string]interface{}{
 			"test": "tests",
 		},
+		Secrets: map[string]interface{}{
+			"PASSWORD": "********",
+		},
 		Created: "2014-01-01T00:00:00UTC",
 		Updated: "2014-01-01T00:00:00UTC",
 		UUID:    "de1bf5b5-4a72-4f94-a10c-d2a3741cdf75",
@@ -265,6 +271,9 @@ func TestConfigList(t *testing.T) {
 		Tags: map[string]interface{}{
 			"test": "tests",
 		},
+		Secrets: map[string]interface{}{
+			"PASSWORD": "********",
+		},
 		Created: "2014-01-01T00:00:00UTC",
 		Updated: "2014-01-01T00:00:00UTC",
 		UUID:    "de1bf5b5-4a72-4f94-a10c-d2a3741cdf75",
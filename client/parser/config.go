@@ -71,6 +71,10 @@ func configSet(argv []string) error {
 	usage := `
 Sets environment variables for an application.
 
+Secrets are encrypted by the controller, masked by config:list and only
+decrypted when they are set in the application's containers. The platform
+needs a secrets key, set with "deisctl config platform set secretsKey=<key>".
+
 Usage: deis config:set <var>=<value> [<var>=<value>...] [options]
 
 Arguments:
@@ -82,6 +86,8 @@ Arguments:
 Options:
   -a --app=<app>
     the uniquely identifiable name for the application.
+  -s --secret
+    encrypt the values, and mask them when config is listed.
 `
 
 	args, err := docopt.Parse(usage, argv, true, "", false, true)
@@ -90,7 +96,7 @@ Options:
 		return err
 	}
 
-	return cmd.ConfigSet(safeGetValue(args, "--app"), args["<var>=<value>"].([]string))
+	return cmd.ConfigSet(safeGetValue(args, "--app"), args["<var>=<value>"].([]string), args["--secret"].(bool))
 }
 
 func configUnset(argv []string) error {
@@ -122,7 +128,9 @@ func configPull(argv []string) error {
 Extract all environment variables from an application for local use.
 
 Your environment will be stored locally in a file named .env. This file can be
-read by foreman to load the local environment for your app.
+read by foreman to load the local environment for your app. The variables that
+are added, changed and removed in .env are printed. Variables only set in .env
+are kept unless --prune is given, and secrets are left out.
 
 Usage: deis config:pull [options]
 
@@ -133,6 +141,11 @@ Options:
     Prompts for each value to be overwritten
   -o --overwrite
     Allows you to have the pull overwrite keys in .env
+  --prune
+    Removes variables from .env that the application doesn't set, other than
+    its secrets
+  --dry-run
+    Prints the changes to .env without writing it
 `
 
 	args, err := docopt.Parse(usage, argv, true, "", false, true)
@@ -144,8 +157,10 @@ Options:
 	app := safeGetValue(args, "--app")
 	interactive := args["--interactive"].(bool)
 	overwrite := args["--overwrite"].(bool)
+	prune := args["--prune"].(bool)
+	dryRun := args["--dry-run"].(bool)
 
-	return cmd.ConfigPull(app, interactive, overwrite)
+	return cmd.ConfigPull(app, interactive, overwrite, prune, dryRun)
 }
 
 func configPush(argv []string) error {
@@ -153,7 +168,10 @@ func configPush(argv []string) error {
 Sets environment variables for an application.
 
 The environment is read from <path>. This file can be read by foreman
-to load the local environment for your app.
+to load the local environment for your app. The variables that are added,
+changed and unset are printed. Variables the application sets that aren't in
+<path> are kept unless --prune is given, and secrets are left unchanged.
+SSH_KEY may be set to a private key or the path to one, as with config:set.
 
 Usage: deis config:push [options]
 
@@ -162,6 +180,10 @@ Options:
     the uniquely identifiable name for the application.
   -p <path>, --path=<path>
     a path leading to an environment file [default: .env]
+  --prune
+    unsets variables that aren't in <path>.
+  --dry-run
+    prints the changes without pushing them.
 `
 
 	args, err := docopt.Parse(usage, argv, true, "", false, true)
@@ -170,5 +192,6 @@ Options:
 		return err
 	}
 
-	return cmd.ConfigPush(safeGetValue(args, "--app"), safeGetValue(args, "--path"))
+	return cmd.ConfigPush(safeGetValue(args, "--app"), safeGetValue(args, "--path"),
+		args["--prune"].(bool), args["--dry-run"].(bool))
 }
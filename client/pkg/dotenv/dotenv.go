@@ -0,0 +1,235 @@
+package dotenv
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	keyRegex = regexp.MustCompile("^[A-Za-z_][A-Za-z0-9_]*$")
+	// values made only of these characters are written without quotes
+	plainRegex = regexp.MustCompile("^[A-Za-z0-9_./:@,+%=-]*$")
+)
+
+// Parse reads the variables of a .env file.
+//
+// Lines are KEY=value pairs, optionally prefixed with "export". Blank lines
+// and lines starting with # are skipped. Values may be unquoted, in which case
+// a # after whitespace starts a comment, single quoted, in which case they are
+// read literally, or double quoted, in which case \n, \r, \t, \", \\ and \$
+// are unescaped. Quoted values may span several lines.
+func Parse(data []byte) (map[string]string, error) {
+	env := make(map[string]string)
+	p := &parser{src: strings.Replace(string(data), "\r\n", "\n", -1), line: 1}
+
+	for {
+		p.skipBlank()
+		if p.pos >= len(p.src) {
+			return env, nil
+		}
+		line := p.line
+		key, value, err := p.variable()
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		env[key] = value
+	}
+}
+
+// Format writes variables in the format read by Parse, ordered by key.
+func Format(env map[string]string) []byte {
+	var b bytes.Buffer
+	for _, key := range sortedKeys(env) {
+		fmt.Fprintf(&b, "%s=%s\n", key, quote(env[key]))
+	}
+	return b.Bytes()
+}
+
+// Diff is the difference between two sets of variables.
+type Diff struct {
+	// Added are the keys only set by the variables changed to
+	Added []string
+	// Changed are the keys whose values differ
+	Changed []string
+	// Removed are the keys only set by the variables changed from
+	Removed []string
+}
+
+// Compare returns the keys that changing from one set of variables to another
+// adds, changes and removes, each ordered by key.
+func Compare(from, to map[string]string) Diff {
+	var d Diff
+	for _, key := range sortedKeys(to) {
+		if value, ok := from[key]; !ok {
+			d.Added = append(d.Added, key)
+		} else if value != to[key] {
+			d.Changed = append(d.Changed, key)
+		}
+	}
+	for _, key := range sortedKeys(from) {
+		if _, ok := to[key]; !ok {
+			d.Removed = append(d.Removed, key)
+		}
+	}
+	return d
+}
+
+// Empty returns whether there is no difference.
+func (d Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Changed) == 0 && len(d.Removed) == 0
+}
+
+type parser struct {
+	src  string
+	pos  int
+	line int
+}
+
+// skipBlank skips whitespace, blank lines and comments.
+func (p *parser) skipBlank() {
+	for p.pos < len(p.src) {
+		switch p.src[p.pos] {
+		case '\n':
+			p.line++
+			fallthrough
+		case ' ', '\t':
+			p.pos++
+		case '#':
+			p.pos = p.lineEnd()
+		default:
+			return
+		}
+	}
+}
+
+// lineEnd returns the position of the end of the current line.
+func (p *parser) lineEnd() int {
+	if i := strings.IndexByte(p.src[p.pos:], '\n'); i >= 0 {
+		return p.pos + i
+	}
+	return len(p.src)
+}
+
+func (p *parser) variable() (string, string, error) {
+	head := p.src[p.pos:p.lineEnd()]
+	i := strings.IndexByte(head, '=')
+	if i < 0 {
+		return "", "", fmt.Errorf("expected KEY=value, got %q", head)
+	}
+	key := strings.TrimSpace(head[:i])
+	if strings.HasPrefix(key, "export ") || strings.HasPrefix(key, "export\t") {
+		key = strings.TrimSpace(key[len("export"):])
+	}
+	if !keyRegex.MatchString(key) {
+		return "", "", fmt.Errorf("invalid key %q", key)
+	}
+
+	p.pos += i + 1
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+
+	var value string
+	var err error
+	if p.pos < len(p.src) && p.src[p.pos] == '"' {
+		value, err = p.doubleQuoted()
+	} else if p.pos < len(p.src) && p.src[p.pos] == '\'' {
+		value, err = p.singleQuoted()
+	} else {
+		return key, p.unquoted(), nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	// only a comment may follow a quoted value
+	end := p.lineEnd()
+	rest := strings.TrimSpace(p.src[p.pos:end])
+	if rest != "" && !strings.HasPrefix(rest, "#") {
+		return "", "", fmt.Errorf("unexpected %q after the value of %s", rest, key)
+	}
+	p.pos = end
+	return key, value, nil
+}
+
+func (p *parser) unquoted() string {
+	end := p.lineEnd()
+	value := p.src[p.pos:end]
+	p.pos = end
+	for i := 1; i < len(value); i++ {
+		if value[i] == '#' && (value[i-1] == ' ' || value[i-1] == '\t') {
+			value = value[:i]
+			break
+		}
+	}
+	return strings.TrimSpace(value)
+}
+
+func (p *parser) singleQuoted() (string, error) {
+	i := strings.IndexByte(p.src[p.pos+1:], '\'')
+	if i < 0 {
+		return "", errors.New("unterminated single quoted value")
+	}
+	value := p.src[p.pos+1 : p.pos+1+i]
+	p.line += strings.Count(value, "\n")
+	p.pos += i + 2
+	return value, nil
+}
+
+func (p *parser) doubleQuoted() (string, error) {
+	var b bytes.Buffer
+	for p.pos++; p.pos < len(p.src); p.pos++ {
+		c := p.src[p.pos]
+		switch {
+		case c == '"':
+			p.pos++
+			return b.String(), nil
+		case c == '\\' && p.pos+1 < len(p.src):
+			p.pos++
+			switch next := p.src[p.pos]; next {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case '"', '\\', '$':
+				b.WriteByte(next)
+			default:
+				if next == '\n' {
+					p.line++
+				}
+				b.WriteByte('\\')
+				b.WriteByte(next)
+			}
+		default:
+			if c == '\n' {
+				p.line++
+			}
+			b.WriteByte(c)
+		}
+	}
+	return "", errors.New("unterminated double quoted value")
+}
+
+// quote returns value as it is written by Format.
+func quote(value string) string {
+	if plainRegex.MatchString(value) {
+		return value
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	return `"` + r.Replace(value) + `"`
+}
+
+func sortedKeys(env map[string]string) []string {
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,12 @@
+from django.core.management.base import BaseCommand
+
+from api.models import publish_builder_perms
+
+
+class Command(BaseCommand):
+    """Management command for publishing push permissions to the builder."""
+
+    help = 'Publishes app collaborators and administrators to etcd for the builder'
+
+    def handle(self, *args, **options):
+        publish_builder_perms()
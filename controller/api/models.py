@@ -26,6 +26,7 @@ from django.db.models.signals import post_delete, post_save
 from django.dispatch import receiver
 from django.utils.encoding import python_2_unicode_compatible
 from docker.utils import utils as dockerutils
+from guardian.models import UserObjectPermission
 from json_field.fields import JSONField
 from OpenSSL import crypto
 import requests
@@ -323,12 +324,14 @@ class App(UuidAuditedModel):
 
     def _scale_containers(self, scale_types, to_remove):
         release = self.release_set.latest()
+        env = release.environment()
         for scale_type in scale_types:
             image = release.image
             version = "v{}".format(release.version)
             kwargs = {'memory': release.config.memory,
                       'cpu': release.config.cpu,
                       'tags': release.config.tags,
+                      'env': env,
                       'version': version,
                       'aname': self.id,
                       'num': scale_types[scale_type]}
@@ -473,12 +476,14 @@ class App(UuidAuditedModel):
             self._default_scale(user, release)
 
     def _deploy_app(self, scale_types, release, existing):
+        env = release.environment()
         for scale_type in scale_types:
             image = release.image
             version = "v{}".format(release.version)
             kwargs = {'memory': release.config.memory,
                       'cpu': release.config.cpu,
                       'tags': release.config.tags,
+                      'env': env,
                       'aname': self.id,
                       'num': 0,
                       'version': version}
@@ -624,7 +629,8 @@ class Container(UuidAuditedModel):
         image = self.release.image
         kwargs = {'memory': self.release.config.memory,
                   'cpu': self.release.config.cpu,
-                  'tags': self.release.config.tags}
+                  'tags': self.release.config.tags,
+                  'env': self.release.environment()}
         try:
             self._scheduler.create(
                 name=self.job_id,
@@ -680,7 +686,8 @@ class Container(UuidAuditedModel):
         else:
             command = "-c '{}'".format(command)
         try:
-            rc, output = self._scheduler.run(self.job_id, image, entrypoint, command)
+            rc, output = self._scheduler.run(self.job_id, image, entrypoint, command,
+                                             env=self.release.environment())
             return rc, output
         except Exception as e:
             err = '{} (run): {}'.format(self.job_id, e)
@@ -727,6 +734,8 @@ class Build(UuidAuditedModel):
     sha = models.CharField(max_length=40, blank=True)
     procfile = JSONField(default={}, blank=True)
     dockerfile = models.TextField(blank=True)
+    build_options = JSONField(default={}, blank=True)
+    image_digest = models.CharField(max_length=128, blank=True)
 
     class Meta:
         get_latest_by = 'created'
@@ -779,6 +788,9 @@ class Config(UuidAuditedModel):
     memory = JSONField(default={}, blank=True)
     cpu = JSONField(default={}, blank=True)
     tags = JSONField(default={}, blank=True)
+    # values encrypted with the platform's secrets key, which are only
+    # decrypted when the scheduler starts the app's containers
+    secrets = JSONField(default={}, blank=True)
 
     class Meta:
         get_latest_by = 'created'
@@ -788,11 +800,29 @@ class Config(UuidAuditedModel):
     def __str__(self):
         return "{}-{}".format(self.app.id, self.uuid[:7])
 
+    def secret_environment(self):
+        """
+        Return the decrypted secrets, which the scheduler sets in the environment
+        of the app's containers as they start.
+
+        Secrets that can't be decrypted, such as after the platform's secrets key
+        was changed, are left out and logged so the app can still be deployed.
+        """
+        env = {}
+        for k, v in self.secrets.viewitems():
+            try:
+                env[k] = utils.decrypt_secret(v, settings.SECRETS_KEY)
+            except ValueError as e:
+                msg = 'secret {} was left out ({}), set it again'.format(k, e)
+                log_event(self.app, msg, logging.WARNING)
+        return env
+
     def save(self, **kwargs):
         """merge the old config with the new"""
         try:
             previous_config = self.app.config_set.latest()
-            for attr in ['cpu', 'memory', 'tags', 'values']:
+            changed = {}
+            for attr in ['cpu', 'memory', 'tags', 'values', 'secrets']:
                 # Guard against migrations from older apps without fixes to
                 # JSONField encoding.
                 try:
@@ -807,6 +837,11 @@ class Config(UuidAuditedModel):
                 # remove config keys if we provided a null value
                 [data.pop(k) for k, v in new_data.viewitems() if v is None]
                 setattr(self, attr, data)
+                changed[attr] = new_data
+            # a key is either a value or a secret, so setting or unsetting
+            # one replaces the other
+            [self.secrets.pop(k, None) for k in changed['values']]
+            [self.values.pop(k, None) for k in changed['secrets']]
         except Config.DoesNotExist:
             pass
         return super(Config, self).save(**kwargs)
@@ -838,8 +873,30 @@ class Release(UuidAuditedModel):
 
     @property
     def image(self):
+        """
+        The image the scheduler runs for this release.
+
+        Builds whose digest the registry reported are pulled by that digest, so
+        the release runs exactly the image that was built.
+        """
+        if self.build is not None and self.build.image_digest:
+            return '{}@{}'.format(self.app.id, self.build.image_digest)
         return '{}:v{}'.format(self.app.id, str(self.version))
 
+    def environment(self):
+        """
+        Return the environment the scheduler sets in the release's containers.
+
+        An image pulled by digest does not carry the release's config values, so
+        they are set along with the decrypted secrets.
+        """
+        env = {}
+        if self.build is not None and self.build.image_digest:
+            env.update(self.config.values)
+            env.update({'DEIS_APP': self.app.id, 'DEIS_RELEASE': 'v{}'.format(self.version)})
+        env.update(self.config.secret_environment())
+        return env
+
     def new(self, user, config, build, summary=None, source_version='latest'):
         """
         Create a new application release using the provided Build and Config
@@ -885,9 +942,10 @@ class Release(UuidAuditedModel):
             if ':' in self.build.image:
                 if '/' not in self.build.image[self.build.image.rfind(':') + 1:]:
                     source_image += self.build.image[self.build.image.rfind(':'):]
+        # secrets are left out of the image, the scheduler sets them instead
         publish_release(source_image,
                         self.config.values,
-                        self.image)
+                        '{}:v{}'.format(self.app.id, self.version))
 
     def previous(self):
         """
@@ -940,8 +998,10 @@ class Release(UuidAuditedModel):
                     self.summary += "{} deployed {}".format(self.build.owner, self.build.image)
             # if the config data changed, log the dict diff
             if self.config != old_config:
-                dict1 = self.config.values
-                dict2 = old_config.values if old_config else {}
+                dict1 = self.config.values.copy()
+                dict1.update(self.config.secrets)
+                dict2 = old_config.values.copy() if old_config else {}
+                dict2.update(old_config.secrets if old_config else {})
                 diff = dict_diff(dict1, dict2)
                 # try to be as succinct as possible
                 added = ', '.join(k for k in diff.get('added', {}))
@@ -1125,12 +1185,18 @@ def _etcd_purge_user(**kwargs):
     except KeyError:
         # If _etcd_publish_key() wasn't called, there is no user dir to delete.
         pass
+    try:
+        _etcd_client.delete('/deis/builder/admins/{}'.format(username))
+    except KeyError:
+        pass
 
 
 def _etcd_create_app(**kwargs):
     appname = kwargs['instance']
     if kwargs['created']:
         _etcd_client.write('/deis/services/{}'.format(appname), None, dir=True)
+        _etcd_client.write('/deis/builder/apps/{}/{}'.format(
+            appname, appname.owner.username), appname.owner.username)
 
 
 def _etcd_purge_app(**kwargs):
@@ -1139,6 +1205,59 @@ def _etcd_purge_app(**kwargs):
         _etcd_client.delete('/deis/services/{}'.format(appname), dir=True, recursive=True)
     except KeyError:
         pass
+    try:
+        _etcd_client.delete('/deis/builder/apps/{}'.format(appname), dir=True, recursive=True)
+    except KeyError:
+        pass
+
+
+def _etcd_publish_app_perm(**kwargs):
+    perm = kwargs['instance']
+    if kwargs['created'] and perm.permission.codename == 'use_app':
+        _etcd_client.write('/deis/builder/apps/{}/{}'.format(
+            perm.content_object, perm.user.username), perm.user.username)
+
+
+def _etcd_purge_app_perm(**kwargs):
+    perm = kwargs['instance']
+    if perm.permission.codename != 'use_app':
+        return
+    try:
+        _etcd_client.delete('/deis/builder/apps/{}/{}'.format(
+            perm.content_object, perm.user.username))
+    except KeyError:
+        pass
+
+
+def _etcd_publish_admin(**kwargs):
+    user = kwargs['instance']
+    key = '/deis/builder/admins/{}'.format(user.username)
+    if user.is_superuser:
+        _etcd_client.write(key, user.username)
+    else:
+        try:
+            _etcd_client.delete(key)
+        except KeyError:
+            pass
+
+
+def publish_builder_perms():
+    """
+    Publish the owners and collaborators of every app, and the administrators,
+    so the builder can authorize pushes.
+
+    Permissions are otherwise only published as they change, so this is run
+    each time the controller starts to publish those that predate it.
+    """
+    if not _etcd_client:
+        return
+    for app in App.objects.all():
+        _etcd_client.write('/deis/builder/apps/{}/{}'.format(
+            app, app.owner.username), app.owner.username)
+    for perm in UserObjectPermission.objects.filter(permission__codename='use_app'):
+        _etcd_publish_app_perm(instance=perm, created=True)
+    for user in get_user_model().objects.filter(is_superuser=True):
+        _etcd_publish_admin(instance=user)
 
 
 def _etcd_publish_cert(**kwargs):
@@ -1167,6 +1286,7 @@ def _etcd_publish_config(**kwargs):
                             prevExist=True, dir=True, recursive=True)
     except KeyError:
         pass
+    # secrets are left out, so they aren't stored in plaintext
     if kwargs['created']:
         for k, v in config.values.iteritems():
             _etcd_client.write(
@@ -1224,6 +1344,11 @@ if _etcd_client:
     post_save.connect(_etcd_publish_key, sender=Key, dispatch_uid='api.models')
     post_delete.connect(_etcd_purge_key, sender=Key, dispatch_uid='api.models')
     post_delete.connect(_etcd_purge_user, sender=get_user_model(), dispatch_uid='api.models')
+    post_save.connect(_etcd_publish_admin, sender=get_user_model(), dispatch_uid='api.models')
+    post_save.connect(_etcd_publish_app_perm, sender=UserObjectPermission,
+                      dispatch_uid='api.models')
+    post_delete.connect(_etcd_purge_app_perm, sender=UserObjectPermission,
+                        dispatch_uid='api.models')
     post_save.connect(_etcd_publish_domains, sender=Domain, dispatch_uid='api.models')
     post_delete.connect(_etcd_purge_domains, sender=Domain, dispatch_uid='api.models')
     post_save.connect(_etcd_create_app, sender=App, dispatch_uid='api.models')
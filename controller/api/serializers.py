@@ -13,7 +13,7 @@ from django.utils import timezone
 from rest_framework import serializers
 from rest_framework.validators import UniqueTogetherValidator
 
-from api import models
+from api import models, utils
 
 
 PROCTYPE_MATCH = re.compile(r'^(?P<type>[a-z]+)')
@@ -154,17 +154,43 @@ class BuildSerializer(ModelSerializer):
     app = serializers.SlugRelatedField(slug_field='id', queryset=models.App.objects.all())
     owner = serializers.ReadOnlyField(source='owner.username')
     procfile = JSONFieldSerializer(required=False)
+    build_options = JSONFieldSerializer(required=False)
     created = serializers.DateTimeField(format=settings.DEIS_DATETIME_FORMAT, read_only=True)
     updated = serializers.DateTimeField(format=settings.DEIS_DATETIME_FORMAT, read_only=True)
 
     class Meta:
         """Metadata options for a :class:`BuildSerializer`."""
         model = models.Build
-        fields = ['owner', 'app', 'image', 'sha', 'procfile', 'dockerfile', 'created',
-                  'updated', 'uuid']
+        fields = ['owner', 'app', 'image', 'image_digest', 'sha', 'procfile', 'dockerfile',
+                  'build_options', 'created', 'updated', 'uuid']
         read_only_fields = ['uuid']
 
 
+class SecretsFieldSerializer(JSONStringFieldSerializer):
+    """
+    A JSON serializer that encrypts secrets when they are written and masks
+    them when they are read.
+    """
+
+    MASK = '********'
+
+    def to_representation(self, obj):
+        """Mask the secrets, so they are never returned by the API."""
+        return {k: self.MASK for k in obj}
+
+    def to_internal_value(self, data):
+        """Encrypt the secrets with the platform's secrets key."""
+        field = super(SecretsFieldSerializer, self).to_internal_value(data)
+        if not settings.SECRETS_KEY:
+            raise serializers.ValidationError(
+                'Secrets are disabled until the platform has a secrets key. '
+                'Set one with "deisctl config platform set secretsKey=<key>"')
+        for k, v in field.viewitems():
+            if v is not None:  # NoneType is used to unset a value
+                field[k] = utils.encrypt_secret(v, settings.SECRETS_KEY)
+        return field
+
+
 class ConfigSerializer(ModelSerializer):
     """Serialize a :class:`~api.models.Config` model."""
 
@@ -174,6 +200,7 @@ class ConfigSerializer(ModelSerializer):
     memory = JSONStringFieldSerializer(required=False)
     cpu = JSONIntFieldSerializer(required=False)
     tags = JSONStringFieldSerializer(required=False)
+    secrets = SecretsFieldSerializer(required=False)
     created = serializers.DateTimeField(format=settings.DEIS_DATETIME_FORMAT, read_only=True)
     updated = serializers.DateTimeField(format=settings.DEIS_DATETIME_FORMAT, read_only=True)
 
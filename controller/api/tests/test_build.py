@@ -97,7 +97,7 @@ class BuildTest(TransactionTestCase):
                                     HTTP_AUTHORIZATION='token {}'.format(self.token))
         for key in response.data:
             self.assertIn(key, ['uuid', 'owner', 'created', 'updated', 'app', 'dockerfile',
-                                'image', 'procfile', 'sha'])
+                                'build_options', 'image', 'image_digest', 'procfile', 'sha'])
         expected = {
             'owner': self.user.username,
             'app': 'test',
@@ -14,11 +14,13 @@ import requests
 
 from django.contrib.auth.models import User
 from django.test import TransactionTestCase
+from django.test.utils import override_settings
 import etcd
 from rest_framework.authtoken.models import Token
 
 import api.exceptions
-from api.models import App, Config
+from api.models import App, Config, Release
+from scheduler import mock as mock_scheduler
 
 
 def mock_status_ok(*args, **kwargs):
@@ -152,7 +154,7 @@ class ConfigTest(TransactionTestCase):
                                     HTTP_AUTHORIZATION='token {}'.format(self.token))
         for key in response.data:
             self.assertIn(key, ['uuid', 'owner', 'created', 'updated', 'app', 'values', 'memory',
-                                'cpu', 'tags'])
+                                'cpu', 'tags', 'secrets'])
         expected = {
             'owner': self.user.username,
             'app': 'test',
@@ -178,7 +180,7 @@ class ConfigTest(TransactionTestCase):
         self.assertEqual(response.status_code, 201)
         for key in response.data:
             self.assertIn(key, ['uuid', 'owner', 'created', 'updated', 'app', 'values', 'memory',
-                                'cpu', 'tags'])
+                                'cpu', 'tags', 'secrets'])
         expected = {
             'owner': self.user.username,
             'app': 'test',
@@ -250,6 +252,109 @@ class ConfigTest(TransactionTestCase):
         self.assertIn('INTEGER', response.data['values'])
         self.assertEqual(response.data['values']['INTEGER'], '1')
 
+    @override_settings(SECRETS_KEY='secret')
+    @mock.patch('requests.post', mock_status_ok)
+    @mock.patch('api.models.publish_release')
+    def test_config_secrets(self, mock_publish_release):
+        """
+        Test that secrets are encrypted, masked and only decrypted for the scheduler
+        """
+        url = '/v1/apps'
+        response = self.client.post(url, HTTP_AUTHORIZATION='token {}'.format(self.token))
+        self.assertEqual(response.status_code, 201)
+        app_id = response.data['id']
+        url = "/v1/apps/{app_id}/builds".format(**locals())
+        body = {'image': 'autotest/example'}
+        response = self.client.post(url, json.dumps(body), content_type='application/json',
+                                    HTTP_AUTHORIZATION='token {}'.format(self.token))
+        self.assertEqual(response.status_code, 201)
+        url = "/v1/apps/{app_id}/config".format(**locals())
+        body = {'values': json.dumps({'PORT': '5000'}),
+                'secrets': json.dumps({'DATABASE_PASSWORD': 'Деис'})}
+        response = self.client.post(url, json.dumps(body), content_type='application/json',
+                                    HTTP_AUTHORIZATION='token {}'.format(self.token))
+        self.assertEqual(response.status_code, 201)
+        self.assertEqual(response.data['secrets'], {'DATABASE_PASSWORD': '********'})
+        self.assertNotIn('Деис', json.dumps(response.data))
+        # the secret is encrypted at rest, kept out of the release image and
+        # decrypted for the scheduler to set when containers start
+        config = Config.objects.get(uuid=response.data['uuid'])
+        self.assertNotEqual(config.secrets['DATABASE_PASSWORD'], 'Деис')
+        self.assertEqual(config.secret_environment(), {'DATABASE_PASSWORD': 'Деис'})
+        _, values, _ = mock_publish_release.call_args[0]
+        self.assertEqual(values, {'PORT': '5000'})
+        container = App.objects.get(id=app_id).container_set.get()
+        self.assertEqual(mock_scheduler.jobs[container.job_id]['env'],
+                         {'DATABASE_PASSWORD': 'Деис'})
+        release = Release.objects.filter(app__id=app_id).latest()
+        self.assertIn('DATABASE_PASSWORD', release.summary)
+        # a secret replaces a value of the same name, and the other way around
+        body = {'secrets': json.dumps({'PORT': '5001'})}
+        response = self.client.post(url, json.dumps(body), content_type='application/json',
+                                    HTTP_AUTHORIZATION='token {}'.format(self.token))
+        self.assertEqual(response.status_code, 201)
+        self.assertNotIn('PORT', response.data['values'])
+        self.assertIn('PORT', response.data['secrets'])
+        body = {'values': json.dumps({'PORT': '5002'})}
+        response = self.client.post(url, json.dumps(body), content_type='application/json',
+                                    HTTP_AUTHORIZATION='token {}'.format(self.token))
+        self.assertEqual(response.status_code, 201)
+        self.assertEqual(response.data['values']['PORT'], '5002')
+        self.assertNotIn('PORT', response.data['secrets'])
+        # unsetting a value also unsets a secret
+        body = {'values': json.dumps({'DATABASE_PASSWORD': None})}
+        response = self.client.post(url, json.dumps(body), content_type='application/json',
+                                    HTTP_AUTHORIZATION='token {}'.format(self.token))
+        self.assertEqual(response.status_code, 201)
+        self.assertEqual(response.data['secrets'], {})
+
+    @mock.patch('requests.post', mock_status_ok)
+    def test_config_secrets_key_changed(self):
+        """
+        Test that secrets encrypted with an old secrets key are left out rather
+        than breaking deploys
+        """
+        url = '/v1/apps'
+        response = self.client.post(url, HTTP_AUTHORIZATION='token {}'.format(self.token))
+        self.assertEqual(response.status_code, 201)
+        app_id = response.data['id']
+        url = "/v1/apps/{app_id}/builds".format(**locals())
+        body = {'image': 'autotest/example'}
+        response = self.client.post(url, json.dumps(body), content_type='application/json',
+                                    HTTP_AUTHORIZATION='token {}'.format(self.token))
+        self.assertEqual(response.status_code, 201)
+        url = "/v1/apps/{app_id}/config".format(**locals())
+        with self.settings(SECRETS_KEY='old secret'):
+            body = {'secrets': json.dumps({'DATABASE_PASSWORD': 'hunter2'})}
+            response = self.client.post(url, json.dumps(body), content_type='application/json',
+                                        HTTP_AUTHORIZATION='token {}'.format(self.token))
+            self.assertEqual(response.status_code, 201)
+        with self.settings(SECRETS_KEY='new secret'):
+            body = {'secrets': json.dumps({'API_TOKEN': 's3cr3t'})}
+            response = self.client.post(url, json.dumps(body), content_type='application/json',
+                                        HTTP_AUTHORIZATION='token {}'.format(self.token))
+            self.assertEqual(response.status_code, 201)
+            config = Config.objects.get(uuid=response.data['uuid'])
+            self.assertEqual(config.secret_environment(), {'API_TOKEN': 's3cr3t'})
+        container = App.objects.get(id=app_id).container_set.get()
+        self.assertEqual(mock_scheduler.jobs[container.job_id]['env'], {'API_TOKEN': 's3cr3t'})
+
+    @mock.patch('requests.post', mock_status_ok)
+    def test_config_secrets_require_key(self):
+        """
+        Test that secrets can't be set until the platform has a secrets key
+        """
+        url = '/v1/apps'
+        response = self.client.post(url, HTTP_AUTHORIZATION='token {}'.format(self.token))
+        self.assertEqual(response.status_code, 201)
+        app_id = response.data['id']
+        url = "/v1/apps/{app_id}/config".format(**locals())
+        body = {'secrets': json.dumps({'DATABASE_PASSWORD': 'hunter2'})}
+        response = self.client.post(url, json.dumps(body), content_type='application/json',
+                                    HTTP_AUTHORIZATION='token {}'.format(self.token))
+        self.assertEqual(response.status_code, 400)
+        self.assertIn('secretsKey', response.data['secrets'][0])
+
     @mock.patch('requests.post', mock_status_ok)
     def test_config_str(self):
         """Test the text representation of a node."""
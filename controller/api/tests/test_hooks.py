@@ -15,6 +15,9 @@ from django.contrib.auth.models import User
 from django.test import TransactionTestCase
 from rest_framework.authtoken.models import Token
 
+from api.models import App
+from scheduler import mock as mock_scheduler
+
 
 def mock_import_repository_task(*args, **kwargs):
     resp = requests.Response()
@@ -207,6 +210,43 @@ class HookTest(TransactionTestCase):
         self.assertEqual(container['type'], 'cmd')
         self.assertEqual(container['num'], 1)
 
+    @mock.patch('api.models.publish_release')
+    def test_build_hook_image_digest(self, mock_publish_release):
+        """Test that a build pushed with a digest is pulled by that digest"""
+        url = '/v1/apps'
+        response = self.client.post(url, HTTP_AUTHORIZATION='token {}'.format(self.token))
+        self.assertEqual(response.status_code, 201)
+        app_id = response.data['id']
+        url = '/v1/hooks/builds'.format(**locals())
+        SHA = 'ecdff91c57a0b9ab82e89634df87e293d259a3aa'
+        DIGEST = 'sha256:8a1f6d4e3c2b9a0f7e6d5c4b3a29180f7e6d5c4b3a29180f7e6d5c4b3a291807'
+        body = {'receive_user': 'autotest',
+                'receive_repo': app_id,
+                'image': '{app_id}:v2'.format(**locals()),
+                'sha': SHA,
+                'procfile': {'web': 'node server.js'},
+                'image_digest': DIGEST}
+        response = self.client.post(url, json.dumps(body), content_type='application/json',
+                                    HTTP_X_DEIS_BUILDER_AUTH=settings.BUILDER_KEY)
+        self.assertEqual(response.status_code, 200)
+        url = '/v1/apps/{app_id}/builds'.format(**locals())
+        response = self.client.get(url, HTTP_AUTHORIZATION='token {}'.format(self.token))
+        self.assertEqual(response.status_code, 200)
+        self.assertEqual(response.data['results'][0]['image_digest'], DIGEST)
+        # the image pulled by digest lacks the release's config, so the scheduler sets it
+        url = '/v1/apps/{app_id}/config'.format(**locals())
+        body = {'values': json.dumps({'PORT': '5000'})}
+        response = self.client.post(url, json.dumps(body), content_type='application/json',
+                                    HTTP_AUTHORIZATION='token {}'.format(self.token))
+        self.assertEqual(response.status_code, 201)
+        container = App.objects.get(id=app_id).container_set.get()
+        job = mock_scheduler.jobs[container.job_id]
+        self.assertEqual(job['image'], '{app_id}@{DIGEST}'.format(**locals()))
+        self.assertEqual(job['env'], {'PORT': '5000', 'DEIS_APP': app_id, 'DEIS_RELEASE': 'v3'})
+        # the release image is still published by tag
+        _, _, target = mock_publish_release.call_args[0]
+        self.assertEqual(target, '{app_id}:v3'.format(**locals()))
+
     def test_config_hook(self):
         """Test reading Config via an API Hook"""
         url = '/v1/apps'
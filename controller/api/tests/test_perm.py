@@ -1,11 +1,26 @@
 
 from __future__ import unicode_literals
 import json
+import mock
 
 from django.contrib.auth.models import User
 from django.test import TestCase
 from rest_framework.authtoken.models import Token
 
+from api.models import App, publish_builder_perms
+
+
+class RecordingEtcdClient(object):
+
+    def __init__(self):
+        self.keys = {}
+
+    def write(self, key, value, **kwargs):
+        self.keys[key] = value
+
+    def delete(self, key, **kwargs):
+        self.keys.pop(key, None)
+
 
 class TestAdminPerms(TestCase):
 
@@ -188,6 +203,28 @@ class TestAppPerms(TestCase):
             self.assertEqual(len(response.data['results']), 0)
         # TODO:  check that user 2 can git push the app
 
+    def test_publish_builder_perms(self):
+        """
+        Test that owners, collaborators and administrators of existing apps are
+        published for the builder
+        """
+        response = self.client.get('/v1/apps', HTTP_AUTHORIZATION='token {}'.format(self.token))
+        app_id = response.data['results'][0]['id']
+        url = "/v1/apps/{}/perms".format(app_id)
+        body = {'username': 'autotest-3'}
+        response = self.client.post(url, json.dumps(body), content_type='application/json',
+                                    HTTP_AUTHORIZATION='token {}'.format(self.token))
+        self.assertEqual(response.status_code, 201)
+        etcd = RecordingEtcdClient()
+        with mock.patch('api.models._etcd_client', etcd):
+            publish_builder_perms()
+        for app in App.objects.all():
+            key = '/deis/builder/apps/{}/{}'.format(app.id, app.owner.username)
+            self.assertEqual(etcd.keys.get(key), app.owner.username)
+        self.assertIn('/deis/builder/apps/{}/autotest-3'.format(app_id), etcd.keys)
+        for user in User.objects.filter(is_superuser=True):
+            self.assertIn('/deis/builder/admins/{}'.format(user.username), etcd.keys)
+
     def test_create_errors(self):
         # check that user 1 sees her lone app
         response = self.client.get('/v1/apps', HTTP_AUTHORIZATION='token {}'.format(self.token))
@@ -3,8 +3,16 @@ Helper functions used by the Deis server.
 """
 import base64
 import hashlib
+import hmac
 import random
 
+from Crypto import Random
+from Crypto.Cipher import AES
+
+
+# prefix of an encrypted secret, naming the format it is encrypted with
+SECRET_PREFIX = 'v1:'
+
 
 def generate_app_name():
     """Return a randomly-generated memorable name."""
@@ -108,6 +116,68 @@ def fingerprint(key):
     return ':'.join(a + b for a, b in zip(fp_plain[::2], fp_plain[1::2]))
 
 
+def _secret_keys(key):
+    """
+    Return the encryption and authentication keys derived from a secrets key.
+    """
+    key = encode(key)
+    return (hashlib.sha256(b'deis-secret-encryption:' + key).digest(),
+            hashlib.sha256(b'deis-secret-authentication:' + key).digest())
+
+
+def encrypt_secret(value, key):
+    """
+    Encrypt a config value with the platform's secrets key.
+
+    The value is encrypted with AES-256 in CBC mode and authenticated with an
+    HMAC-SHA256 of the ciphertext.
+
+    >>> token = encrypt_secret(u'hunter2', 'key')
+    >>> token.startswith(SECRET_PREFIX)
+    True
+    >>> decrypt_secret(token, 'key')
+    u'hunter2'
+    """
+    if not key:
+        raise ValueError('secrets key is not set')
+    enc_key, mac_key = _secret_keys(key)
+    data = encode(value)
+    padding = AES.block_size - len(data) % AES.block_size
+    data += chr(padding) * padding
+    iv = Random.new().read(AES.block_size)
+    ciphertext = iv + AES.new(enc_key, AES.MODE_CBC, iv).encrypt(data)
+    mac = hmac.new(mac_key, ciphertext, hashlib.sha256).digest()
+    return SECRET_PREFIX + base64.b64encode(ciphertext + mac)
+
+
+def decrypt_secret(token, key):
+    """
+    Decrypt a config value encrypted by :func:`encrypt_secret`.
+
+    Raises ValueError if the value was encrypted with another key or was
+    tampered with.
+
+    >>> decrypt_secret(encrypt_secret(u'hunter2', 'key'), 'other key')
+    Traceback (most recent call last):
+    ...
+    ValueError: secret was not encrypted with this secrets key
+    """
+    if not key:
+        raise ValueError('secrets key is not set')
+    if not token.startswith(SECRET_PREFIX):
+        raise ValueError('unknown secret format')
+    enc_key, mac_key = _secret_keys(key)
+    data = base64.b64decode(token[len(SECRET_PREFIX):])
+    mac_size = hashlib.sha256().digest_size
+    ciphertext, mac = data[:-mac_size], data[-mac_size:]
+    expected = hmac.new(mac_key, ciphertext, hashlib.sha256).digest()
+    if len(ciphertext) < 2 * AES.block_size or not hmac.compare_digest(mac, expected):
+        raise ValueError('secret was not encrypted with this secrets key')
+    iv = ciphertext[:AES.block_size]
+    data = AES.new(enc_key, AES.MODE_CBC, iv).decrypt(ciphertext[AES.block_size:])
+    return data[:-ord(data[-1])].decode('utf-8')
+
+
 def encode(obj):
     """Return UTF-8 encoding for string objects."""
     if isinstance(obj, basestring):
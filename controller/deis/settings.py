@@ -301,6 +301,8 @@ SCHEDULER_OPTIONS = {}
 SSH_PRIVATE_KEY = ''  # used for SSH connections to facilitate "deis run"
 SECRET_KEY = os.environ.get('DEIS_SECRET_KEY', 'CHANGEME_sapm$s%upvsw5l_zuy_&29rkywd^78ff(qi')
 BUILDER_KEY = os.environ.get('DEIS_BUILDER_KEY', 'CHANGEME_sapm$s%upvsw5l_zuy_&29rkywd^78ff(qi')
+# encrypts app config secrets; they can't be set until it is
+SECRETS_KEY = os.environ.get('DEIS_SECRETS_KEY', '')
 
 # registry settings
 REGISTRY_MODULE = 'registry.mock'
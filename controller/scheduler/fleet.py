@@ -145,9 +145,17 @@ class FleetHTTPClient(AbstractSchedulerClient):
         # encode command as utf-8
         if isinstance(l.get('command'), basestring):
             l['command'] = l['command'].encode('utf-8')
+        # name the variables only, so docker reads their values from the unit's
+        # environment rather than its command line. Note that fleet stores the
+        # unit, decrypted secrets included, unencrypted in etcd.
+        env = kwargs.get('env', {})
+        l.update({'env': ' '.join('-e {}'.format(k) for k in sorted(env))})
         # construct unit from template
         for f in unit:
             f['value'] = f['value'].format(**l)
+        for k in sorted(env):
+            unit.append({"section": "Service", "name": "Environment",
+                         "value": _unit_environment(k, env[k])})
         # prepare tags only if one was provided
         tags = kwargs.get('tags', {})
         tagset = ' '.join(['"{}={}"'.format(k, v) for k, v in tags.viewitems()])
@@ -232,10 +240,10 @@ class FleetHTTPClient(AbstractSchedulerClient):
                 if attempt == (RETRIES - 1):  # account for 0 indexing
                     raise
 
-    def run(self, name, image, entrypoint, command):  # noqa
+    def run(self, name, image, entrypoint, command, **kwargs):  # noqa
         """Run a one-off command."""
         self._create_container(name, image, command, copy.deepcopy(RUN_TEMPLATE),
-                               entrypoint=entrypoint)
+                               entrypoint=entrypoint, env=kwargs.get('env', {}))
         # launch the container
         self._put_unit(name, {'desiredState': 'launched'})
         # wait for the container to get scheduled
@@ -367,11 +375,18 @@ class FleetHTTPClient(AbstractSchedulerClient):
 SchedulerClient = FleetHTTPClient
 
 
+def _unit_environment(key, value):
+    """Return the value of a systemd Environment= option that sets key to value."""
+    for old, new in [('\\', '\\\\'), ('"', '\\"'), ('\n', '\\n'), ('%', '%%')]:
+        value = value.replace(old, new)
+    return u'"{}={}"'.format(key, value)
+
+
 CONTAINER_TEMPLATE = [
     {"section": "Unit", "name": "Description", "value": "{name}"},
     {"section": "Service", "name": "ExecStartPre", "value": '''/bin/sh -c "IMAGE=$(etcdctl get /deis/registry/host 2>&1):$(etcdctl get /deis/registry/port 2>&1)/{image}; docker pull $IMAGE"'''},  # noqa
     {"section": "Service", "name": "ExecStartPre", "value": '''/bin/sh -c "docker inspect {name} >/dev/null 2>&1 && docker rm -f {name} || true"'''},  # noqa
-    {"section": "Service", "name": "ExecStart", "value": '''/bin/sh -c "IMAGE=$(etcdctl get /deis/registry/host 2>&1):$(etcdctl get /deis/registry/port 2>&1)/{image}; docker run --name {name} --rm {memory} {cpu} {hostname} -P $IMAGE {command}"'''},  # noqa
+    {"section": "Service", "name": "ExecStart", "value": '''/bin/sh -c "IMAGE=$(etcdctl get /deis/registry/host 2>&1):$(etcdctl get /deis/registry/port 2>&1)/{image}; docker run --name {name} --rm {memory} {cpu} {hostname} {env} -P $IMAGE {command}"'''},  # noqa
     {"section": "Service", "name": "ExecStop", "value": '''/usr/bin/docker stop {name}'''},
     {"section": "Service", "name": "TimeoutStartSec", "value": "20m"},
     {"section": "Service", "name": "TimeoutStopSec", "value": "10"},
@@ -384,6 +399,6 @@ RUN_TEMPLATE = [
     {"section": "Unit", "name": "Description", "value": "{name} admin command"},
     {"section": "Service", "name": "ExecStartPre", "value": '''/bin/sh -c "IMAGE=$(etcdctl get /deis/registry/host 2>&1):$(etcdctl get /deis/registry/port 2>&1)/{image}; docker pull $IMAGE"'''},  # noqa
     {"section": "Service", "name": "ExecStartPre", "value": '''/bin/sh -c "docker inspect {name} >/dev/null 2>&1 && docker rm -f {name} || true"'''},  # noqa
-    {"section": "Service", "name": "ExecStart", "value": '''/bin/sh -c "IMAGE=$(etcdctl get /deis/registry/host 2>&1):$(etcdctl get /deis/registry/port 2>&1)/{image}; docker run --name {name} --entrypoint={entrypoint} -a stdout -a stderr $IMAGE {command}"'''},  # noqa
+    {"section": "Service", "name": "ExecStart", "value": '''/bin/sh -c "IMAGE=$(etcdctl get /deis/registry/host 2>&1):$(etcdctl get /deis/registry/port 2>&1)/{image}; docker run --name {name} --entrypoint={entrypoint} {env} -a stdout -a stderr $IMAGE {command}"'''},  # noqa
     {"section": "Service", "name": "TimeoutStartSec", "value": "20m"},
 ]
@@ -307,6 +307,7 @@ class KubeHTTPClient(AbstractSchedulerClient):
         js_template = json.loads(template)
         containers = js_template["spec"]["template"]["spec"]["containers"]
         containers[0]['args'] = args
+        containers[0]['env'] = _environment(kwargs.get('env', {}))
         loc = locals().copy()
         loc.update(re.match(MATCH, container_fullname).groupdict())
         mem = kwargs.get('memory', {}).get(loc['c_type'])
@@ -594,7 +595,7 @@ class KubeHTTPClient(AbstractSchedulerClient):
                 log_data += data
         return log_data
 
-    def run(self, name, image, entrypoint, command):
+    def run(self, name, image, entrypoint, command, **kwargs):
         """Run a one-off command."""
         appname = name.split("_")[0]
         name = name.replace(".", "-")
@@ -612,6 +613,7 @@ class KubeHTTPClient(AbstractSchedulerClient):
         js_template = json.loads(template)
         js_template['spec']['containers'][0]['command'] = [entrypoint]
         js_template['spec']['containers'][0]['args'] = args
+        js_template['spec']['containers'][0]['env'] = _environment(kwargs.get('env', {}))
 
         con_dest = httplib.HTTPConnection(self.target+":"+self.port)
         headers = {'Content-Type': 'application/json'}
@@ -683,4 +685,9 @@ class KubeHTTPClient(AbstractSchedulerClient):
         except RuntimeError:
             return JobState.destroyed
 
+
+def _environment(env):
+    """Return the environment of a container as a list of name/value pairs."""
+    return [{'name': k, 'value': v} for k, v in sorted(env.viewitems())]
+
 SchedulerClient = KubeHTTPClient
@@ -50,8 +50,13 @@ class MarathonHTTPClient(AbstractSchedulerClient):
         cpu = kwargs.get('cpu', {}).get(l['c_type'])
         if cpu:
             c = cpu
-        cmd = "docker run --name {name} -P {image} {command}".format(**locals())
-        self.client.create_app(app_id, MarathonApp(cmd=cmd, mem=m, cpus=c, instances=0))
+        # name the variables only, so docker reads their values from the task's
+        # environment rather than its command line
+        env = kwargs.get('env', {})
+        envs = ' '.join('-e {}'.format(k) for k in sorted(env))
+        cmd = "docker run --name {name} {envs} -P {image} {command}".format(**locals())
+        self.client.create_app(app_id, MarathonApp(cmd=cmd, env=env, mem=m, cpus=c,
+                                                   instances=0))
         for _ in xrange(POLL_ATTEMPTS):
             if self.client.get_app(self._app_id(name)).tasks_running == 0:
                 return
@@ -96,9 +101,9 @@ class MarathonHTTPClient(AbstractSchedulerClient):
         if docker_cli.inspect_container(name)['State']:
             docker_cli.remove_container(name, force=True)
 
-    def run(self, name, image, entrypoint, command):  # noqa
+    def run(self, name, image, entrypoint, command, **kwargs):  # noqa
         """Run a one-off command."""
-        return self.fleet.run(name, image, entrypoint, command)
+        return self.fleet.run(name, image, entrypoint, command, **kwargs)
 
     def state(self, name):
         """Display the given job's running state."""
@@ -34,11 +34,12 @@ class SwarmClient(AbstractSchedulerClient):
             if mem[-2:-1].isalpha() and mem[-1].isalpha():
                 mem = mem[:-1]
         cpu = kwargs.get('cpu', {}).get(l['c_type'])
+        env = _environment(kwargs.get('env', {}))
         self.docker_cli.create_container(image=cimage, name=name,
                                          command=command.encode('utf-8'),
                                          mem_limit=mem,
                                          cpu_shares=cpu,
-                                         environment=[affinity],
+                                         environment=[affinity] + env,
                                          host_config={'PublishAllPorts': True})
 
     def start(self, name):
@@ -54,14 +55,15 @@ class SwarmClient(AbstractSchedulerClient):
         self.stop(name)
         self.docker_cli.remove_container(name)
 
-    def run(self, name, image, entrypoint, command):
+    def run(self, name, image, entrypoint, command, **kwargs):
         """Run a one-off command."""
         cimage = self.registry + '/' + image
         # use affinity for nodes that already have the image
         affinity = "affinity:image==~{}".format(cimage)
+        env = _environment(kwargs.get('env', {}))
         self.docker_cli.create_container(image=cimage, name=name,
                                          command=command.encode('utf-8'),
-                                         environment=[affinity],
+                                         environment=[affinity] + env,
                                          entrypoint=[entrypoint])
         time.sleep(2)
         self.start(name)
@@ -121,4 +123,9 @@ class SwarmClient(AbstractSchedulerClient):
         dictports = self.docker_cli.inspect_image(image)['ContainerConfig']['ExposedPorts']
         return [int(port.split('/')[0]) for port in dictports]
 
+
+def _environment(env):
+    """Return the environment of a container as a list of KEY=value strings."""
+    return [u'{}={}'.format(k, v) for k, v in sorted(env.viewitems())]
+
 SchedulerClient = SwarmClient
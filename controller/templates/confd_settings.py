@@ -1,6 +1,7 @@
 # security keys and auth tokens
 SECRET_KEY = '{{ getv "/deis/controller/secretKey" }}'
 BUILDER_KEY = '{{ getv "/deis/controller/builderKey" }}'
+SECRETS_KEY = '{{ if exists "/deis/platform/secretsKey" }}{{ getv "/deis/platform/secretsKey" }}{{ end }}'
 
 # scheduler settings
 SCHEDULER_MODULE = 'scheduler.{{ if exists "/deis/controller/schedulerModule" }}{{ getv "/deis/controller/schedulerModule" }}{{ else }}fleet{{ end }}'
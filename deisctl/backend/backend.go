@@ -5,13 +5,17 @@ import (
 	"sync"
 )
 
-// Backend interface is used to interact with the cluster control plane
+// Backend interface is used to interact with the cluster control plane.
+//
+// Methods that take an output and an error writer report failures by writing
+// them to the error writer.
 type Backend interface {
 	Create([]string, *sync.WaitGroup, io.Writer, io.Writer)
 	Destroy([]string, *sync.WaitGroup, io.Writer, io.Writer)
 	Start([]string, *sync.WaitGroup, io.Writer, io.Writer)
 	Stop([]string, *sync.WaitGroup, io.Writer, io.Writer)
 	Scale(string, int, *sync.WaitGroup, io.Writer, io.Writer)
+	Scalable(string) (bool, error)
 	RollingRestart(string, *sync.WaitGroup, io.Writer, io.Writer)
 	SSH(string) error
 	SSHExec(string, string) error
@@ -20,4 +24,25 @@ type Backend interface {
 	ListUnitFiles() error
 	Status(string) error
 	Journal(string) error
+	UnitStates() ([]*UnitState, error)
+	Machines() ([]*Machine, error)
+	SSHOutput(string, string) (string, error)
+}
+
+// UnitState describes an installed unit and where it is running.
+type UnitState struct {
+	Name         string `json:"name"`
+	DesiredState string `json:"desired,omitempty"`
+	MachineID    string `json:"machine_id,omitempty"`
+	MachineIP    string `json:"machine_ip,omitempty"`
+	LoadState    string `json:"load"`
+	ActiveState  string `json:"active"`
+	SubState     string `json:"sub"`
+	Hash         string `json:"hash,omitempty"`
+}
+
+// Machine is a host in the cluster.
+type Machine struct {
+	ID string `json:"id"`
+	IP string `json:"ip"`
 }
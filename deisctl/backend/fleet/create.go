@@ -11,7 +11,9 @@ import (
 	"github.com/coreos/fleet/job"
 	"github.com/coreos/fleet/schema"
 	"github.com/coreos/fleet/unit"
+	sdunit "github.com/coreos/go-systemd/unit"
 
+	"github.com/deis/deis/deisctl/config"
 	"github.com/deis/deis/pkg/prettyprint"
 )
 
@@ -105,9 +107,28 @@ func (c *FleetClient) createServiceUnit(component string, num int) (name string,
 	if err != nil {
 		return "", nil, err
 	}
-	uf, err = NewUnit(component, c.templatePaths, decorate)
+	// explicit placement for the component takes the place of its decorator
+	placement, err := c.configBackend.GetWithDefault(config.PlacementKey(component), "")
+	if err != nil {
+		return "", nil, err
+	}
+	uf, err = NewUnit(component, c.templatePaths, decorate && placement == "")
 	if err != nil {
 		return
 	}
+	if placement != "" {
+		uf = placeUnit(uf, strings.Split(placement, ","))
+	}
 	return name, uf, nil
 }
+
+// placeUnit returns uf constrained to machines with all of the given metadata.
+func placeUnit(uf *unit.UnitFile, metadata []string) *unit.UnitFile {
+	opts := uf.Options
+	for _, m := range metadata {
+		if m = strings.TrimSpace(m); m != "" {
+			opts = append(opts, &sdunit.UnitOption{Section: "X-Fleet", Name: "MachineMetadata", Value: m})
+		}
+	}
+	return unit.NewUnitFromOptions(opts)
+}
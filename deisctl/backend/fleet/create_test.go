@@ -3,6 +3,7 @@ package fleet
 import (
 	"io/ioutil"
 	"path"
+	"reflect"
 	"sync"
 	"testing"
 
@@ -71,3 +72,29 @@ func TestCreate(t *testing.T) {
 		}
 	}
 }
+
+func TestCreatePlacement(t *testing.T) {
+	t.Parallel()
+
+	name, err := ioutil.TempDir("", "deisctl-fleetctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.WriteFile(path.Join(name, "deis-router.service"), []byte("[Unit]\nDescription=router"), 777)
+
+	testConfigBackend := mock.ConfigBackend{Expected: []*model.ConfigNode{
+		{Key: "/deis/platform/placement/router", Value: "routerMesh=true, region=east"},
+	}}
+	c := &FleetClient{templatePaths: []string{name}, configBackend: testConfigBackend}
+
+	_, uf, err := c.createUnitFile("router@1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"routerMesh=true", "region=east"}
+	actual := uf.Contents["X-Fleet"]["MachineMetadata"]
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v, Got %v", expected, actual)
+	}
+}
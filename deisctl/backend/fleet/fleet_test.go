@@ -103,7 +103,8 @@ func (c *stubFleetClient) SetUnitTargetState(name, target string) error {
 		}
 	}
 
-	c.testUnitStates = append(c.testUnitStates, &schema.UnitState{Name: name, SystemdSubState: subState, SystemdActiveState: activeState})
+	c.testUnitStates = append(c.testUnitStates, &schema.UnitState{Name: name, MachineID: unit.MachineID,
+		SystemdSubState: subState, SystemdActiveState: activeState})
 
 	return nil
 }
@@ -125,6 +126,14 @@ func (c *stubFleetClient) DestroyUnit(name string) error {
 	}
 	c.unitsMutex.Unlock()
 
+	c.unitStatesMutex.Lock()
+	for i := len(c.testUnitStates) - 1; i >= 0; i-- {
+		if c.testUnitStates[i].Name == name {
+			c.testUnitStates = append(c.testUnitStates[:i], c.testUnitStates[i+1:]...)
+		}
+	}
+	c.unitStatesMutex.Unlock()
+
 	return nil
 }
 
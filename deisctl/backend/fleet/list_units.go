@@ -6,6 +6,7 @@ import (
 
 	"github.com/coreos/fleet/machine"
 	"github.com/coreos/fleet/schema"
+	"github.com/deis/deis/deisctl/backend"
 	"github.com/deis/deis/deisctl/units"
 )
 
@@ -74,11 +75,8 @@ func (c *FleetClient) ListUnits() (err error) {
 	}
 
 	for _, us := range unitStates {
-		for _, prefix := range units.Names {
-			if strings.HasPrefix(us.Name, prefix) {
-				states = append(states, us)
-				break
-			}
+		if isDeisUnit(us.Name) {
+			states = append(states, us)
 		}
 	}
 	c.printUnits(states)
@@ -114,3 +112,55 @@ func machineFullLegend(ms machine.MachineState, full bool) string {
 	}
 	return legend
 }
+
+// UnitStates returns the state of all Deis-related units, including those
+// that have been created but not yet scheduled to a machine.
+func (c *FleetClient) UnitStates() ([]*backend.UnitState, error) {
+	allUnits, err := c.Fleet.Units()
+	if err != nil {
+		return nil, err
+	}
+	unitStates, err := c.Fleet.UnitStates()
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]*schema.UnitState, len(unitStates))
+	for _, us := range unitStates {
+		byName[us.Name] = us
+	}
+
+	var states []*backend.UnitState
+	for _, u := range allUnits {
+		if !isDeisUnit(u.Name) {
+			continue
+		}
+		state := &backend.UnitState{Name: u.Name, DesiredState: u.DesiredState, MachineID: u.MachineID}
+		if us, ok := byName[u.Name]; ok {
+			state.MachineID = us.MachineID
+			state.LoadState = us.SystemdLoadState
+			state.ActiveState = us.SystemdActiveState
+			state.SubState = us.SystemdSubState
+			state.Hash = us.Hash
+		}
+		if state.Hash == "" && len(u.Options) > 0 {
+			state.Hash = schema.MapSchemaUnitOptionsToUnitFile(u.Options).Hash().String()
+		}
+		if state.MachineID != "" {
+			if ms := c.cachedMachineState(state.MachineID); ms != nil {
+				state.MachineIP = ms.PublicIP
+			}
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+// isDeisUnit returns true if name is the name of a Deis unit.
+func isDeisUnit(name string) bool {
+	for _, prefix := range units.Names {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -2,12 +2,14 @@ package fleet
 
 import (
 	"bytes"
+	"reflect"
 	"sync"
 	"testing"
 	"text/tabwriter"
 
 	"github.com/coreos/fleet/machine"
 	"github.com/coreos/fleet/schema"
+	"github.com/deis/deis/deisctl/backend"
 )
 
 func TestListUnits(t *testing.T) {
@@ -72,3 +74,43 @@ deis-router@1.service	654321.../2.2.2.2	loaded	active	running
 		t.Errorf("Expected '%s', Got '%s'", expected, actual)
 	}
 }
+
+func TestUnitStates(t *testing.T) {
+	t.Parallel()
+
+	testUnits := []*schema.Unit{
+		&schema.Unit{Name: "deis-controller.service", DesiredState: "launched", MachineID: "123456"},
+		&schema.Unit{Name: "deis-router@1.service"},
+		&schema.Unit{Name: "myapp_v2.web.1.service"},
+	}
+	testUnitStates := []*schema.UnitState{
+		&schema.UnitState{
+			Name:               "deis-controller.service",
+			MachineID:          "123456",
+			SystemdLoadState:   "loaded",
+			SystemdActiveState: "active",
+			SystemdSubState:    "running",
+			Hash:               "abcd",
+		},
+	}
+	testMachines := []machine.MachineState{
+		machine.MachineState{ID: "123456", PublicIP: "1.1.1.1"},
+	}
+
+	c := &FleetClient{Fleet: &stubFleetClient{testUnits: testUnits, testUnitStates: testUnitStates,
+		testMachineStates: testMachines, unitsMutex: &sync.Mutex{}, unitStatesMutex: &sync.Mutex{}}}
+
+	states, err := c.UnitStates()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []*backend.UnitState{
+		&backend.UnitState{Name: "deis-controller.service", DesiredState: "launched", MachineID: "123456", MachineIP: "1.1.1.1",
+			LoadState: "loaded", ActiveState: "active", SubState: "running", Hash: "abcd"},
+		&backend.UnitState{Name: "deis-router@1.service"},
+	}
+	if !reflect.DeepEqual(states, expected) {
+		t.Errorf("Expected %v, Got %v", expected, states)
+	}
+}
@@ -3,35 +3,179 @@ package fleet
 import (
 	"fmt"
 	"io"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 )
 
-// RollingRestart for instance units
+// RestartTimeout is how long a rolling restart waits for each restarted unit
+// to become healthy before it gives up.
+var RestartTimeout = 5 * time.Minute
+
+// restartPollInterval is how often a rolling restart checks a restarted unit.
+var restartPollInterval = time.Second
+
+// publicationKeys are the etcd keys that units of a component publish while
+// they are healthy, formatted with the IP of the machine they run on.
+var publicationKeys = map[string]string{
+	"router":        "/deis/router/hosts/%s",
+	"registry":      "/deis/registry/hosts/%s/host",
+	"store-gateway": "/deis/store/gateway/hosts/%s/host",
+}
+
+// RollingRestart restarts the units of a scalable component one at a time.
+//
+// Each unit is recreated and started, and the next unit is not touched until
+// it is running and has republished itself to etcd. If a unit does not become
+// healthy within RestartTimeout, or a step of restarting it fails, the rolling
+// restart is aborted.
 func (c *FleetClient) RollingRestart(component string, wg *sync.WaitGroup, out, ew io.Writer) {
-	if component != "router" {
-		fmt.Fprint(ew, "invalid component. supported for: router")
-		return
-	}
+	component = strings.TrimSuffix(strings.TrimPrefix(component, "deis-"), "@*")
 
-	components, err := c.Units(component)
+	allUnits, err := c.Fleet.Units()
 	if err != nil {
 		io.WriteString(ew, err.Error())
 		return
 	}
-	if len(components) < 1 {
-		fmt.Fprint(ew, "rolling restart requires at least 1 component")
+	var nums []int
+	for _, unit := range allUnits {
+		name, num, err := splitJobName(unit.Name)
+		if err != nil || name != component {
+			continue
+		}
+		nums = append(nums, num)
+	}
+	if len(nums) < 1 {
+		fmt.Fprintf(ew, "rolling restart requires at least 1 unit of a scalable component, found none for %s\n", component)
 		return
 	}
-	for num := range components {
-		unitName := fmt.Sprintf("%s@%v", component, num+1)
+	sort.Ints(nums)
 
-		c.Stop([]string{unitName}, wg, out, ew)
-		wg.Wait()
-		c.Destroy([]string{unitName}, wg, out, ew)
-		wg.Wait()
-		c.Create([]string{unitName}, wg, out, ew)
-		wg.Wait()
-		c.Start([]string{unitName}, wg, out, ew)
+	// the stop, destroy, create and start steps report failures by writing
+	// to ew, so any write means the unit was not restarted
+	failures := &countingWriter{w: ew}
+	steps := []struct {
+		name string
+		fn   func([]string, *sync.WaitGroup, io.Writer, io.Writer)
+	}{
+		{"destroyed", c.Destroy},
+		{"created", c.Create},
+		{"started", c.Start},
+	}
+
+	for _, num := range nums {
+		unitName := fmt.Sprintf("%s@%v", component, num)
+
+		ip := c.unitMachineIP(unitName)
+		c.Stop([]string{unitName}, wg, out, failures)
 		wg.Wait()
+		if failures.Count() > 0 {
+			fmt.Fprintf(ew, "Aborting rolling restart: %s could not be stopped\n", unitName)
+			return
+		}
+		// the stale key would otherwise mask the new unit failing to publish
+		if key := publicationKey(component, ip); key != "" && c.configBackend != nil {
+			c.configBackend.Delete(key)
+		}
+		for _, step := range steps {
+			step.fn([]string{unitName}, wg, out, failures)
+			wg.Wait()
+			if failures.Count() > 0 {
+				fmt.Fprintf(ew, "Aborting rolling restart: %s could not be %s\n", unitName, step.name)
+				return
+			}
+		}
+
+		if err := c.waitForHealthy(component, unitName, RestartTimeout, out); err != nil {
+			fmt.Fprintf(ew, "Aborting rolling restart: %v\n", err)
+			return
+		}
+	}
+}
+
+// countingWriter counts the writes passed on to w.
+type countingWriter struct {
+	mutex sync.Mutex
+	w     io.Writer
+	count int
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.count++
+	return c.w.Write(p)
+}
+
+// Count returns the number of writes so far.
+func (c *countingWriter) Count() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.count
+}
+
+func publicationKey(component, ip string) string {
+	format, ok := publicationKeys[component]
+	if !ok || ip == "" {
+		return ""
+	}
+	return fmt.Sprintf(format, ip)
+}
+
+// unitMachineIP returns the IP of the machine running a unit, if it is known.
+func (c *FleetClient) unitMachineIP(target string) string {
+	states, err := c.UnitStates()
+	if err != nil {
+		return ""
+	}
+	for _, s := range states {
+		if s.Name == "deis-"+target+".service" {
+			return s.MachineIP
+		}
+	}
+	return ""
+}
+
+// waitForHealthy waits until a unit is active/running and, for components
+// that publish themselves, until its publication key is back in etcd.
+func (c *FleetClient) waitForHealthy(component, target string, timeout time.Duration, out io.Writer) error {
+	name := "deis-" + target + ".service"
+	deadline := time.Now().Add(timeout)
+	running := false
+	for {
+		states, err := c.UnitStates()
+		if err != nil {
+			return err
+		}
+		var active, sub, ip string
+		for _, s := range states {
+			if s.Name == name {
+				active, sub, ip = s.ActiveState, s.SubState, s.MachineIP
+			}
+		}
+		if sub == "failed" {
+			return fmt.Errorf("%s failed while starting", name)
+		}
+
+		if active == "active" && sub == "running" {
+			running = true
+			key := publicationKey(component, ip)
+			if key == "" || c.configBackend == nil {
+				return nil
+			}
+			if _, err := c.configBackend.Get(key); err == nil {
+				fmt.Fprintf(out, "%s: published %s\n", name, key)
+				return nil
+			}
+		}
+
+		if time.Now().After(deadline) {
+			if running {
+				return fmt.Errorf("%s did not publish itself to etcd within %v", name, timeout)
+			}
+			return fmt.Errorf("%s is %s/%s after %v", name, active, sub, timeout)
+		}
+		time.Sleep(restartPollInterval)
 	}
 }
@@ -0,0 +1,169 @@
+package fleet
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deis/deis/deisctl/config/model"
+	"github.com/deis/deis/deisctl/test/mock"
+
+	"github.com/coreos/fleet/machine"
+	"github.com/coreos/fleet/schema"
+)
+
+// schedulingFleetClient schedules the units it creates onto a machine.
+type schedulingFleetClient struct {
+	*stubFleetClient
+	machines map[string]string
+	// failStart makes launching units fail
+	failStart bool
+}
+
+func (c *schedulingFleetClient) SetUnitTargetState(name, target string) error {
+	if c.failStart && target == "launched" {
+		return errors.New("failed to launch " + name)
+	}
+	return c.stubFleetClient.SetUnitTargetState(name, target)
+}
+
+func (c *schedulingFleetClient) CreateUnit(unit *schema.Unit) error {
+	unit.MachineID = c.machines[unit.Name]
+	return c.stubFleetClient.CreateUnit(unit)
+}
+
+func newRollingRestartClient(t *testing.T, fc *stubFleetClient) (*FleetClient, string) {
+	name, err := ioutil.TempDir("", "deisctl-fleetctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.WriteFile(path.Join(name, "deis-registry.service"), []byte("[Unit]"), 777)
+
+	fc.testUnits = []*schema.Unit{
+		{Name: "deis-registry@1.service", DesiredState: "launched", MachineID: "m1"},
+		{Name: "deis-registry@2.service", DesiredState: "launched", MachineID: "m2"},
+		{Name: "deis-registry-data.service", DesiredState: "launched", MachineID: "m1"},
+	}
+	fc.testUnitStates = []*schema.UnitState{
+		{Name: "deis-registry@1.service", MachineID: "m1", SystemdActiveState: "active", SystemdSubState: "running"},
+		{Name: "deis-registry@2.service", MachineID: "m2", SystemdActiveState: "active", SystemdSubState: "running"},
+	}
+	fc.testMachineStates = []machine.MachineState{{ID: "m1", PublicIP: "10.0.0.1"}, {ID: "m2", PublicIP: "10.0.0.2"}}
+	fc.unitsMutex = &sync.Mutex{}
+	fc.unitStatesMutex = &sync.Mutex{}
+
+	cb := mock.ConfigBackend{Expected: []*model.ConfigNode{
+		{Key: "/deis/registry/hosts/10.0.0.1/host", Value: "10.0.0.1"},
+		{Key: "/deis/registry/hosts/10.0.0.2/host", Value: "10.0.0.2"},
+	}}
+	sc := &schedulingFleetClient{stubFleetClient: fc, machines: map[string]string{"deis-registry@1.service": "m1", "deis-registry@2.service": "m2"}}
+	return &FleetClient{templatePaths: []string{name}, Fleet: sc, configBackend: cb}, name
+}
+
+func TestRollingRestart(t *testing.T) {
+	t.Parallel()
+
+	fc := &stubFleetClient{}
+	c, dir := newRollingRestartClient(t, fc)
+	defer os.RemoveAll(dir)
+
+	var wg sync.WaitGroup
+	se := newOutErr()
+	c.RollingRestart("registry", &wg, se.out, se.ew)
+
+	if se.ew.String() != "" {
+		t.Fatal(se.ew.String())
+	}
+	for _, expected := range []string{
+		"deis-registry@1.service: published /deis/registry/hosts/10.0.0.1/host",
+		"deis-registry@2.service: published /deis/registry/hosts/10.0.0.2/host",
+	} {
+		if !strings.Contains(se.out.String(), expected) {
+			t.Errorf("Expected output to contain %q, Got %q", expected, se.out.String())
+		}
+	}
+}
+
+func TestRollingRestartAborts(t *testing.T) {
+	RestartTimeout = time.Millisecond
+	defer func() { RestartTimeout = 5 * time.Minute }()
+
+	fc := &stubFleetClient{}
+	c, dir := newRollingRestartClient(t, fc)
+	defer os.RemoveAll(dir)
+	// registry@1 never publishes itself
+	c.configBackend = mock.ConfigBackend{}
+
+	var wg sync.WaitGroup
+	se := newOutErr()
+	c.RollingRestart("registry", &wg, se.out, se.ew)
+
+	expected := "Aborting rolling restart: deis-registry@1.service did not publish itself to etcd within 1ms\n"
+	if se.ew.String() != expected {
+		t.Errorf("Expected %q, Got %q", expected, se.ew.String())
+	}
+	for _, u := range fc.testUnits {
+		if u.Name == "deis-registry@2.service" && u.DesiredState != "launched" {
+			t.Errorf("Expected %s to be left alone, Got %s", u.Name, u.DesiredState)
+		}
+	}
+}
+
+func TestRollingRestartStartFails(t *testing.T) {
+	t.Parallel()
+
+	fc := &stubFleetClient{}
+	c, dir := newRollingRestartClient(t, fc)
+	defer os.RemoveAll(dir)
+	c.Fleet.(*schedulingFleetClient).failStart = true
+
+	var wg sync.WaitGroup
+	se := newOutErr()
+	c.RollingRestart("registry", &wg, se.out, se.ew)
+
+	expected := "Aborting rolling restart: registry@1 could not be started\n"
+	if !strings.HasSuffix(se.ew.String(), expected) {
+		t.Errorf("Expected %q, Got %q", expected, se.ew.String())
+	}
+	if strings.Contains(se.out.String(), "deis-registry@2.service") {
+		t.Errorf("Expected registry@2 to be left alone, Got %q", se.out.String())
+	}
+}
+
+func TestRollingRestartNotScalable(t *testing.T) {
+	t.Parallel()
+
+	fc := &stubFleetClient{}
+	c, dir := newRollingRestartClient(t, fc)
+	defer os.RemoveAll(dir)
+
+	var wg sync.WaitGroup
+	se := newOutErr()
+	c.RollingRestart("registry-data", &wg, se.out, se.ew)
+
+	expected := "rolling restart requires at least 1 unit of a scalable component, found none for registry-data\n"
+	if se.ew.String() != expected {
+		t.Errorf("Expected %q, Got %q", expected, se.ew.String())
+	}
+}
+
+func TestWaitForHealthyTimeout(t *testing.T) {
+	t.Parallel()
+
+	fc := &stubFleetClient{}
+	c, dir := newRollingRestartClient(t, fc)
+	defer os.RemoveAll(dir)
+	c.configBackend = mock.ConfigBackend{}
+
+	err := c.waitForHealthy("registry", "registry@1", time.Millisecond, ioutil.Discard)
+
+	expected := "deis-registry@1.service did not publish itself to etcd within 1ms"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected %q, Got %v", expected, err)
+	}
+}
@@ -3,10 +3,11 @@ package fleet
 import (
 	"fmt"
 	"io"
-	"math"
+	"sort"
 	"strconv"
-	"strings"
 	"sync"
+
+	"github.com/coreos/fleet/unit"
 )
 
 // Scale creates or destroys units to match the desired number
@@ -18,43 +19,85 @@ func (c *FleetClient) Scale(
 		return
 	}
 	// check how many currently exist
-	components, err := c.Units(component)
+	existing, err := c.unitNums(component)
 	if err != nil {
-		// skip checking the first time; we just want a tally
-		if !strings.Contains(err.Error(), "could not find unit") {
-			fmt.Fprintln(ew, err.Error())
-			return
-		}
+		fmt.Fprintln(ew, err.Error())
+		return
 	}
 
-	timesToScale := int(math.Abs(float64(requested - len(components))))
 	switch {
-	case timesToScale == 0:
+	case requested == len(existing):
 		return
-	case requested-len(components) > 0:
-		c.scaleUp(component, len(components), timesToScale, wg, out, ew)
+	case requested > len(existing):
+		c.scaleUp(component, existing, requested-len(existing), wg, out, ew)
 	default:
-		c.scaleDown(component, len(components), timesToScale, wg, out, ew)
+		c.scaleDown(component, existing, len(existing)-requested, wg, out, ew)
 	}
 }
 
-func (c *FleetClient) scaleUp(component string, numExistingContainers, numTimesToScale int,
+// Scalable returns true if the component's unit template declares that it
+// can run more than one unit, with:
+//
+//	[X-Deis]
+//	Scalable=true
+func (c *FleetClient) Scalable(component string) (bool, error) {
+	template, err := readTemplate(component, c.templatePaths)
+	if err != nil {
+		return false, err
+	}
+	uf, err := unit.NewUnitFile(string(template))
+	if err != nil {
+		return false, err
+	}
+	values := uf.Contents["X-Deis"]["Scalable"]
+	return len(values) > 0 && values[len(values)-1] == "true", nil
+}
+
+// unitNums returns the numbers of a component's units, in ascending order.
+func (c *FleetClient) unitNums(component string) ([]int, error) {
+	allUnits, err := c.Fleet.Units()
+	if err != nil {
+		return nil, err
+	}
+	var nums []int
+	for _, u := range allUnits {
+		name, num, err := splitJobName(u.Name)
+		if err != nil || name != component {
+			continue
+		}
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	return nums, nil
+}
+
+// scaleUp creates and starts units, filling any gaps in the unit numbers first.
+func (c *FleetClient) scaleUp(component string, existing []int, numTimesToScale int,
 	wg *sync.WaitGroup, out, ew io.Writer) {
-	for i := 0; i < numTimesToScale; i++ {
-		target := component + "@" + strconv.Itoa(numExistingContainers+i+1)
-		c.Create([]string{target}, wg, out, ew)
+	taken := make(map[int]bool, len(existing))
+	for _, num := range existing {
+		taken[num] = true
 	}
-	wg.Wait()
-	for i := 0; i < numTimesToScale; i++ {
-		target := component + "@" + strconv.Itoa(numExistingContainers+i+1)
-		c.Start([]string{target}, wg, out, ew)
+	var targets []string
+	for num := 1; len(targets) < numTimesToScale; num++ {
+		if !taken[num] {
+			targets = append(targets, component+"@"+strconv.Itoa(num))
+		}
 	}
+	c.Create(targets, wg, out, ew)
+	wg.Wait()
+	c.Start(targets, wg, out, ew)
 }
 
-func (c *FleetClient) scaleDown(component string, numExistingContainers, numTimesToScale int,
+// scaleDown stops and then destroys the highest-numbered units.
+func (c *FleetClient) scaleDown(component string, existing []int, numTimesToScale int,
 	wg *sync.WaitGroup, out, ew io.Writer) {
+	var targets []string
 	for i := 0; i < numTimesToScale; i++ {
-		target := component + "@" + strconv.Itoa(numExistingContainers-i)
-		c.Destroy([]string{target}, wg, out, ew)
+		targets = append(targets, component+"@"+strconv.Itoa(existing[len(existing)-1-i]))
 	}
+	// stop the units first, so they can shut down gracefully
+	c.Stop(targets, wg, out, ew)
+	wg.Wait()
+	c.Destroy(targets, wg, out, ew)
 }
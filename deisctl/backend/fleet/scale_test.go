@@ -153,3 +153,62 @@ func TestScaleError(t *testing.T) {
 	}
 	logMutex.Unlock()
 }
+
+func TestScaleDownStopsUnits(t *testing.T) {
+	t.Parallel()
+
+	testUnits := []*schema.Unit{
+		&schema.Unit{Name: "deis-registry@1.service", DesiredState: "launched"},
+		&schema.Unit{Name: "deis-registry@3.service", DesiredState: "launched"},
+	}
+	testUnitStates := []*schema.UnitState{
+		&schema.UnitState{Name: "deis-registry@1.service", SystemdActiveState: "active", SystemdSubState: "running"},
+		&schema.UnitState{Name: "deis-registry@3.service", SystemdActiveState: "active", SystemdSubState: "running"},
+	}
+	testFleetClient := stubFleetClient{testUnits: testUnits, testUnitStates: testUnitStates,
+		unitsMutex: &sync.Mutex{}, unitStatesMutex: &sync.Mutex{}}
+
+	c := &FleetClient{Fleet: &testFleetClient}
+
+	var wg sync.WaitGroup
+	se := newOutErr()
+	c.Scale("registry", 1, &wg, se.out, se.ew)
+	wg.Wait()
+
+	if se.ew.String() != "" {
+		t.Fatal(se.ew.String())
+	}
+	out := se.out.String()
+	if stopped := strings.Index(out, "inactive/dead"); stopped < 0 || stopped > strings.Index(out, "destroyed") {
+		t.Errorf("Expected deis-registry@3.service to be stopped before it was destroyed, Got %q", out)
+	}
+	if len(testFleetClient.testUnits) != 1 || testFleetClient.testUnits[0].Name != "deis-registry@1.service" {
+		t.Errorf("Expected only deis-registry@1.service to remain, Got %v", testFleetClient.testUnits)
+	}
+}
+
+func TestScalable(t *testing.T) {
+	t.Parallel()
+
+	name, err := ioutil.TempDir("", "deisctl-fleetctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.WriteFile(path.Join(name, "deis-router.service"), []byte("[Unit]\n\n[X-Deis]\nScalable=true\n"), 777)
+	ioutil.WriteFile(path.Join(name, "deis-controller.service"), []byte("[Unit]"), 777)
+
+	c := &FleetClient{templatePaths: []string{name}}
+
+	for component, expected := range map[string]bool{"router": true, "controller": false} {
+		scalable, err := c.Scalable(component)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if scalable != expected {
+			t.Errorf("Expected %s scalable to be %v, Got %v", component, expected, scalable)
+		}
+	}
+	if _, err := c.Scalable("missing"); err == nil {
+		t.Error("Expected an error for a component without a unit file")
+	}
+}
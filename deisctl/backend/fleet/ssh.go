@@ -8,6 +8,8 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/deis/deis/deisctl/backend"
+
 	"github.com/coreos/fleet/machine"
 	"github.com/coreos/fleet/ssh"
 )
@@ -37,6 +39,38 @@ func (c *FleetClient) SSHExec(name, cmd string) error {
 	return err
 }
 
+// SSHOutput runs a command on a machine in the cluster, given a unit or
+// machine id, and returns what it wrote to stdout.
+func (c *FleetClient) SSHOutput(name, cmd string) (string, error) {
+	conn, _, err := c.sshConnect(name)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	session, err := conn.NewSession()
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+
+	out, err := session.Output(cmd)
+	return string(out), err
+}
+
+// Machines returns the machines in the cluster.
+func (c *FleetClient) Machines() ([]*backend.Machine, error) {
+	states, err := c.Fleet.Machines()
+	if err != nil {
+		return nil, err
+	}
+	machines := make([]*backend.Machine, len(states))
+	for i, ms := range states {
+		machines[i] = &backend.Machine{ID: ms.ID, IP: ms.PublicIP}
+	}
+	return machines, nil
+}
+
 func (c *FleetClient) sshConnect(name string) (*ssh.SSHForwardingClient, *machine.MachineState, error) {
 
 	timeout := time.Duration(Flags.SSHTimeout*1000) * time.Millisecond
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/deis/deis/deisctl/backend"
 	"github.com/deis/deis/deisctl/backend/fleet"
@@ -18,10 +19,13 @@ import (
 
 // DeisCtlClient manages Deis components, configuration, and related tasks.
 type DeisCtlClient interface {
+	Apply(argv []string) error
 	Config(argv []string) error
+	Doctor(argv []string) error
 	Install(argv []string) error
 	Journal(argv []string) error
 	List(argv []string) error
+	ListUnitFiles(argv []string) error
 	RefreshUnits(argv []string) error
 	Restart(argv []string) error
 	Scale(argv []string) error
@@ -101,17 +105,67 @@ Usage:
 func (c *Client) RollingRestart(argv []string) error {
 	usage := `Perform a rolling restart of an instance unit.
 
+Units of a scalable component, such as router, registry or store-gateway, are
+restarted one at a time. Each restarted unit must be running and have published
+itself to etcd before the next one is restarted; if it does not within the
+timeout, the rolling restart is aborted.
+
 Usage:
-  deisctl rolling-restart <target>
+  deisctl rolling-restart <target> [options]
+
+Options:
+  --timeout=<seconds>   how long to wait for each unit to become healthy [default: 300]
 `
 	args, err := docopt.Parse(usage, argv, true, "", false)
 	if err != nil {
 		return err
 	}
 
+	timeout, err := strconv.Atoi(args["--timeout"].(string))
+	if err != nil || timeout < 1 {
+		return fmt.Errorf("invalid timeout: %s", args["--timeout"])
+	}
+	fleet.RestartTimeout = time.Duration(timeout) * time.Second
+
 	return cmd.RollingRestart(args["<target>"].(string), c.Backend)
 }
 
+// Apply converges the platform on a manifest describing its components and config.
+func (c *Client) Apply(argv []string) error {
+	usage := `Converges the platform on a manifest describing its components and config.
+
+Components missing from the cluster are installed and started, components
+that are not in the manifest are uninstalled, and config keys are set to the
+values given. Components are started in the order they are listed.
+
+Usage:
+  deisctl apply -f <file> [options]
+
+Options:
+  -f --file=<file>  manifest to apply
+  --dry-run         print the actions needed without taking them
+
+Example manifest:
+  config:
+    platform:
+      domain: example.com
+  components:
+    - name: logger
+    - name: logspout
+    - name: controller
+    - name: router
+      count: 3
+      placement: ["routerMesh=true"]
+`
+	// parse command-line arguments
+	args, err := docopt.Parse(usage, argv, true, "", false)
+	if err != nil {
+		return err
+	}
+
+	return cmd.Apply(args["--file"].(string), args["--dry-run"].(bool), c.Backend, c.configBackend, cmd.CheckRequiredKeys)
+}
+
 // Config gets or sets a configuration value from the cluster.
 //
 // A configuration value is stored and retrieved from a key/value store (in this case, etcd)
@@ -128,16 +182,42 @@ enabling TLS for the routers.
 Note: "deisctl config platform set sshPrivateKey=" expects a path
 to a private key.
 
+The previous values of keys changed by set, rm and import are kept, and
+"rollback" restores the value a key had before its last change.
+
+Keys and values are checked against the keys the components read before
+they are set. "describe" lists the keys of a target, and --force sets keys
+that aren't listed, or values that don't look valid.
+
+"export" writes the config of the given targets, or of every target that
+"describe" knows, as YAML that "import" reads back. Only the keys "describe"
+lists are exported. Import prints the keys it changes and asks before
+setting them, unless --yes is given.
+
 Usage:
+  deisctl config export [<target>...]
+  deisctl config import <file> [--dry-run | --yes]
   deisctl config <target> get [<key>...]
-  deisctl config <target> set <key=val>...
+  deisctl config <target> set <key=val>... [--force]
   deisctl config <target> rm [<key>...]
+  deisctl config <target> history [<key>...]
+  deisctl config <target> rollback <key>...
+  deisctl config <target> describe
+
+Options:
+  --dry-run  print the keys that would change without setting them
+  --yes      set the keys without asking for confirmation
+  --force    set keys without checking them against the schema
 
 Examples:
   deisctl config platform set domain=mydomain.com
   deisctl config platform set sshPrivateKey=$HOME/.ssh/deis
   deisctl config controller get webEnabled
   deisctl config controller rm webEnabled
+  deisctl config controller rollback webEnabled
+  deisctl config router describe
+  deisctl config export > platform.yaml
+  deisctl config import platform.yaml
 `
 	// parse command-line arguments
 	args, err := docopt.Parse(usage, argv, true, "", false)
@@ -149,18 +229,30 @@ Examples:
 	var key []string
 
 	switch {
+	case args["export"] == true:
+		return cmd.ConfigExport(args["<target>"].([]string), c.configBackend)
+	case args["import"] == true:
+		return cmd.ConfigImport(args["<file>"].(string), args["--dry-run"].(bool), args["--yes"].(bool), c.configBackend)
 	case args["set"] == true:
 		action = "set"
 		key = args["<key=val>"].([]string)
 	case args["rm"] == true:
 		action = "rm"
 		key = args["<key>"].([]string)
+	case args["history"] == true:
+		action = "history"
+		key = args["<key>"].([]string)
+	case args["rollback"] == true:
+		action = "rollback"
+		key = args["<key>"].([]string)
+	case args["describe"] == true:
+		action = "describe"
 	default:
 		action = "get"
 		key = args["<key>"].([]string)
 	}
 
-	return cmd.Config(args["<target>"].(string), action, key, c.configBackend)
+	return cmd.Config(args["<target>"].([]string)[0], action, key, args["--force"].(bool), c.configBackend)
 }
 
 // Install loads the definitions of components from local unit files.
@@ -220,12 +312,53 @@ func (c *Client) List(argv []string) error {
 
 Usage:
   deisctl list [options]
+
+Options:
+  -o --output=<format>  print units as text or json [default: text]
+`
+	// parse command-line arguments
+	args, err := docopt.Parse(usage, argv, true, "", false)
+	if err != nil {
+		return err
+	}
+	return cmd.ListUnits(c.Backend, args["--output"].(string))
+}
+
+// Doctor checks the health of the platform and prints a report.
+func (c *Client) Doctor(argv []string) error {
+	usage := `Checks the health of the platform and prints a report.
+
+Checks that the platform's units are running, that its components have
+published themselves to etcd, that required configuration is set, and that
+etcd is healthy and clocks agree on every machine. Each warning or failure is
+printed with a hint on how to fix it.
+
+Usage:
+  deisctl doctor [options]
 `
 	// parse command-line arguments
 	if _, err := docopt.Parse(usage, argv, true, "", false); err != nil {
 		return err
 	}
-	return cmd.ListUnits(c.Backend)
+	return cmd.Doctor(c.Backend, c.configBackend)
+}
+
+// ListUnitFiles prints the unit files loaded into the cluster.
+func (c *Client) ListUnitFiles(argv []string) error {
+	usage := `Prints the unit files loaded into the cluster, including units not yet scheduled.
+
+Usage:
+  deisctl list-unit-files [options]
+
+Options:
+  -o --output=<format>  print unit files as text or json [default: text]
+`
+	// parse command-line arguments
+	args, err := docopt.Parse(usage, argv, true, "", false)
+	if err != nil {
+		return err
+	}
+	return cmd.ListUnitFiles(c.Backend, args["--output"].(string))
 }
 
 // RefreshUnits overwrites local unit files with those requested.
@@ -278,10 +411,16 @@ Usage:
 func (c *Client) Scale(argv []string) error {
 	usage := `Grows or shrinks the number of running components.
 
-Currently "router", "registry" and "store-gateway" are the only types that can be scaled.
+Any component whose unit file declares it scalable can be scaled, such as
+"router", "registry" and "store-gateway". Units removed when scaling down are
+stopped before they are destroyed.
 
 Usage:
   deisctl scale [<target>...] [options]
+  deisctl scale --status
+
+Options:
+  --status    show how many units of each scaled component should be running, and how many are
 `
 	// parse command-line arguments
 	args, err := docopt.Parse(usage, argv, true, "", false)
@@ -289,6 +428,9 @@ Usage:
 		return err
 	}
 
+	if args["--status"].(bool) {
+		return cmd.ScaleStatus(c.Backend)
+	}
 	return cmd.Scale(args["<target>"].([]string), c.Backend)
 }
 
@@ -371,8 +513,14 @@ Usage:
 func (c *Client) Status(argv []string) error {
 	usage := `Prints the current status of components.
 
+With --output json, the fleet state of the components' units is printed instead
+of their systemd status.
+
 Usage:
   deisctl status [<target>...] [options]
+
+Options:
+  -o --output=<format>  print status as text or json [default: text]
 `
 	// parse command-line arguments
 	args, err := docopt.Parse(usage, argv, true, "", false)
@@ -380,7 +528,7 @@ Usage:
 		return err
 	}
 
-	return cmd.Status(args["<target>"].([]string), c.Backend)
+	return cmd.Status(args["<target>"].([]string), args["--output"].(string), c.Backend)
 }
 
 // Stop deactivates the specified components.
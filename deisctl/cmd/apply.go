@@ -0,0 +1,400 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/deis/deis/deisctl/backend"
+	"github.com/deis/deis/deisctl/config"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Manifest describes the desired state of the platform.
+type Manifest struct {
+	// Config maps a config target, such as "platform" or "router", to the
+	// keys to set under /deis/<target>/.
+	Config map[string]map[string]interface{} `yaml:"config"`
+	// Components are started in dependency order, and stopped in reverse.
+	Components []ManifestComponent `yaml:"components"`
+}
+
+// ManifestComponent describes how a component should be installed.
+type ManifestComponent struct {
+	Name string `yaml:"name"`
+	// Count is the number of units of a scalable component. It defaults to 1.
+	Count int `yaml:"count"`
+	// Placement is the machine metadata the component's units must run on.
+	Placement []string `yaml:"placement"`
+	// State is either "started", the default, or "installed".
+	State string `yaml:"state"`
+
+	// scalable components have numbered units, such as "router@1".
+	scalable bool
+}
+
+// Action is one step towards the state described by a Manifest.
+type Action struct {
+	// Op is one of "set", "rm", "destroy", "create", "start" or "stop".
+	Op string
+	// Target is a config key for "set" and "rm", and a unit target such as
+	// "router@1" otherwise.
+	Target string
+	// Detail explains the action.
+	Detail string
+}
+
+func (a Action) String() string {
+	s := fmt.Sprintf("%-7s %s", a.Op, a.Target)
+	if a.Detail != "" {
+		s += " (" + a.Detail + ")"
+	}
+	return s
+}
+
+// Plan is the list of actions, in order, that converge the platform on a Manifest.
+type Plan []Action
+
+var manifestName = regexp.MustCompile(`^[a-z-]+$`)
+
+// ReadManifest reads and validates a manifest file.
+func ReadManifest(path string) (*Manifest, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseManifest(data)
+}
+
+func parseManifest(data []byte) (*Manifest, error) {
+	m := new(Manifest)
+	if err := yaml.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for i := range m.Components {
+		c := &m.Components[i]
+		c.Name = strings.TrimPrefix(c.Name, "deis-")
+		if !manifestName.MatchString(c.Name) {
+			return nil, fmt.Errorf("invalid component name %q", c.Name)
+		}
+		if seen[c.Name] {
+			return nil, fmt.Errorf("component %s is listed more than once", c.Name)
+		}
+		seen[c.Name] = true
+		switch c.State {
+		case "":
+			c.State = "started"
+		case "started", "installed":
+		default:
+			return nil, fmt.Errorf("%s: state must be started or installed, not %q", c.Name, c.State)
+		}
+		if c.Count < 0 {
+			return nil, fmt.Errorf("%s: count cannot be negative", c.Name)
+		}
+		if c.Count == 0 {
+			c.Count = 1
+		}
+	}
+	return m, nil
+}
+
+// resolveScalable asks the backend which of the manifest's components are
+// scalable, and checks that only those have more than one unit.
+func (m *Manifest) resolveScalable(b backend.Backend) error {
+	for i := range m.Components {
+		c := &m.Components[i]
+		scalable, err := b.Scalable(c.Name)
+		if err != nil {
+			return err
+		}
+		if c.Count > 1 && !scalable {
+			return fmt.Errorf("%s: cannot run more than one unit of this component", c.Name)
+		}
+		c.scalable = scalable
+	}
+	return nil
+}
+
+// targets returns the unit targets a component should have, such as "router@1".
+func (c ManifestComponent) targets() []string {
+	if !c.scalable {
+		return []string{c.Name}
+	}
+	targets := make([]string, c.Count)
+	for i := range targets {
+		targets[i] = c.Name + "@" + strconv.Itoa(i+1)
+	}
+	return targets
+}
+
+var unitNameRegexp = regexp.MustCompile(`^deis-([a-z-]+)(@\d+)?\.service$`)
+
+// unitTarget returns the target and component of a unit name, such as
+// "router@1" and "router" for "deis-router@1.service".
+func unitTarget(name string) (target, component string) {
+	match := unitNameRegexp.FindStringSubmatch(name)
+	if match == nil {
+		return "", ""
+	}
+	return match[1] + match[2], match[1]
+}
+
+// PlanApply compares a Manifest to the platform's units and config, and
+// returns the actions needed to converge them.
+func PlanApply(m *Manifest, states []*backend.UnitState, cb config.Backend) (Plan, error) {
+	var plan Plan
+
+	// config comes first, so that units start with it in place
+	targets := make([]string, 0, len(m.Config))
+	for target := range m.Config {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+	for _, target := range targets {
+		keys := make([]string, 0, len(m.Config[target]))
+		for k := range m.Config[target] {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			path := "/deis/" + target + "/" + k
+			want, err := config.Value(path, fmt.Sprint(m.Config[target][k]))
+			if err != nil {
+				return nil, err
+			}
+			if have, err := cb.Get(path); err != nil || have != want {
+				plan = append(plan, Action{Op: "set", Target: path})
+			}
+		}
+	}
+
+	existing := make(map[string]*backend.UnitState)
+	byComponent := make(map[string][]string)
+	for _, s := range states {
+		target, component := unitTarget(s.Name)
+		if target == "" {
+			continue
+		}
+		existing[target] = s
+		byComponent[component] = append(byComponent[component], target)
+	}
+
+	// units are created and started in dependency order
+	names := make([]string, len(m.Components))
+	components := make(map[string]ManifestComponent)
+	for i, c := range m.Components {
+		names[i] = c.Name
+		components[c.Name] = c
+	}
+
+	var destroys, creates, starts, stops []Action
+	wanted := make(map[string]bool)
+	for _, n := range componentGraph.subgraph(names) {
+		c := components[n.Name]
+		placement := strings.Join(c.Placement, ",")
+		havePlacement, err := cb.GetWithDefault(config.PlacementKey(c.Name), "")
+		if err != nil {
+			return nil, err
+		}
+		replace := placement != havePlacement
+		if replace {
+			if placement == "" {
+				plan = append(plan, Action{Op: "rm", Target: config.PlacementKey(c.Name)})
+			} else {
+				plan = append(plan, Action{Op: "set", Target: config.PlacementKey(c.Name), Detail: placement})
+			}
+		}
+
+		for _, target := range c.targets() {
+			wanted[target] = true
+			s, ok := existing[target]
+			switch {
+			case !ok:
+				creates = append(creates, Action{Op: "create", Target: target})
+			case replace:
+				destroys = append(destroys, Action{Op: "destroy", Target: target, Detail: "placement changed"})
+				creates = append(creates, Action{Op: "create", Target: target, Detail: "placement changed"})
+			case c.State == "started" && s.SubState != "running":
+				starts = append(starts, Action{Op: "start", Target: target})
+			case c.State == "installed" && s.ActiveState == "active":
+				stops = append(stops, Action{Op: "stop", Target: target})
+			}
+			if c.State == "started" && (!ok || replace) {
+				starts = append(starts, Action{Op: "start", Target: target})
+			}
+		}
+	}
+
+	// units not in the manifest are destroyed before anything else changes,
+	// highest-numbered units first
+	var unwanted []string
+	for target := range existing {
+		if !wanted[target] {
+			unwanted = append(unwanted, target)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(unwanted)))
+	for _, target := range unwanted {
+		plan = append(plan, Action{Op: "destroy", Target: target, Detail: "not in manifest"})
+	}
+	// the rest are destroyed in reverse order, like a platform stop
+	for i := len(destroys) - 1; i >= 0; i-- {
+		plan = append(plan, destroys[i])
+	}
+	plan = append(plan, creates...)
+	plan = append(plan, starts...)
+	for i := len(stops) - 1; i >= 0; i-- {
+		plan = append(plan, stops[i])
+	}
+	return plan, nil
+}
+
+// Apply converges the platform on the manifest at path.
+//
+// With dryRun, the actions that would be taken are printed and nothing is changed.
+func Apply(path string, dryRun bool, b backend.Backend, cb config.Backend, checkKeys func(config.Backend) error) error {
+	m, err := ReadManifest(path)
+	if err != nil {
+		return err
+	}
+	if err := m.resolveScalable(b); err != nil {
+		return err
+	}
+	states, err := b.UnitStates()
+	if err != nil {
+		return err
+	}
+	plan, err := PlanApply(m, states, cb)
+	if err != nil {
+		return err
+	}
+
+	if len(plan) == 0 {
+		fmt.Fprintln(Stdout, "Platform matches the manifest, nothing to do.")
+		return nil
+	}
+	if dryRun {
+		for _, a := range plan {
+			fmt.Fprintln(Stdout, a)
+		}
+		return nil
+	}
+	return executePlan(plan, m, b, cb, checkKeys)
+}
+
+func executePlan(plan Plan, m *Manifest, b backend.Backend, cb config.Backend, checkKeys func(config.Backend) error) error {
+	values := make(map[string]string)
+	for target, kvs := range m.Config {
+		for k, v := range kvs {
+			values["/deis/"+target+"/"+k] = fmt.Sprint(v)
+		}
+	}
+	for _, c := range m.Components {
+		values[config.PlacementKey(c.Name)] = strings.Join(c.Placement, ",")
+	}
+
+	checked := false
+	for i := 0; i < len(plan); {
+		a := plan[i]
+		switch a.Op {
+		case "set":
+			val, err := config.Value(a.Target, values[a.Target])
+			if err != nil {
+				return err
+			}
+			if _, err := config.Set(cb, a.Target, val); err != nil {
+				return err
+			}
+			fmt.Fprintln(Stdout, a)
+			i++
+			continue
+		case "rm":
+			if err := config.Delete(cb, a.Target); err != nil {
+				return err
+			}
+			fmt.Fprintln(Stdout, a)
+			i++
+			continue
+		}
+
+		if !checked {
+			if err := checkKeys(cb); err != nil {
+				return err
+			}
+			checked = true
+		}
+
+		var err error
+		switch a.Op {
+		case "create", "start":
+			// units are created and started in dependency order, with
+			// independent components in parallel
+			targets := make(map[string][]string)
+			var components []string
+			for ; i < len(plan) && plan[i].Op == a.Op; i++ {
+				_, c := unitTarget("deis-" + plan[i].Target + ".service")
+				if targets[c] == nil {
+					components = append(components, c)
+				}
+				targets[c] = append(targets[c], plan[i].Target)
+			}
+			err = componentGraph.subgraph(components).walk(false, func(n node) error {
+				return applyBatch(a.Op, targets[n.Name], b)
+			})
+		default:
+			// units are stopped and destroyed a component at a time
+			_, component := unitTarget("deis-" + a.Target + ".service")
+			var batch []string
+			for ; i < len(plan) && plan[i].Op == a.Op; i++ {
+				if _, c := unitTarget("deis-" + plan[i].Target + ".service"); c != component {
+					break
+				}
+				batch = append(batch, plan[i].Target)
+			}
+			err = applyBatch(a.Op, batch, b)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Fprintln(Stdout, "Done.")
+	return nil
+}
+
+// applyBatch acts on the units of one component, and returns the errors the
+// backend reports.
+func applyBatch(op string, batch []string, b backend.Backend) error {
+	var wg sync.WaitGroup
+	e := newErrorWriter(Stderr)
+	switch op {
+	case "destroy":
+		// units are stopped first, as a scale down does
+		b.Stop(batch, &wg, Stdout, e)
+		wg.Wait()
+		if err := e.Err(); err != nil {
+			return err
+		}
+		b.Destroy(batch, &wg, Stdout, e)
+	case "create":
+		b.Create(batch, &wg, Stdout, e)
+	case "start":
+		b.Start(batch, &wg, Stdout, e)
+	case "stop":
+		b.Stop(batch, &wg, Stdout, e)
+	}
+	wg.Wait()
+	if err := e.Err(); err != nil {
+		return err
+	}
+	if op == "start" {
+		_, component := unitTarget("deis-" + batch[0] + ".service")
+		return checkStarted(b, component)
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/deis/deis/deisctl/backend"
+	"github.com/deis/deis/deisctl/config/model"
+	"github.com/deis/deis/deisctl/test/mock"
+)
+
+const testManifest = `
+config:
+  platform:
+    domain: example.com
+components:
+  - name: logger
+  - name: controller
+  - name: router
+    count: 2
+  - name: builder
+    state: installed
+`
+
+func TestParseManifest(t *testing.T) {
+	t.Parallel()
+
+	m, err := parseManifest([]byte(testManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []ManifestComponent{
+		{Name: "logger", Count: 1, State: "started"},
+		{Name: "controller", Count: 1, State: "started"},
+		{Name: "router", Count: 2, State: "started"},
+		{Name: "builder", Count: 1, State: "installed"},
+	}
+	if !reflect.DeepEqual(m.Components, expected) {
+		t.Errorf("Expected %v, Got %v", expected, m.Components)
+	}
+}
+
+func TestParseManifestBad(t *testing.T) {
+	t.Parallel()
+
+	bad := []string{
+		"components:\n  - name: router\n  - name: router\n",
+		"components:\n  - name: router\n    state: paused\n",
+		"components:\n  - name: Router!\n",
+	}
+	for _, manifest := range bad {
+		if _, err := parseManifest([]byte(manifest)); err == nil {
+			t.Errorf("Expected an error parsing %q", manifest)
+		}
+	}
+}
+
+func TestResolveScalable(t *testing.T) {
+	t.Parallel()
+
+	m, err := parseManifest([]byte("components:\n  - name: controller\n    count: 2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "controller: cannot run more than one unit of this component"
+	if err := m.resolveScalable(&backendStub{}); err == nil || err.Error() != expected {
+		t.Errorf("Expected %q, Got %v", expected, err)
+	}
+}
+
+func TestPlanApply(t *testing.T) {
+	t.Parallel()
+
+	m, err := parseManifest([]byte(testManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.resolveScalable(&backendStub{}); err != nil {
+		t.Fatal(err)
+	}
+	states := []*backend.UnitState{
+		{Name: "deis-logger.service", ActiveState: "active", SubState: "running"},
+		{Name: "deis-controller.service", ActiveState: "inactive", SubState: "dead"},
+		{Name: "deis-router@1.service", ActiveState: "active", SubState: "running"},
+		{Name: "deis-builder.service", ActiveState: "active", SubState: "running"},
+		{Name: "deis-cache.service", ActiveState: "active", SubState: "running"},
+	}
+	cb := mock.ConfigBackend{Expected: []*model.ConfigNode{{Key: "/deis/platform/domain", Value: "example.com"}}}
+
+	plan, err := PlanApply(m, states, cb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Plan{
+		{Op: "destroy", Target: "cache", Detail: "not in manifest"},
+		{Op: "create", Target: "router@2"},
+		{Op: "start", Target: "controller"},
+		{Op: "start", Target: "router@2"},
+		{Op: "stop", Target: "builder"},
+	}
+	if !reflect.DeepEqual(plan, expected) {
+		t.Errorf("Expected %v, Got %v", expected, plan)
+	}
+}
+
+func TestApplyDryRun(t *testing.T) {
+	f, err := ioutil.TempFile("", "deisctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("components:\n  - name: router\n    count: 2\n")
+	f.Close()
+
+	var out bytes.Buffer
+	Stdout = &out
+	defer func() { Stdout = os.Stdout }()
+
+	b := &backendStub{}
+	if err := Apply(f.Name(), true, b, mock.ConfigBackend{}, fakeCheckKeys); err != nil {
+		t.Fatal(err)
+	}
+	if b.installedUnits != nil || b.startedUnits != nil {
+		t.Errorf("Expected a dry run to change nothing, installed %v and started %v", b.installedUnits, b.startedUnits)
+	}
+	expected := "create  router@1\ncreate  router@2\nstart   router@1\nstart   router@2\n"
+	if out.String() != expected {
+		t.Errorf("Expected %q, Got %q", expected, out.String())
+	}
+}
+
+func TestApply(t *testing.T) {
+	f, err := ioutil.TempFile("", "deisctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("components:\n  - name: logger\n  - name: router\n    count: 2\n")
+	f.Close()
+
+	var out bytes.Buffer
+	Stdout = &out
+	defer func() { Stdout = os.Stdout }()
+
+	b := &backendStub{
+		states: []*backend.UnitState{
+			{Name: "deis-router@1.service", ActiveState: "active", SubState: "running"},
+			{Name: "deis-router@3.service", ActiveState: "active", SubState: "running"},
+		},
+		sshOutput: func(machine, command string) (string, error) { return "OK", nil },
+	}
+	if err := Apply(f.Name(), false, b, mock.ConfigBackend{}, fakeCheckKeys); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"router@3"}; !reflect.DeepEqual(b.stoppedUnits, expected) {
+		t.Errorf("Expected %v stopped before being destroyed, Got %v", expected, b.stoppedUnits)
+	}
+	if expected := []string{"router@3"}; !reflect.DeepEqual(b.uninstalledUnits, expected) {
+		t.Errorf("Expected %v, Got %v", expected, b.uninstalledUnits)
+	}
+	// independent components are created and started in parallel
+	sort.Strings(b.installedUnits)
+	if expected := []string{"logger", "router@2"}; !reflect.DeepEqual(b.installedUnits, expected) {
+		t.Errorf("Expected %v, Got %v", expected, b.installedUnits)
+	}
+	sort.Strings(b.startedUnits)
+	if expected := []string{"logger", "router@2"}; !reflect.DeepEqual(b.startedUnits, expected) {
+		t.Errorf("Expected %v, Got %v", expected, b.startedUnits)
+	}
+}
+
+func TestApplyDependencyOrder(t *testing.T) {
+	f, err := ioutil.TempFile("", "deisctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("components:\n  - name: builder\n  - name: controller\n  - name: database\n")
+	f.Close()
+
+	var out bytes.Buffer
+	Stdout = &out
+	defer func() { Stdout = os.Stdout }()
+
+	b := &backendStub{}
+	if err := Apply(f.Name(), false, b, mock.ConfigBackend{}, fakeCheckKeys); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"database", "controller", "builder"}; !reflect.DeepEqual(b.startedUnits, expected) {
+		t.Errorf("Expected %v, Got %v", expected, b.startedUnits)
+	}
+}
+
+func TestApplyFailure(t *testing.T) {
+	f, err := ioutil.TempFile("", "deisctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("components:\n  - name: database\n  - name: controller\n")
+	f.Close()
+
+	var out, errOut bytes.Buffer
+	Stdout, Stderr = &out, &errOut
+	defer func() { Stdout, Stderr = os.Stdout, os.Stderr }()
+
+	b := &backendStub{failing: map[string]bool{"database": true}}
+	err = Apply(f.Name(), false, b, mock.ConfigBackend{}, fakeCheckKeys)
+	if err == nil {
+		t.Fatal("Expected an error when a unit fails to start")
+	}
+	if expected := []string{"database"}; !reflect.DeepEqual(b.startedUnits, expected) {
+		t.Errorf("Expected the controller not to start after the database failed, Got %v", b.startedUnits)
+	}
+	if !strings.Contains(err.Error(), "error starting database") {
+		t.Errorf("Expected the backend's error, Got %v", err)
+	}
+	if strings.Contains(out.String(), "Done.") {
+		t.Errorf("Expected a failed apply not to report success, Got %q", out.String())
+	}
+}
@@ -1,15 +1,18 @@
 package cmd
 
 import (
-	"bytes"
+	"bufio"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"text/tabwriter"
 
 	"github.com/deis/deis/deisctl/backend"
 	"github.com/deis/deis/deisctl/config"
@@ -31,13 +34,58 @@ const (
 )
 
 // ListUnits prints a list of installed units.
-func ListUnits(b backend.Backend) error {
-	return b.ListUnits()
+//
+// With the "json" output format, only units that have been scheduled to a
+// machine are listed.
+func ListUnits(b backend.Backend, output string) error {
+	if err := checkOutput(output); err != nil {
+		return err
+	}
+	if output == "text" {
+		return b.ListUnits()
+	}
+	return printUnitStates(b, func(s *backend.UnitState) bool {
+		return s.LoadState != ""
+	})
 }
 
 // ListUnitFiles prints the contents of all defined unit files.
-func ListUnitFiles(b backend.Backend) error {
-	return b.ListUnitFiles()
+func ListUnitFiles(b backend.Backend, output string) error {
+	if err := checkOutput(output); err != nil {
+		return err
+	}
+	if output == "text" {
+		return b.ListUnitFiles()
+	}
+	return printUnitStates(b, func(*backend.UnitState) bool { return true })
+}
+
+// checkOutput returns an error unless output is a supported output format.
+func checkOutput(output string) error {
+	if output != "text" && output != "json" {
+		return fmt.Errorf("%s is not a supported output format, use text or json", output)
+	}
+	return nil
+}
+
+// printUnitStates writes the states of the units matching filter to Stdout as JSON.
+func printUnitStates(b backend.Backend, filter func(*backend.UnitState) bool) error {
+	states, err := b.UnitStates()
+	if err != nil {
+		return err
+	}
+	matched := []*backend.UnitState{}
+	for _, s := range states {
+		if filter(s) {
+			matched = append(matched, s)
+		}
+	}
+	data, err := json.MarshalIndent(matched, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(Stdout, string(data))
+	return nil
 }
 
 // Location to write standard output. By default, this is the os.Stdout.
@@ -46,11 +94,44 @@ var Stdout io.Writer = os.Stdout
 // Location to write standard error information. By default, this is the os.Stderr.
 var Stderr io.Writer = os.Stderr
 
+// Location to read answers to questions from. By default, this is the os.Stdin.
+var Stdin io.Reader = os.Stdin
+
+// errorWriter passes writes on to w and keeps them. Backends report failures
+// by writing them to their error writer, so any write means that something
+// failed.
+type errorWriter struct {
+	sync.Mutex
+	w    io.Writer
+	errs []string
+}
+
+func newErrorWriter(w io.Writer) *errorWriter {
+	return &errorWriter{w: w}
+}
+
+func (e *errorWriter) Write(p []byte) (int, error) {
+	e.Lock()
+	defer e.Unlock()
+	e.errs = append(e.errs, strings.TrimSpace(string(p)))
+	return e.w.Write(p)
+}
+
+// Err returns an error holding everything written, or nil if nothing was.
+func (e *errorWriter) Err() error {
+	e.Lock()
+	defer e.Unlock()
+	if len(e.errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s", strings.Join(e.errs, "\n"))
+}
+
 // Number of routers to be installed. By default, it's DefaultRouterMeshSize.
 var RouterMeshSize = DefaultRouterMeshSize
 
 // Scale grows or shrinks the number of running components.
-// Currently "router", "registry" and "store-gateway" are the only types that can be scaled.
+// Only components whose unit files declare themselves scalable can be scaled.
 func Scale(targets []string, b backend.Backend) error {
 	var wg sync.WaitGroup
 
@@ -59,8 +140,11 @@ func Scale(targets []string, b backend.Backend) error {
 		if err != nil {
 			return err
 		}
-		// the router, registry, and store-gateway are the only component that can scale at the moment
-		if !strings.Contains(component, "router") && !strings.Contains(component, "registry") && !strings.Contains(component, "store-gateway") {
+		scalable, err := b.Scalable(component)
+		if err != nil {
+			return err
+		}
+		if !scalable {
 			return fmt.Errorf("cannot scale %s component", component)
 		}
 		b.Scale(component, num, &wg, Stdout, Stderr)
@@ -69,6 +153,43 @@ func Scale(targets []string, b backend.Backend) error {
 	return nil
 }
 
+// ScaleStatus prints, for each component with numbered units, how many units
+// should be running and how many are.
+func ScaleStatus(b backend.Backend) error {
+	states, err := b.UnitStates()
+	if err != nil {
+		return err
+	}
+
+	type counts struct{ desired, running int }
+	byComponent := make(map[string]*counts)
+	var components []string
+	for _, s := range states {
+		target, component := unitTarget(s.Name)
+		if target == component {
+			continue
+		}
+		if byComponent[component] == nil {
+			byComponent[component] = &counts{}
+			components = append(components, component)
+		}
+		if s.DesiredState == "launched" {
+			byComponent[component].desired++
+		}
+		if s.SubState == "running" {
+			byComponent[component].running++
+		}
+	}
+	sort.Strings(components)
+
+	w := tabwriter.NewWriter(Stdout, 0, 8, 1, '\t', 0)
+	fmt.Fprintln(w, "COMPONENT\tDESIRED\tRUNNING")
+	for _, c := range components {
+		fmt.Fprintf(w, "%s\t%d\t%d\n", c, byComponent[c].desired, byComponent[c].running)
+	}
+	return w.Flush()
+}
+
 // Start activates the specified components.
 func Start(targets []string, b backend.Backend) error {
 
@@ -99,10 +220,11 @@ func Start(targets []string, b backend.Backend) error {
 func RollingRestart(target string, b backend.Backend) error {
 	var wg sync.WaitGroup
 
-	b.RollingRestart(target, &wg, Stdout, Stderr)
+	e := newErrorWriter(Stderr)
+	b.RollingRestart(target, &wg, Stdout, e)
 	wg.Wait()
 
-	return nil
+	return e.Err()
 }
 
 // CheckRequiredKeys exist in config backend
@@ -120,66 +242,10 @@ deisctl config platform set sshPrivateKey=<path-to-key>
 	return nil
 }
 
-func startDefaultServices(b backend.Backend, stateless bool, wg *sync.WaitGroup, out, err io.Writer) {
-
-	// Wait for groups to come up.
-	// If we're running in stateless mode, we start only a subset of services.
-	if !stateless {
-		fmt.Fprintln(out, "Storage subsystem...")
-		b.Start([]string{"store-monitor"}, wg, out, err)
-		wg.Wait()
-		b.Start([]string{"store-daemon"}, wg, out, err)
-		wg.Wait()
-		b.Start([]string{"store-metadata"}, wg, out, err)
-		wg.Wait()
-
-		// we start gateway first to give metadata time to come up for volume
-		b.Start([]string{"store-gateway@*"}, wg, out, err)
-		wg.Wait()
-		b.Start([]string{"store-volume"}, wg, out, err)
-		wg.Wait()
-	}
-
-	// start logging subsystem first to collect logs from other components
-	fmt.Fprintln(out, "Logging subsystem...")
-	if !stateless {
-		b.Start([]string{"logger"}, wg, out, err)
-		wg.Wait()
-	}
-	b.Start([]string{"logspout"}, wg, out, err)
-	wg.Wait()
-
-	// Start these in parallel. This section can probably be removed now.
-	var bgwg sync.WaitGroup
-	var trash bytes.Buffer
-	batch := []string{
-		"database", "registry@*", "controller", "builder",
-		"publisher", "router@*",
-	}
-	if stateless {
-		batch = []string{"registry@*", "controller", "builder", "publisher", "router@*"}
-	}
-	b.Start(batch, &bgwg, &trash, &trash)
-	// End background stuff.
-
-	fmt.Fprintln(Stdout, "Control plane...")
-	batch = []string{"database", "registry@*", "controller"}
-	if stateless {
-		batch = []string{"registry@*", "controller"}
-	}
-	b.Start(batch, wg, out, err)
-	wg.Wait()
-
-	b.Start([]string{"builder"}, wg, out, err)
-	wg.Wait()
-
-	fmt.Fprintln(out, "Data plane...")
-	b.Start([]string{"publisher"}, wg, out, err)
-	wg.Wait()
-
-	fmt.Fprintln(out, "Router mesh...")
-	b.Start([]string{"router@*"}, wg, out, err)
-	wg.Wait()
+// startDefaultServices starts the platform's components in dependency order,
+// starting independent components in parallel.
+func startDefaultServices(b backend.Backend, stateless bool, out, ew io.Writer) error {
+	return platform(stateless).start(b, out, ew)
 }
 
 // Stop deactivates the specified components.
@@ -209,44 +275,10 @@ func Stop(targets []string, b backend.Backend) error {
 	return nil
 }
 
-func stopDefaultServices(b backend.Backend, stateless bool, wg *sync.WaitGroup, out, err io.Writer) {
-
-	fmt.Fprintln(out, "Router mesh...")
-	b.Stop([]string{"router@*"}, wg, out, err)
-	wg.Wait()
-
-	fmt.Fprintln(out, "Data plane...")
-	b.Stop([]string{"publisher"}, wg, out, err)
-	wg.Wait()
-
-	fmt.Fprintln(out, "Control plane...")
-	if stateless {
-		b.Stop([]string{"controller", "builder", "registry@*"}, wg, out, err)
-	} else {
-		b.Stop([]string{"controller", "builder", "database", "registry@*"}, wg, out, err)
-	}
-	wg.Wait()
-
-	fmt.Fprintln(out, "Logging subsystem...")
-	if stateless {
-		b.Stop([]string{"logspout"}, wg, out, err)
-	} else {
-		b.Stop([]string{"logger", "logspout"}, wg, out, err)
-	}
-	wg.Wait()
-
-	if !stateless {
-		fmt.Fprintln(out, "Storage subsystem...")
-		b.Stop([]string{"store-volume", "store-gateway@*"}, wg, out, err)
-		wg.Wait()
-		b.Stop([]string{"store-metadata"}, wg, out, err)
-		wg.Wait()
-		b.Stop([]string{"store-daemon"}, wg, out, err)
-		wg.Wait()
-		b.Stop([]string{"store-monitor"}, wg, out, err)
-		wg.Wait()
-	}
-
+// stopDefaultServices stops the platform's components in the reverse of the
+// order they are started in.
+func stopDefaultServices(b backend.Backend, stateless bool, out, ew io.Writer) error {
+	return platform(stateless).stop(b, out, ew)
 }
 
 // Restart stops and then starts the specified components.
@@ -261,7 +293,22 @@ func Restart(targets []string, b backend.Backend) error {
 }
 
 // Status prints the current status of components.
-func Status(targets []string, b backend.Backend) error {
+func Status(targets []string, output string, b backend.Backend) error {
+	if err := checkOutput(output); err != nil {
+		return err
+	}
+	if output == "json" {
+		return printUnitStates(b, func(s *backend.UnitState) bool {
+			target, component := unitTarget(s.Name)
+			for _, t := range targets {
+				t = strings.TrimSuffix(strings.TrimPrefix(t, "deis-"), ".service")
+				if t == target || t == component || t == component+"@*" {
+					return true
+				}
+			}
+			return false
+		})
+	}
 
 	for _, target := range targets {
 		if err := b.Status(target); err != nil {
@@ -310,38 +357,8 @@ func Install(targets []string, b backend.Backend, cb config.Backend, checkKeys f
 	return nil
 }
 
-func installDefaultServices(b backend.Backend, stateless bool, wg *sync.WaitGroup, out, err io.Writer) {
-
-	if !stateless {
-		fmt.Fprintln(out, "Storage subsystem...")
-		b.Create([]string{"store-daemon", "store-monitor", "store-metadata", "store-volume", "store-gateway@1"}, wg, out, err)
-		wg.Wait()
-	}
-
-	fmt.Fprintln(out, "Logging subsystem...")
-	if stateless {
-		b.Create([]string{"logspout"}, wg, out, err)
-	} else {
-		b.Create([]string{"logger", "logspout"}, wg, out, err)
-	}
-	wg.Wait()
-
-	fmt.Fprintln(out, "Control plane...")
-	if stateless {
-		b.Create([]string{"registry@1", "controller", "builder"}, wg, out, err)
-	} else {
-		b.Create([]string{"database", "registry@1", "controller", "builder"}, wg, out, err)
-	}
-	wg.Wait()
-
-	fmt.Fprintln(out, "Data plane...")
-	b.Create([]string{"publisher"}, wg, out, err)
-	wg.Wait()
-
-	fmt.Fprintln(out, "Router mesh...")
-	b.Create(getRouters(), wg, out, err)
-	wg.Wait()
-
+func installDefaultServices(b backend.Backend, stateless bool, out, ew io.Writer) error {
+	return platform(stateless).install(b, out, ew)
 }
 
 func getRouters() []string {
@@ -379,45 +396,8 @@ func Uninstall(targets []string, b backend.Backend) error {
 	return nil
 }
 
-func uninstallAllServices(b backend.Backend, stateless bool, wg *sync.WaitGroup, out, err io.Writer) error {
-
-	fmt.Fprintln(out, "Router mesh...")
-	b.Destroy([]string{"router@*"}, wg, out, err)
-	wg.Wait()
-
-	fmt.Fprintln(out, "Data plane...")
-	b.Destroy([]string{"publisher"}, wg, out, err)
-	wg.Wait()
-
-	fmt.Fprintln(out, "Control plane...")
-	if stateless {
-		b.Destroy([]string{"controller", "builder", "registry@*"}, wg, out, err)
-	} else {
-		b.Destroy([]string{"controller", "builder", "database", "registry@*"}, wg, out, err)
-	}
-	wg.Wait()
-
-	fmt.Fprintln(out, "Logging subsystem...")
-	if stateless {
-		b.Destroy([]string{"logspout"}, wg, out, err)
-	} else {
-		b.Destroy([]string{"logger", "logspout"}, wg, out, err)
-	}
-	wg.Wait()
-
-	if !stateless {
-		fmt.Fprintln(out, "Storage subsystem...")
-		b.Destroy([]string{"store-volume", "store-gateway@*"}, wg, out, err)
-		wg.Wait()
-		b.Destroy([]string{"store-metadata"}, wg, out, err)
-		wg.Wait()
-		b.Destroy([]string{"store-daemon"}, wg, out, err)
-		wg.Wait()
-		b.Destroy([]string{"store-monitor"}, wg, out, err)
-		wg.Wait()
-	}
-
-	return nil
+func uninstallAllServices(b backend.Backend, stateless bool, out, ew io.Writer) error {
+	return platform(stateless).uninstall(b, out, ew)
 }
 
 func splitScaleTarget(target string) (c string, num int, err error) {
@@ -440,13 +420,44 @@ func splitScaleTarget(target string) (c string, num int, err error) {
 // A configuration value is stored and retrieved from a key/value store
 // at /deis/<component>/<config>. Configuration values are typically used for component-level
 // configuration, such as enabling TLS for the routers.
-func Config(target string, action string, key []string, cb config.Backend) error {
-	if err := config.Config(target, action, key, cb); err != nil {
+func Config(target string, action string, key []string, force bool, cb config.Backend) error {
+	if err := config.Config(target, action, key, force, cb); err != nil {
 		return err
 	}
 	return nil
 }
 
+// ConfigExport writes a snapshot of the config of the targets, or of the whole
+// platform if none are given.
+func ConfigExport(targets []string, cb config.Backend) error {
+	return config.Export(cb, targets, Stdout)
+}
+
+// ConfigImport sets the config from a snapshot written by ConfigExport, after
+// printing the keys that change and, unless yes is true, asking to go ahead.
+func ConfigImport(path string, dryRun, yes bool, cb config.Backend) error {
+	s, err := config.ReadSnapshot(path)
+	if err != nil {
+		return err
+	}
+	confirm := func() bool {
+		return yes || askYesNo("Import these keys?")
+	}
+	return config.Import(cb, s, dryRun, confirm, Stdout)
+}
+
+// askYesNo asks a question on Stdout and returns whether the answer read from
+// Stdin is yes. No answer, such as when Stdin is not a terminal, means no.
+func askYesNo(question string) bool {
+	fmt.Fprintf(Stdout, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
 // RefreshUnits overwrites local unit files with those requested.
 // Downloading from the Deis project GitHub URL by tag or SHA is the only mechanism
 // currently supported.
@@ -1,12 +1,15 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"reflect"
 	"strings"
 	"sync"
@@ -20,24 +23,43 @@ import (
 )
 
 type backendStub struct {
+	mutex            sync.Mutex
+	states           []*backend.UnitState
 	startedUnits     []string
 	stoppedUnits     []string
 	installedUnits   []string
 	uninstalledUnits []string
 	restartedUnits   []string
 	expected         bool
+	machines         []*backend.Machine
+	sshOutput        func(machine, command string) (string, error)
+	// failing targets are reported as failed to start
+	failing map[string]bool
 }
 
 func (backend *backendStub) Create(targets []string, wg *sync.WaitGroup, out, ew io.Writer) {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
 	backend.installedUnits = append(backend.installedUnits, targets...)
 }
 func (backend *backendStub) Destroy(targets []string, wg *sync.WaitGroup, out, ew io.Writer) {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
 	backend.uninstalledUnits = append(backend.uninstalledUnits, targets...)
 }
 func (backend *backendStub) Start(targets []string, wg *sync.WaitGroup, out, ew io.Writer) {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
 	backend.startedUnits = append(backend.startedUnits, targets...)
+	for _, target := range targets {
+		if backend.failing[target] {
+			fmt.Fprintf(ew, "error starting %s\n", target)
+		}
+	}
 }
 func (backend *backendStub) Stop(targets []string, wg *sync.WaitGroup, out, ew io.Writer) {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
 	backend.stoppedUnits = append(backend.stoppedUnits, targets...)
 }
 func (backend *backendStub) Scale(component string, num int, wg *sync.WaitGroup, out, ew io.Writer) {
@@ -50,8 +72,18 @@ func (backend *backendStub) Scale(component string, num int, wg *sync.WaitGroup,
 		backend.expected = false
 	}
 }
+func (backend *backendStub) Scalable(component string) (bool, error) {
+	switch component {
+	case "router", "registry", "store-gateway":
+		return true, nil
+	}
+	return false, nil
+}
 func (backend *backendStub) RollingRestart(target string, wg *sync.WaitGroup, out, ew io.Writer) {
 	backend.restartedUnits = append(backend.restartedUnits, target)
+	if backend.failing[target] {
+		fmt.Fprintf(ew, "Aborting rolling restart: %s failed\n", target)
+	}
 }
 
 func (backend *backendStub) ListUnits() error {
@@ -89,6 +121,21 @@ func (backend *backendStub) Dock(target string, command []string) error {
 	return nil
 }
 
+func (backend *backendStub) UnitStates() ([]*backend.UnitState, error) {
+	return backend.states, nil
+}
+
+func (backend *backendStub) Machines() ([]*backend.Machine, error) {
+	return backend.machines, nil
+}
+
+func (backend *backendStub) SSHOutput(machine, command string) (string, error) {
+	if backend.sshOutput == nil {
+		return "", errors.New("Error")
+	}
+	return backend.sshOutput(machine, command)
+}
+
 var _ backend.Backend = &backendStub{}
 
 func fakeCheckKeys(cb config.Backend) error {
@@ -177,7 +224,7 @@ func TestListUnits(t *testing.T) {
 
 	b := backendStub{installedUnits: []string{"router@1", "router@2"}}
 
-	if ListUnits(&b) != nil {
+	if ListUnits(&b, "text") != nil {
 		t.Error("unexpected error")
 	}
 }
@@ -187,7 +234,7 @@ func TestListUnitFiles(t *testing.T) {
 
 	b := backendStub{}
 
-	if ListUnitFiles(&b) != nil {
+	if ListUnitFiles(&b, "text") != nil {
 		t.Error("unexpected error")
 	}
 }
@@ -229,6 +276,28 @@ func TestScalingInvalidFormat(t *testing.T) {
 	}
 }
 
+func TestScaleStatus(t *testing.T) {
+	var out bytes.Buffer
+	Stdout = &out
+	defer func() { Stdout = os.Stdout }()
+
+	b := backendStub{states: []*backend.UnitState{
+		{Name: "deis-controller.service", DesiredState: "launched", SubState: "running"},
+		{Name: "deis-router@1.service", DesiredState: "launched", SubState: "running"},
+		{Name: "deis-router@2.service", DesiredState: "launched", SubState: "failed"},
+		{Name: "deis-registry@1.service", DesiredState: "loaded", SubState: "dead"},
+	}}
+
+	if err := ScaleStatus(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "COMPONENT\tDESIRED\tRUNNING\nregistry\t0\t0\nrouter\t\t2\t1\n"
+	if out.String() != expected {
+		t.Errorf("Expected %q, Got %q", expected, out.String())
+	}
+}
+
 func TestStart(t *testing.T) {
 	t.Parallel()
 
@@ -248,13 +317,12 @@ func TestStartPlatform(t *testing.T) {
 	b := backendStub{}
 	expected := []string{"store-monitor", "store-daemon", "store-metadata", "store-gateway@*",
 		"store-volume", "logger", "logspout", "database", "registry@*", "controller",
-		"builder", "publisher", "router@*", "database", "registry@*", "controller",
 		"builder", "publisher", "router@*"}
 
 	Start([]string{"platform"}, &b)
 
-	if !reflect.DeepEqual(b.startedUnits, expected) {
-		t.Error(fmt.Errorf("Expected %v, Got %v", expected, b.startedUnits))
+	if err := checkOrder(platformGraph, b.startedUnits, expected, false); err != nil {
+		t.Error(err)
 	}
 }
 
@@ -263,13 +331,12 @@ func TestStartStatelessPlatform(t *testing.T) {
 
 	b := backendStub{}
 	expected := []string{"logspout", "registry@*", "controller",
-		"builder", "publisher", "router@*", "registry@*", "controller",
 		"builder", "publisher", "router@*"}
 
 	Start([]string{"stateless-platform"}, &b)
 
-	if !reflect.DeepEqual(b.startedUnits, expected) {
-		t.Error(fmt.Errorf("Expected %v, Got %v", expected, b.startedUnits))
+	if err := checkOrder(platform(true), b.startedUnits, expected, false); err != nil {
+		t.Error(err)
 	}
 }
 
@@ -292,13 +359,31 @@ func TestRollingRestart(t *testing.T) {
 	b := backendStub{}
 	expected := []string{"router"}
 
-	RollingRestart("router", &b)
+	if err := RollingRestart("router", &b); err != nil {
+		t.Fatal(err)
+	}
 
 	if !reflect.DeepEqual(b.restartedUnits, expected) {
 		t.Error(fmt.Errorf("Expected %v, Got %v", expected, b.restartedUnits))
 	}
 }
 
+func TestRollingRestartFailure(t *testing.T) {
+	var errOut bytes.Buffer
+	Stderr = &errOut
+	defer func() { Stderr = os.Stderr }()
+
+	b := backendStub{failing: map[string]bool{"router": true}}
+	expected := "Aborting rolling restart: router failed"
+
+	if err := RollingRestart("router", &b); err == nil || err.Error() != expected {
+		t.Errorf("Expected %q, Got %v", expected, err)
+	}
+	if errOut.String() != expected+"\n" {
+		t.Errorf("Expected the error to be printed, Got %q", errOut.String())
+	}
+}
+
 func TestUpgradePrep(t *testing.T) {
 	t.Parallel()
 
@@ -308,8 +393,11 @@ func TestUpgradePrep(t *testing.T) {
 
 	UpgradePrep(&b)
 
-	if !reflect.DeepEqual(b.stoppedUnits, expected) {
-		t.Error(fmt.Errorf("Expected %v, Got %v", expected, b.stoppedUnits))
+	if err := checkOrder(platformGraph, b.stoppedUnits, expected, true); err != nil {
+		t.Error(err)
+	}
+	if err := checkOrder(platformGraph, b.uninstalledUnits, expected, true); err != nil {
+		t.Error(err)
 	}
 }
 
@@ -320,9 +408,8 @@ func TestUpgradeTakeover(t *testing.T) {
 
 	b := backendStub{}
 	expectedRestarted := []string{"router"}
-	expectedStarted := []string{"publisher", "store-monitor", "store-daemon", "store-metadata",
+	expectedStarted := []string{"store-monitor", "store-daemon", "store-metadata",
 		"store-gateway@*", "store-volume", "logger", "logspout", "database", "registry@*",
-		"controller", "builder", "publisher", "router@*", "database", "registry@*",
 		"controller", "builder", "publisher", "router@*"}
 
 	if err := doUpgradeTakeOver(&b, testMock); err != nil {
@@ -332,8 +419,12 @@ func TestUpgradeTakeover(t *testing.T) {
 	if !reflect.DeepEqual(b.restartedUnits, expectedRestarted) {
 		t.Error(fmt.Errorf("Expected %v, Got %v", expectedRestarted, b.restartedUnits))
 	}
-	if !reflect.DeepEqual(b.startedUnits, expectedStarted) {
-		t.Error(fmt.Errorf("Expected %v, Got %v", expectedStarted, b.startedUnits))
+	// the publisher is started on its own before the rest of the platform
+	if b.startedUnits[0] != "publisher" {
+		t.Errorf("Expected publisher to start first, Got %v", b.startedUnits)
+	}
+	if err := checkOrder(platformGraph, b.startedUnits[1:], expectedStarted, false); err != nil {
+		t.Error(err)
 	}
 }
 
@@ -358,8 +449,8 @@ func TestStopPlatform(t *testing.T) {
 		"store-metadata", "store-daemon", "store-monitor"}
 	Stop([]string{"platform"}, &b)
 
-	if !reflect.DeepEqual(b.stoppedUnits, expected) {
-		t.Error(fmt.Errorf("Expected %v, Got %v", expected, b.stoppedUnits))
+	if err := checkOrder(platformGraph, b.stoppedUnits, expected, true); err != nil {
+		t.Error(err)
 	}
 }
 
@@ -371,8 +462,8 @@ func TestStopStatelessPlatform(t *testing.T) {
 		"registry@*", "logspout"}
 	Stop([]string{"stateless-platform"}, &b)
 
-	if !reflect.DeepEqual(b.stoppedUnits, expected) {
-		t.Error(fmt.Errorf("Expected %v, Got %v", expected, b.stoppedUnits))
+	if err := checkOrder(platform(true), b.stoppedUnits, expected, true); err != nil {
+		t.Error(err)
 	}
 }
 
@@ -441,18 +532,66 @@ func TestStatus(t *testing.T) {
 
 	b := backendStub{}
 
-	if Status([]string{"controller", "builder"}, &b) != nil {
+	if Status([]string{"controller", "builder"}, "text", &b) != nil {
 		t.Error("Unexpected Error")
 	}
 }
 
+func TestStatusJSON(t *testing.T) {
+	var out bytes.Buffer
+	Stdout = &out
+	defer func() { Stdout = os.Stdout }()
+
+	b := backendStub{states: []*backend.UnitState{
+		{Name: "deis-controller.service", MachineID: "abc", MachineIP: "10.0.0.1",
+			LoadState: "loaded", ActiveState: "active", SubState: "running", Hash: "1234"},
+		{Name: "deis-router@1.service", LoadState: "loaded", ActiveState: "active", SubState: "running"},
+		{Name: "deis-router@2.service"},
+	}}
+
+	if err := Status([]string{"controller", "router@2"}, "json", &b); err != nil {
+		t.Fatal(err)
+	}
+	var states []*backend.UnitState
+	if err := json.Unmarshal(out.Bytes(), &states); err != nil {
+		t.Fatal(err)
+	}
+	expected := []*backend.UnitState{b.states[0], b.states[2]}
+	if !reflect.DeepEqual(states, expected) {
+		t.Errorf("Expected %v, Got %v", expected, states)
+	}
+
+	out.Reset()
+	if err := ListUnits(&b, "json"); err != nil {
+		t.Fatal(err)
+	}
+	states = nil
+	if err := json.Unmarshal(out.Bytes(), &states); err != nil {
+		t.Fatal(err)
+	}
+	if expected := b.states[:2]; !reflect.DeepEqual(states, expected) {
+		t.Errorf("Expected %v, Got %v", expected, states)
+	}
+}
+
+func TestOutputFormatError(t *testing.T) {
+	t.Parallel()
+
+	b := backendStub{}
+
+	expected := "yaml is not a supported output format, use text or json"
+	if err := ListUnitFiles(&b, "yaml"); err == nil || err.Error() != expected {
+		t.Errorf("Expected '%v', Got '%v'", expected, err)
+	}
+}
+
 func TestStatusError(t *testing.T) {
 	t.Parallel()
 
 	b := backendStub{}
 
 	expected := "Test Error"
-	err := Status([]string{"blah"}, &b).Error()
+	err := Status([]string{"blah"}, "text", &b).Error()
 
 	if err != expected {
 		t.Error(fmt.Errorf("Expected '%v', Got '%v'", expected, err))
@@ -509,8 +648,8 @@ func TestInstallPlatform(t *testing.T) {
 
 	Install([]string{"platform"}, &b, &cb, fakeCheckKeys)
 
-	if !reflect.DeepEqual(b.installedUnits, expected) {
-		t.Error(fmt.Errorf("Expected %v, Got %v", expected, b.installedUnits))
+	if err := checkOrder(platformGraph, b.installedUnits, expected, false); err != nil {
+		t.Error(err)
 	}
 }
 
@@ -528,8 +667,8 @@ func TestInstallPlatformWithCustomRouterMeshSize(t *testing.T) {
 	Install([]string{"platform"}, &b, &cb, fakeCheckKeys)
 	RouterMeshSize = DefaultRouterMeshSize
 
-	if !reflect.DeepEqual(b.installedUnits, expected) {
-		t.Error(fmt.Errorf("Expected %v, Got %v", expected, b.installedUnits))
+	if err := checkOrder(platformGraph, b.installedUnits, expected, false); err != nil {
+		t.Error(err)
 	}
 }
 
@@ -544,8 +683,8 @@ func TestInstallStatelessPlatform(t *testing.T) {
 
 	Install([]string{"stateless-platform"}, &b, &cb, fakeCheckKeys)
 
-	if !reflect.DeepEqual(b.installedUnits, expected) {
-		t.Error(fmt.Errorf("Expected %v, Got %v", expected, b.installedUnits))
+	if err := checkOrder(platform(true), b.installedUnits, expected, false); err != nil {
+		t.Error(err)
 	}
 }
 
@@ -587,8 +726,8 @@ func TestUninstallPlatform(t *testing.T) {
 
 	Uninstall([]string{"platform"}, &b)
 
-	if !reflect.DeepEqual(b.uninstalledUnits, expected) {
-		t.Error(fmt.Errorf("Expected %v, Got %v", expected, b.uninstalledUnits))
+	if err := checkOrder(platformGraph, b.uninstalledUnits, expected, true); err != nil {
+		t.Error(err)
 	}
 }
 
@@ -601,8 +740,8 @@ func TestUninstallStatelessPlatform(t *testing.T) {
 
 	Uninstall([]string{"stateless-platform"}, &b)
 
-	if !reflect.DeepEqual(b.uninstalledUnits, expected) {
-		t.Error(fmt.Errorf("Expected %v, Got %v", expected, b.uninstalledUnits))
+	if err := checkOrder(platform(true), b.uninstalledUnits, expected, true); err != nil {
+		t.Error(err)
 	}
 }
 
@@ -618,3 +757,44 @@ func TestUninstallSwarm(t *testing.T) {
 		t.Error(fmt.Errorf("Expected %v, Got %v", expected, b.uninstalledUnits))
 	}
 }
+
+func TestConfigImportConfirm(t *testing.T) {
+	f, err := ioutil.TempFile("", "deisctl-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("config:\n  platform:\n    domain: example.com\n")
+	f.Close()
+
+	var out bytes.Buffer
+	Stdout = &out
+	defer func() { Stdout, Stdin = os.Stdout, os.Stdin }()
+
+	tests := []struct {
+		answer   string
+		yes      bool
+		expected string
+	}{
+		{"\n", false, ""},
+		{"", false, ""},
+		{"n\n", false, ""},
+		{"y\n", false, "example.com"},
+		{"YES\n", false, "example.com"},
+		{"", true, "example.com"},
+	}
+	for _, test := range tests {
+		cb := mock.NewMemoryBackend()
+		Stdin = strings.NewReader(test.answer)
+		out.Reset()
+		if err := ConfigImport(f.Name(), false, test.yes, cb); err != nil {
+			t.Fatal(err)
+		}
+		if value, _ := cb.Get("/deis/platform/domain"); value != test.expected {
+			t.Errorf("Answering %q with yes=%v: Expected %q, Got %q", test.answer, test.yes, test.expected, value)
+		}
+		if asked := strings.Contains(out.String(), "[y/N]"); asked == test.yes {
+			t.Errorf("Answering %q with yes=%v: Expected to ask only without yes, Got %q", test.answer, test.yes, out.String())
+		}
+	}
+}
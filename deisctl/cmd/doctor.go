@@ -0,0 +1,288 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/deis/deis/deisctl/backend"
+	"github.com/deis/deis/deisctl/config"
+)
+
+const (
+	// clockSkewWarn is the clock skew between machines worth warning about.
+	clockSkewWarn = time.Second
+	// clockSkewFail is the clock skew at which etcd and fleet misbehave.
+	clockSkewFail = 5 * time.Second
+)
+
+// machineCheckCommand prints the machine's clock, then whether its etcd member answers.
+const machineCheckCommand = `date +%s.%N; etcdctl --no-sync ls / >/dev/null 2>&1 && echo etcd-ok || echo etcd-unreachable`
+
+// DoctorCheck is the outcome of one of the checks run by Doctor.
+type DoctorCheck struct {
+	// Status is "pass", "warn" or "fail".
+	Status string
+	// Name is what was checked, such as "unit deis-router@1.service".
+	Name string
+	// Detail describes what was found.
+	Detail string
+	// Hint suggests how to fix a warning or failure.
+	Hint string
+}
+
+// publication is an etcd key a component publishes while it is healthy.
+type publication struct {
+	key       string
+	component string
+	// dir is true if the key is a directory that should have children.
+	dir bool
+}
+
+var publications = []publication{
+	{key: "/deis/logs/host", component: "logger"},
+	{key: "/deis/registry/host", component: "registry"},
+	{key: "/deis/router/hosts", component: "router", dir: true},
+	{key: "/deis/controller/host", component: "controller"},
+	{key: "/deis/controller/port", component: "controller"},
+}
+
+// Doctor checks the health of the platform and prints a report, returning an
+// error if any check failed.
+func Doctor(b backend.Backend, cb config.Backend) error {
+	states, err := b.UnitStates()
+	if err != nil {
+		return err
+	}
+	stateless := isStateless(states)
+
+	var checks []DoctorCheck
+	checks = append(checks, checkUnits(platform(stateless), states)...)
+	checks = append(checks, checkPublications(cb, stateless)...)
+	checks = append(checks, checkPlatformConfig(cb)...)
+	checks = append(checks, checkMachines(b)...)
+
+	return printReport(checks)
+}
+
+// isStateless returns true if none of the platform's stateful components are installed.
+func isStateless(states []*backend.UnitState) bool {
+	for _, s := range states {
+		_, component := unitTarget(s.Name)
+		for _, c := range statefulComponents {
+			if c == component {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func checkUnits(g graph, states []*backend.UnitState) []DoctorCheck {
+	var checks []DoctorCheck
+	installed := make(map[string]bool)
+	for _, s := range states {
+		target, component := unitTarget(s.Name)
+		if target == "" {
+			continue
+		}
+		installed[component] = true
+		check := DoctorCheck{Name: "unit " + s.Name, Detail: s.ActiveState + "/" + s.SubState}
+		switch {
+		case s.ActiveState == "active" && s.SubState == "running":
+			check.Status = "pass"
+		case s.ActiveState == "failed" || s.SubState == "failed":
+			check.Status = "fail"
+			check.Hint = fmt.Sprintf("Inspect it with `deisctl journal %s`, then run `deisctl restart %s`.", target, target)
+		case s.MachineID == "":
+			check.Status = "warn"
+			check.Detail = "not scheduled to a machine"
+			check.Hint = "Check that a machine matches its placement with `fleetctl list-machines`."
+		default:
+			check.Status = "warn"
+			check.Hint = fmt.Sprintf("Start it with `deisctl start %s`.", target)
+		}
+		checks = append(checks, check)
+	}
+	for _, n := range g {
+		if !installed[n.Name] {
+			checks = append(checks, DoctorCheck{
+				Status: "warn",
+				Name:   "component " + n.Name,
+				Detail: "not installed",
+				Hint:   fmt.Sprintf("Install and start it with `deisctl install %s && deisctl start %s`.", n.Name, n.target()),
+			})
+		}
+	}
+	return checks
+}
+
+func checkPublications(cb config.Backend, stateless bool) []DoctorCheck {
+	var checks []DoctorCheck
+	for _, p := range publications {
+		if stateless && p.component == "logger" {
+			continue
+		}
+		check := DoctorCheck{Name: "etcd " + p.key, Status: "pass"}
+		if p.dir {
+			nodes, err := cb.GetRecursive(p.key)
+			if err != nil || len(nodes) == 0 {
+				check.Status = "fail"
+				check.Detail = "nothing published"
+			} else {
+				check.Detail = fmt.Sprintf("%d published", len(nodes))
+			}
+		} else {
+			value, err := cb.Get(p.key)
+			if err != nil {
+				check.Status = "fail"
+				check.Detail = "not published"
+			} else {
+				check.Detail = value
+			}
+		}
+		if check.Status == "fail" {
+			check.Hint = fmt.Sprintf("The %s publishes this key while it is healthy; check `deisctl journal %s`.", p.component, p.component)
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+func checkPlatformConfig(cb config.Backend) []DoctorCheck {
+	checks := []DoctorCheck{{Status: "pass", Name: "config /deis/platform/domain"}}
+	if err := config.CheckConfig("/deis/platform/", "domain", cb); err != nil {
+		checks[0].Status = "fail"
+		checks[0].Detail = "not set"
+		checks[0].Hint = "Set it with `deisctl config platform set domain=<your-domain>`."
+	}
+	check := DoctorCheck{Status: "pass", Name: "config /deis/platform/sshPrivateKey"}
+	if err := config.CheckConfig("/deis/platform/", "sshPrivateKey", cb); err != nil {
+		check.Status = "warn"
+		check.Detail = `not set, "deis run" is unavailable`
+		check.Hint = "Set it with `deisctl config platform set sshPrivateKey=<path-to-key>`."
+	}
+	return append(checks, check)
+}
+
+// checkMachines checks that every machine's etcd member answers, that the
+// etcd cluster is healthy and that the machines' clocks agree with this one.
+func checkMachines(b backend.Backend) []DoctorCheck {
+	machines, err := b.Machines()
+	if err != nil {
+		return []DoctorCheck{{Status: "fail", Name: "machines", Detail: err.Error(),
+			Hint: "Check that fleet is reachable, or set DEISCTL_TUNNEL."}}
+	}
+
+	var checks []DoctorCheck
+	var reachable string
+	for _, m := range machines {
+		name := "machine " + m.IP
+		before := time.Now()
+		out, err := b.SSHOutput(m.ID, machineCheckCommand)
+		after := time.Now()
+		if err != nil {
+			checks = append(checks, DoctorCheck{Status: "warn", Name: name, Detail: "could not run checks: " + err.Error(),
+				Hint: "Check that you can `deisctl ssh " + m.ID + "`."})
+			continue
+		}
+		lines := strings.Split(strings.TrimSpace(out), "\n")
+
+		etcd := DoctorCheck{Status: "pass", Name: name + " etcd", Detail: "reachable"}
+		if lines[len(lines)-1] != "etcd-ok" {
+			etcd.Status = "fail"
+			etcd.Detail = "unreachable"
+			etcd.Hint = "Check `systemctl status etcd` on the machine."
+		} else if reachable == "" {
+			reachable = m.ID
+		}
+		checks = append(checks, etcd)
+
+		seconds, err := strconv.ParseFloat(lines[0], 64)
+		if err != nil {
+			checks = append(checks, DoctorCheck{Status: "warn", Name: name + " clock", Detail: "could not read the time"})
+			continue
+		}
+		checks = append(checks, clockCheck(name, seconds, before, after))
+	}
+
+	if reachable != "" {
+		check := DoctorCheck{Status: "pass", Name: "etcd cluster", Detail: "healthy"}
+		out, err := b.SSHOutput(reachable, "etcdctl cluster-health")
+		if err != nil || strings.Contains(out, "unhealthy") {
+			check.Status = "fail"
+			check.Detail = strings.TrimSpace(out)
+			if check.Detail == "" && err != nil {
+				check.Detail = err.Error()
+			}
+			check.Hint = "Bring the unhealthy members back, or remove them with `etcdctl member remove`."
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// clockCheck compares a machine's clock, read between before and after, with this one.
+func clockCheck(name string, seconds float64, before, after time.Time) DoctorCheck {
+	remote := time.Unix(0, int64(seconds*float64(time.Second)))
+	// the remote clock was read some time between before and after
+	uncertainty := after.Sub(before) / 2
+	skew := remote.Sub(before.Add(uncertainty))
+	if skew < 0 {
+		skew = -skew
+	}
+	skew -= uncertainty
+	if skew < 0 {
+		skew = 0
+	}
+
+	check := DoctorCheck{Status: "pass", Name: name + " clock", Detail: fmt.Sprintf("skew %v", skew)}
+	switch {
+	case skew > clockSkewFail:
+		check.Status = "fail"
+	case skew > clockSkewWarn:
+		check.Status = "warn"
+	}
+	if check.Status != "pass" {
+		check.Hint = "Make sure systemd-timesyncd or ntpd is running and synchronized on the machine."
+	}
+	return check
+}
+
+// printReport prints checks, failures last, and returns an error if any failed.
+func printReport(checks []DoctorCheck) error {
+	rank := map[string]int{"pass": 0, "warn": 1, "fail": 2}
+	sort.Stable(byStatus{checks, rank})
+
+	counts := make(map[string]int)
+	for _, c := range checks {
+		counts[c.Status]++
+		line := fmt.Sprintf("%-4s  %s", strings.ToUpper(c.Status), c.Name)
+		if c.Detail != "" {
+			line += ": " + c.Detail
+		}
+		fmt.Fprintln(Stdout, line)
+		if c.Hint != "" {
+			fmt.Fprintln(Stdout, "      "+c.Hint)
+		}
+	}
+	fmt.Fprintf(Stdout, "\n%d passed, %d warnings, %d failed\n", counts["pass"], counts["warn"], counts["fail"])
+
+	if counts["fail"] > 0 {
+		return fmt.Errorf("%d checks failed", counts["fail"])
+	}
+	return nil
+}
+
+type byStatus struct {
+	checks []DoctorCheck
+	rank   map[string]int
+}
+
+func (s byStatus) Len() int      { return len(s.checks) }
+func (s byStatus) Swap(i, j int) { s.checks[i], s.checks[j] = s.checks[j], s.checks[i] }
+func (s byStatus) Less(i, j int) bool {
+	return s.rank[s.checks[i].Status] < s.rank[s.checks[j].Status]
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deis/deis/deisctl/backend"
+	"github.com/deis/deis/deisctl/config/model"
+	"github.com/deis/deis/deisctl/test/mock"
+)
+
+func TestDoctor(t *testing.T) {
+	var out bytes.Buffer
+	Stdout = &out
+	defer func() { Stdout = os.Stdout }()
+
+	var states []*backend.UnitState
+	for _, n := range platformGraph {
+		name := "deis-" + n.Name + ".service"
+		if n.Scalable {
+			name = "deis-" + n.Name + "@1.service"
+		}
+		states = append(states, &backend.UnitState{Name: name, MachineID: "m1", ActiveState: "active", SubState: "running"})
+	}
+	states[len(states)-1].ActiveState, states[len(states)-1].SubState = "failed", "failed"
+
+	now := float64(time.Now().UnixNano()) / float64(time.Second)
+	b := backendStub{
+		states:   states,
+		machines: []*backend.Machine{{ID: "m1", IP: "10.0.0.1"}, {ID: "m2", IP: "10.0.0.2"}},
+		sshOutput: func(machine, command string) (string, error) {
+			if command == "etcdctl cluster-health" {
+				return "cluster is healthy\n", nil
+			}
+			if machine == "m2" {
+				return fmt.Sprintf("%f\netcd-unreachable\n", now+30), nil
+			}
+			return fmt.Sprintf("%f\netcd-ok\n", now), nil
+		},
+	}
+	cb := mock.ConfigBackend{Expected: []*model.ConfigNode{
+		{Key: "/deis/platform/domain", Value: "example.com"},
+		{Key: "/deis/logs/host", Value: "10.0.0.1"},
+		{Key: "/deis/registry/host", Value: "10.0.0.1"},
+		{Key: "/deis/controller/host", Value: "10.0.0.1"},
+		{Key: "/deis/controller/port", Value: "8000"},
+	}}
+
+	err := Doctor(&b, cb)
+	if err == nil {
+		t.Fatal("Expected doctor to report failed checks")
+	}
+
+	for _, expected := range []string{
+		"PASS  unit deis-controller.service: active/running\n",
+		"PASS  etcd /deis/controller/host: 10.0.0.1\n",
+		"PASS  config /deis/platform/domain\n",
+		"PASS  machine 10.0.0.1 clock",
+		"PASS  etcd cluster: healthy\n",
+		"WARN  config /deis/platform/sshPrivateKey",
+		"FAIL  unit deis-router@1.service: failed/failed\n      Inspect it with `deisctl journal router@1`",
+		"FAIL  etcd /deis/router/hosts: nothing published\n",
+		"FAIL  machine 10.0.0.2 etcd: unreachable\n",
+		"FAIL  machine 10.0.0.2 clock",
+		"1 warnings, 4 failed\n",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected report to contain %q, Got %q", expected, out.String())
+		}
+	}
+	if strings.Index(out.String(), "PASS") > strings.Index(out.String(), "FAIL") {
+		t.Error("Expected failures to be reported last")
+	}
+}
+
+func TestDoctorMissingComponents(t *testing.T) {
+	t.Parallel()
+
+	states := []*backend.UnitState{{Name: "deis-router@1.service", ActiveState: "inactive", SubState: "dead"}}
+	checks := checkUnits(platform(isStateless(states)), states)
+
+	found := make(map[string]DoctorCheck)
+	for _, c := range checks {
+		found[c.Name] = c
+	}
+	if c := found["unit deis-router@1.service"]; c.Status != "warn" || c.Detail != "not scheduled to a machine" {
+		t.Errorf("Expected router@1 to warn that it is unscheduled, Got %+v", c)
+	}
+	if _, ok := found["component database"]; ok {
+		t.Error("Expected a stateless platform not to need the database")
+	}
+	if c := found["component controller"]; c.Status != "warn" || !strings.Contains(c.Hint, "deisctl install controller") {
+		t.Errorf("Expected a missing controller to warn, Got %+v", c)
+	}
+}
+
+func TestClockCheck(t *testing.T) {
+	t.Parallel()
+
+	before := time.Unix(1000, 0)
+	after := before.Add(2 * time.Second)
+
+	for seconds, expected := range map[float64]string{
+		1001:   "pass",
+		1003.5: "warn",
+		995:    "warn",
+		1010:   "fail",
+		990:    "fail",
+	} {
+		if c := clockCheck("machine", seconds, before, after); c.Status != expected {
+			t.Errorf("Expected %v to %s, Got %+v", seconds, expected, c)
+		}
+	}
+}
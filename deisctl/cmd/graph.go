@@ -0,0 +1,338 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/deis/deis/deisctl/backend"
+)
+
+// node is a component in a dependency graph.
+type node struct {
+	// Name is the component name, such as "router".
+	Name string
+	// Scalable components are addressed as <name>@<num> units.
+	Scalable bool
+	// Requires are the components that must be running before this one starts.
+	Requires []string
+}
+
+// graph is a set of components and their dependencies, in a stable order.
+type graph []node
+
+// platformGraph describes how the Deis platform components depend on one another.
+var platformGraph = graph{
+	{Name: "store-monitor"},
+	{Name: "store-daemon", Requires: []string{"store-monitor"}},
+	{Name: "store-metadata", Requires: []string{"store-daemon"}},
+	{Name: "store-gateway", Scalable: true, Requires: []string{"store-metadata"}},
+	// the gateway gives metadata time to come up before the volume mounts it
+	{Name: "store-volume", Requires: []string{"store-gateway"}},
+	{Name: "logger", Requires: []string{"store-volume"}},
+	// logging comes up first to collect logs from the other components
+	{Name: "logspout", Requires: []string{"logger"}},
+	{Name: "database", Requires: []string{"logspout", "store-gateway"}},
+	{Name: "registry", Scalable: true, Requires: []string{"logspout", "store-gateway"}},
+	{Name: "controller", Requires: []string{"database", "registry"}},
+	{Name: "builder", Requires: []string{"controller"}},
+	{Name: "publisher", Requires: []string{"logspout"}},
+	{Name: "router", Scalable: true, Requires: []string{"logspout"}},
+}
+
+// componentGraph holds every component deisctl knows how to order.
+var componentGraph = joinGraphs(platformGraph, k8sGraph, mesosGraph, swarmGraph)
+
+// statefulComponents are left out of a stateless platform.
+var statefulComponents = []string{
+	"store-monitor", "store-daemon", "store-metadata", "store-gateway", "store-volume",
+	"logger", "database",
+}
+
+var k8sGraph = graph{
+	{Name: "kube-apiserver"},
+	{Name: "kube-controller-manager", Requires: []string{"kube-apiserver"}},
+	{Name: "kube-scheduler", Requires: []string{"kube-apiserver"}},
+	{Name: "kube-kubelet", Requires: []string{"kube-controller-manager", "kube-scheduler"}},
+	{Name: "kube-proxy", Requires: []string{"kube-kubelet"}},
+}
+
+var mesosGraph = graph{
+	{Name: "zookeeper"},
+	{Name: "mesos-master", Requires: []string{"zookeeper"}},
+	{Name: "mesos-marathon", Requires: []string{"mesos-master"}},
+	{Name: "mesos-slave", Requires: []string{"mesos-marathon"}},
+}
+
+var swarmGraph = graph{
+	{Name: "swarm-manager"},
+	{Name: "swarm-node", Requires: []string{"swarm-manager"}},
+}
+
+// platform returns the platform graph, without the stateful components if stateless.
+func platform(stateless bool) graph {
+	if !stateless {
+		return platformGraph
+	}
+	return platformGraph.without(statefulComponents...)
+}
+
+// without returns the graph minus the named components. Dependencies on them
+// are dropped.
+func (g graph) without(names ...string) graph {
+	skip := make(map[string]bool)
+	for _, n := range names {
+		skip[n] = true
+	}
+	var out graph
+	for _, n := range g {
+		if !skip[n.Name] {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+func joinGraphs(graphs ...graph) graph {
+	var out graph
+	for _, g := range graphs {
+		out = append(out, g...)
+	}
+	return out
+}
+
+// subgraph returns the named components, in graph order. Components that are
+// not in the graph come last, in the order they are named, and require nothing.
+func (g graph) subgraph(names []string) graph {
+	want := make(map[string]bool)
+	for _, name := range names {
+		want[name] = true
+	}
+	var out graph
+	for _, n := range g {
+		if want[n.Name] {
+			out = append(out, n)
+			delete(want, n.Name)
+		}
+	}
+	for _, name := range names {
+		if want[name] {
+			out = append(out, node{Name: name})
+			delete(want, name)
+		}
+	}
+	return out
+}
+
+// target returns the target addressing all of a component's units.
+func (n node) target() string {
+	if n.Scalable {
+		return n.Name + "@*"
+	}
+	return n.Name
+}
+
+// installTargets returns the units to create when installing a component.
+func (n node) installTargets() []string {
+	switch {
+	case n.Name == "router":
+		return getRouters()
+	case n.Scalable:
+		return []string{n.Name + "@1"}
+	}
+	return []string{n.Name}
+}
+
+// walk calls fn for every component, running independent components in
+// parallel. A component is visited only once fn has succeeded for all of the
+// components it requires or, if reverse is true, for all of the components
+// that require it. Components whose dependencies failed are skipped.
+func (g graph) walk(reverse bool, fn func(n node) error) error {
+	in := make(map[string]bool)
+	for _, n := range g {
+		in[n.Name] = true
+	}
+	waitsOn := make(map[string][]string)
+	for _, n := range g {
+		for _, r := range n.Requires {
+			if !in[r] {
+				continue
+			}
+			if reverse {
+				waitsOn[r] = append(waitsOn[r], n.Name)
+			} else {
+				waitsOn[n.Name] = append(waitsOn[n.Name], r)
+			}
+		}
+	}
+
+	done := make(map[string]chan struct{})
+	for _, n := range g {
+		done[n.Name] = make(chan struct{})
+	}
+	var mutex sync.Mutex
+	failed := make(map[string]bool)
+	var errs []string
+
+	var wg sync.WaitGroup
+	for _, n := range g {
+		wg.Add(1)
+		go func(n node) {
+			defer wg.Done()
+			defer close(done[n.Name])
+			for _, dep := range waitsOn[n.Name] {
+				<-done[dep]
+			}
+			mutex.Lock()
+			for _, dep := range waitsOn[n.Name] {
+				if failed[dep] {
+					failed[n.Name] = true
+					errs = append(errs, fmt.Sprintf("%s: skipped because %s failed", n.Name, dep))
+					mutex.Unlock()
+					return
+				}
+			}
+			mutex.Unlock()
+			if err := fn(n); err != nil {
+				mutex.Lock()
+				failed[n.Name] = true
+				errs = append(errs, fmt.Sprintf("%s: %v", n.Name, err))
+				mutex.Unlock()
+			}
+		}(n)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return fmt.Errorf("%s", strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// install creates the units of every component in the graph.
+func (g graph) install(b backend.Backend, out, ew io.Writer) error {
+	return g.walk(false, func(n node) error {
+		var wg sync.WaitGroup
+		e := newErrorWriter(ew)
+		b.Create(n.installTargets(), &wg, out, e)
+		wg.Wait()
+		return e.Err()
+	})
+}
+
+// start starts every component in the graph, waiting for a component's
+// units to be running before starting the components that require it.
+func (g graph) start(b backend.Backend, out, ew io.Writer) error {
+	return g.walk(false, func(n node) error {
+		var wg sync.WaitGroup
+		e := newErrorWriter(ew)
+		b.Start([]string{n.target()}, &wg, out, e)
+		wg.Wait()
+		if err := e.Err(); err != nil {
+			return err
+		}
+		return checkStarted(b, n.Name)
+	})
+}
+
+// stop stops every component in the graph, stopping the components that
+// require a component before the component itself.
+func (g graph) stop(b backend.Backend, out, ew io.Writer) error {
+	return g.walk(true, func(n node) error {
+		var wg sync.WaitGroup
+		e := newErrorWriter(ew)
+		b.Stop([]string{n.target()}, &wg, out, e)
+		wg.Wait()
+		return e.Err()
+	})
+}
+
+// uninstall destroys the units of every component in the graph, in the
+// same order as stop.
+func (g graph) uninstall(b backend.Backend, out, ew io.Writer) error {
+	return g.walk(true, func(n node) error {
+		var wg sync.WaitGroup
+		e := newErrorWriter(ew)
+		b.Destroy([]string{n.target()}, &wg, out, e)
+		wg.Wait()
+		return e.Err()
+	})
+}
+
+// healthPorts are the loopback ports that the unit files publish components'
+// /readyz endpoints on.
+var healthPorts = map[string]int{
+	"builder":        8092,
+	"router":         8093,
+	"cache":          8094,
+	"logger":         8095,
+	"publisher":      8096,
+	"mesos-master":   8101,
+	"mesos-slave":    8102,
+	"mesos-marathon": 8103,
+}
+
+// readyTimeout is how long a started unit has to pass its readiness check.
+var readyTimeout = 5 * time.Minute
+
+// readyPollInterval is how often a unit's readiness check is retried.
+var readyPollInterval = time.Second
+
+// checkStarted returns an error unless all of a component's units are
+// running and ready to serve.
+func checkStarted(b backend.Backend, component string) error {
+	if err := checkRunning(b, component); err != nil {
+		return err
+	}
+	return checkReady(b, component)
+}
+
+// checkReady waits until every unit of a component answers its /readyz
+// endpoint, on the machine it runs on. A running unit is not necessarily
+// ready: its process may still be booting or publishing itself to etcd.
+func checkReady(b backend.Backend, component string) error {
+	port, ok := healthPorts[component]
+	if !ok {
+		return nil
+	}
+	states, err := b.UnitStates()
+	if err != nil {
+		return err
+	}
+	command := fmt.Sprintf("curl -sf http://127.0.0.1:%d/readyz", port)
+	for _, s := range states {
+		if _, c := unitTarget(s.Name); c != component {
+			continue
+		}
+		deadline := time.Now().Add(readyTimeout)
+		for {
+			_, err := b.SSHOutput(s.MachineID, command)
+			if err == nil {
+				break
+			}
+			if time.Now().After(deadline) {
+				return fmt.Errorf("%s is not ready after %v: %v", s.Name, readyTimeout, err)
+			}
+			time.Sleep(readyPollInterval)
+		}
+	}
+	return nil
+}
+
+// checkRunning returns an error unless all of a component's units are running.
+func checkRunning(b backend.Backend, component string) error {
+	states, err := b.UnitStates()
+	if err != nil {
+		return err
+	}
+	for _, s := range states {
+		if _, c := unitTarget(s.Name); c == component && s.SubState != "running" {
+			return fmt.Errorf("%s is %s/%s", s.Name, s.ActiveState, s.SubState)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deis/deis/deisctl/backend"
+)
+
+// checkOrder returns an error unless got holds the expected targets, with
+// every component's targets after those of the components it requires, or
+// before them if reverse is true.
+func checkOrder(g graph, got, expected []string, reverse bool) error {
+	sortedGot := append([]string{}, got...)
+	sortedExpected := append([]string{}, expected...)
+	sort.Strings(sortedGot)
+	sort.Strings(sortedExpected)
+	if !reflect.DeepEqual(sortedGot, sortedExpected) {
+		return fmt.Errorf("Expected %v, Got %v", expected, got)
+	}
+
+	first := make(map[string]int)
+	last := make(map[string]int)
+	for i, target := range got {
+		c := strings.SplitN(target, "@", 2)[0]
+		if _, ok := first[c]; !ok {
+			first[c] = i
+		}
+		last[c] = i
+	}
+	for _, n := range g {
+		for _, r := range n.Requires {
+			if _, ok := first[r]; !ok {
+				continue
+			}
+			if !reverse && last[r] > first[n.Name] {
+				return fmt.Errorf("Expected %s before %s, Got %v", r, n.Name, got)
+			}
+			if reverse && last[n.Name] > first[r] {
+				return fmt.Errorf("Expected %s before %s, Got %v", n.Name, r, got)
+			}
+		}
+	}
+	return nil
+}
+
+func TestWalkParallel(t *testing.T) {
+	t.Parallel()
+
+	g := graph{
+		{Name: "a"},
+		{Name: "b", Requires: []string{"a"}},
+		{Name: "c", Requires: []string{"a"}},
+		{Name: "d", Requires: []string{"b", "c"}},
+	}
+
+	// b and c can only both finish if they run at the same time
+	var barrier sync.WaitGroup
+	barrier.Add(2)
+	var mutex sync.Mutex
+	var visited []string
+	err := g.walk(false, func(n node) error {
+		if n.Name == "b" || n.Name == "c" {
+			barrier.Done()
+			barrier.Wait()
+		}
+		mutex.Lock()
+		visited = append(visited, n.Name)
+		mutex.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkOrder(g, visited, []string{"a", "b", "c", "d"}, false); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWalkReverse(t *testing.T) {
+	t.Parallel()
+
+	var visited []string
+	swarmGraph.walk(true, func(n node) error {
+		visited = append(visited, n.Name)
+		return nil
+	})
+
+	expected := []string{"swarm-node", "swarm-manager"}
+	if !reflect.DeepEqual(visited, expected) {
+		t.Error(fmt.Errorf("Expected %v, Got %v", expected, visited))
+	}
+}
+
+func TestWalkFailure(t *testing.T) {
+	t.Parallel()
+
+	var mutex sync.Mutex
+	var visited []string
+	err := mesosGraph.walk(false, func(n node) error {
+		mutex.Lock()
+		visited = append(visited, n.Name)
+		mutex.Unlock()
+		if n.Name == "mesos-master" {
+			return errors.New("failed")
+		}
+		return nil
+	})
+
+	expected := []string{"zookeeper", "mesos-master"}
+	if !reflect.DeepEqual(visited, expected) {
+		t.Error(fmt.Errorf("Expected %v, Got %v", expected, visited))
+	}
+	if err == nil || !strings.Contains(err.Error(), "mesos-slave: skipped because mesos-marathon failed") {
+		t.Errorf("Expected dependents to be skipped, Got %v", err)
+	}
+}
+
+func TestStartUnhealthy(t *testing.T) {
+	t.Parallel()
+
+	b := backendStub{states: []*backend.UnitState{
+		{Name: "deis-swarm-manager.service", ActiveState: "failed", SubState: "failed"},
+	}}
+
+	err := StartSwarm(&b)
+
+	expected := []string{"swarm-manager"}
+	if !reflect.DeepEqual(b.startedUnits, expected) {
+		t.Error(fmt.Errorf("Expected %v, Got %v", expected, b.startedUnits))
+	}
+	if err == nil || !strings.Contains(err.Error(), "deis-swarm-manager.service is failed/failed") {
+		t.Errorf("Expected an unhealthy swarm-manager error, Got %v", err)
+	}
+}
+
+func TestCheckReady(t *testing.T) {
+	t.Parallel()
+
+	var mutex sync.Mutex
+	var commands []string
+	b := backendStub{
+		states: []*backend.UnitState{
+			{Name: "deis-router@1.service", MachineID: "m1", ActiveState: "active", SubState: "running"},
+			{Name: "deis-router@2.service", MachineID: "m2", ActiveState: "active", SubState: "running"},
+			{Name: "deis-controller.service", MachineID: "m1", ActiveState: "active", SubState: "running"},
+		},
+		sshOutput: func(machine, command string) (string, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			commands = append(commands, machine+": "+command)
+			return "OK", nil
+		},
+	}
+
+	if err := checkReady(&b, "router"); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"m1: curl -sf http://127.0.0.1:8093/readyz",
+		"m2: curl -sf http://127.0.0.1:8093/readyz",
+	}
+	if !reflect.DeepEqual(commands, expected) {
+		t.Errorf("Expected %v, Got %v", expected, commands)
+	}
+
+	// components without a readiness endpoint are ready once running
+	if err := checkReady(&b, "controller"); err != nil {
+		t.Fatal(err)
+	}
+	if len(commands) != len(expected) {
+		t.Errorf("Expected controller not to be checked, Got %v", commands)
+	}
+}
+
+func TestCheckReadyTimeout(t *testing.T) {
+	readyTimeout, readyPollInterval = time.Millisecond, time.Millisecond
+	defer func() { readyTimeout, readyPollInterval = 5*time.Minute, time.Second }()
+
+	b := backendStub{
+		states: []*backend.UnitState{
+			{Name: "deis-cache.service", MachineID: "m1", ActiveState: "active", SubState: "running"},
+		},
+		sshOutput: func(machine, command string) (string, error) {
+			return "", errors.New("exit status 22")
+		},
+	}
+
+	err := checkReady(&b, "cache")
+	if err == nil || !strings.HasPrefix(err.Error(), "deis-cache.service is not ready after 1ms") {
+		t.Errorf("Expected a readiness timeout, Got %v", err)
+	}
+}
+
+func TestPlatformStateless(t *testing.T) {
+	t.Parallel()
+
+	for _, n := range platform(true) {
+		for _, c := range statefulComponents {
+			if n.Name == c {
+				t.Errorf("Expected %s to be left out of a stateless platform", c)
+			}
+		}
+	}
+}
@@ -3,7 +3,6 @@ package cmd
 import (
 	"fmt"
 	"io"
-	"sync"
 
 	"github.com/deis/deis/deisctl/backend"
 	"github.com/deis/deis/deisctl/config"
@@ -24,13 +23,11 @@ func InstallPlatform(b backend.Backend, cb config.Backend, checkKeys func(config
 		fmt.Println("See the official Deis documentation for details on running a stateless control plane.")
 	}
 
-	var wg sync.WaitGroup
-
 	io.WriteString(Stdout, prettyprint.DeisIfy("Installing Deis..."))
 
-	installDefaultServices(b, stateless, &wg, Stdout, Stderr)
-
-	wg.Wait()
+	if err := installDefaultServices(b, stateless, Stdout, Stderr); err != nil {
+		return err
+	}
 
 	fmt.Fprintln(Stdout, "Done.")
 	fmt.Fprintln(Stdout, "")
@@ -45,13 +42,11 @@ func InstallPlatform(b backend.Backend, cb config.Backend, checkKeys func(config
 // StartPlatform activates all components.
 func StartPlatform(b backend.Backend, stateless bool) error {
 
-	var wg sync.WaitGroup
-
 	io.WriteString(Stdout, prettyprint.DeisIfy("Starting Deis..."))
 
-	startDefaultServices(b, stateless, &wg, Stdout, Stderr)
-
-	wg.Wait()
+	if err := startDefaultServices(b, stateless, Stdout, Stderr); err != nil {
+		return err
+	}
 
 	fmt.Fprintln(Stdout, "Done.\n ")
 	fmt.Fprintln(Stdout, "Please set up an administrative account. See 'deis help register'")
@@ -61,13 +56,11 @@ func StartPlatform(b backend.Backend, stateless bool) error {
 // StopPlatform deactivates all components.
 func StopPlatform(b backend.Backend, stateless bool) error {
 
-	var wg sync.WaitGroup
-
 	io.WriteString(Stdout, prettyprint.DeisIfy("Stopping Deis..."))
 
-	stopDefaultServices(b, stateless, &wg, Stdout, Stderr)
-
-	wg.Wait()
+	if err := stopDefaultServices(b, stateless, Stdout, Stderr); err != nil {
+		return err
+	}
 
 	fmt.Fprintln(Stdout, "Done.\n ")
 	if stateless {
@@ -82,13 +75,11 @@ func StopPlatform(b backend.Backend, stateless bool) error {
 // After UninstallPlatform, all components will be unavailable.
 func UninstallPlatform(b backend.Backend, stateless bool) error {
 
-	var wg sync.WaitGroup
-
 	io.WriteString(Stdout, prettyprint.DeisIfy("Uninstalling Deis..."))
 
-	uninstallAllServices(b, stateless, &wg, Stdout, Stderr)
-
-	wg.Wait()
+	if err := uninstallAllServices(b, stateless, Stdout, Stderr); err != nil {
+		return err
+	}
 
 	fmt.Fprintln(Stdout, "Done.")
 	return nil
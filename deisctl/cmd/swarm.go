@@ -3,7 +3,6 @@ package cmd
 import (
 	"fmt"
 	"io"
-	"sync"
 
 	"github.com/deis/deis/deisctl/backend"
 	"github.com/deis/deis/pkg/prettyprint"
@@ -11,14 +10,10 @@ import (
 
 //InstallSwarm Installs swarm
 func InstallSwarm(b backend.Backend) error {
-	var wg sync.WaitGroup
 	io.WriteString(Stdout, prettyprint.DeisIfy("Installing Swarm..."))
-	fmt.Fprintln(Stdout, "Swarm control plane...")
-	b.Create([]string{"swarm-manager"}, &wg, Stdout, Stderr)
-	wg.Wait()
-	fmt.Fprintln(Stdout, "Swarm data plane...")
-	b.Create([]string{"swarm-node"}, &wg, Stdout, Stderr)
-	wg.Wait()
+	if err := swarmGraph.install(b, Stdout, Stderr); err != nil {
+		return err
+	}
 	fmt.Fprintln(Stdout, "Done.\n ")
 	fmt.Fprintln(Stdout, "Please run `deisctl start swarm` to start swarm.")
 	return nil
@@ -26,14 +21,10 @@ func InstallSwarm(b backend.Backend) error {
 
 //StartSwarm starts Swarm Schduler
 func StartSwarm(b backend.Backend) error {
-	var wg sync.WaitGroup
 	io.WriteString(Stdout, prettyprint.DeisIfy("Starting Swarm..."))
-	fmt.Fprintln(Stdout, "Swarm control plane...")
-	b.Start([]string{"swarm-manager"}, &wg, Stdout, Stderr)
-	wg.Wait()
-	fmt.Fprintln(Stdout, "Swarm data plane...")
-	b.Start([]string{"swarm-node"}, &wg, Stdout, Stderr)
-	wg.Wait()
+	if err := swarmGraph.start(b, Stdout, Stderr); err != nil {
+		return err
+	}
 	fmt.Fprintln(Stdout, "Done.\n ")
 	fmt.Fprintln(Stdout, "Please run `deisctl config controller set schedulerModule=swarm` to use the swarm scheduler.")
 	return nil
@@ -41,30 +32,20 @@ func StartSwarm(b backend.Backend) error {
 
 //StopSwarm stops swarm
 func StopSwarm(b backend.Backend) error {
-
-	var wg sync.WaitGroup
 	io.WriteString(Stdout, prettyprint.DeisIfy("Stopping Swarm..."))
-	fmt.Fprintln(Stdout, "Swarm data plane...")
-	b.Stop([]string{"swarm-node"}, &wg, Stdout, Stderr)
-	wg.Wait()
-	fmt.Fprintln(Stdout, "Swarm control plane...")
-	b.Stop([]string{"swarm-manager"}, &wg, Stdout, Stderr)
-	wg.Wait()
-
+	if err := swarmGraph.stop(b, Stdout, Stderr); err != nil {
+		return err
+	}
 	fmt.Fprintln(Stdout, "Done.\n ")
 	return nil
 }
 
 //UnInstallSwarm uninstall Swarm
 func UnInstallSwarm(b backend.Backend) error {
-	var wg sync.WaitGroup
 	io.WriteString(Stdout, prettyprint.DeisIfy("Uninstalling Swarm..."))
-	fmt.Fprintln(Stdout, "Swarm data plane...")
-	b.Destroy([]string{"swarm-node"}, &wg, Stdout, Stderr)
-	wg.Wait()
-	fmt.Fprintln(Stdout, "Swarm control plane...")
-	b.Destroy([]string{"swarm-manager"}, &wg, Stdout, Stderr)
-	wg.Wait()
+	if err := swarmGraph.uninstall(b, Stdout, Stderr); err != nil {
+		return err
+	}
 	fmt.Fprintln(Stdout, "Done.\n ")
 	return nil
 }
@@ -11,32 +11,17 @@ import (
 
 // UpgradePrep stops and uninstalls all components except router and publisher
 func UpgradePrep(b backend.Backend) error {
-	var wg sync.WaitGroup
-
-	b.Stop([]string{"database", "registry@*", "controller", "builder", "logger", "logspout"}, &wg, Stdout, Stderr)
-	wg.Wait()
-	b.Destroy([]string{"database", "registry@*", "controller", "builder", "logger", "logspout"}, &wg, Stdout, Stderr)
-	wg.Wait()
-
-	b.Stop([]string{"store-volume", "store-gateway@*"}, &wg, Stdout, Stderr)
-	wg.Wait()
-	b.Destroy([]string{"store-volume", "store-gateway@*"}, &wg, Stdout, Stderr)
-	wg.Wait()
-
-	b.Stop([]string{"store-metadata"}, &wg, Stdout, Stderr)
-	wg.Wait()
-	b.Destroy([]string{"store-metadata"}, &wg, Stdout, Stderr)
-	wg.Wait()
-
-	b.Stop([]string{"store-daemon"}, &wg, Stdout, Stderr)
-	wg.Wait()
-	b.Destroy([]string{"store-daemon"}, &wg, Stdout, Stderr)
-	wg.Wait()
-
-	b.Stop([]string{"store-monitor"}, &wg, Stdout, Stderr)
-	wg.Wait()
-	b.Destroy([]string{"store-monitor"}, &wg, Stdout, Stderr)
-	wg.Wait()
+	err := platformGraph.without("router", "publisher").walk(true, func(n node) error {
+		var wg sync.WaitGroup
+		b.Stop([]string{n.target()}, &wg, Stdout, Stderr)
+		wg.Wait()
+		b.Destroy([]string{n.target()}, &wg, Stdout, Stderr)
+		wg.Wait()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 
 	fmt.Fprintln(Stdout, "The platform has been stopped, but applications are still serving traffic as normal.")
 	fmt.Fprintln(Stdout, "Your cluster is now ready for upgrade. Install a new deisctl version and run `deisctl upgrade-takeover`.")
@@ -91,17 +76,17 @@ func doUpgradeTakeOver(b backend.Backend, cb config.Backend) error {
 		return err
 	}
 
-	b.RollingRestart("router", &wg, Stdout, Stderr)
-	wg.Wait()
+	if err := RollingRestart("router", b); err != nil {
+		return err
+	}
 	b.Create([]string{"publisher"}, &wg, Stdout, Stderr)
 	wg.Wait()
 	b.Start([]string{"publisher"}, &wg, Stdout, Stderr)
 	wg.Wait()
 
-	installDefaultServices(b, false, &wg, Stdout, Stderr) // @fixme: hax?
-	wg.Wait()
+	if err := installDefaultServices(b, false, Stdout, Stderr); err != nil { // @fixme: hax?
+		return err
+	}
 
-	startDefaultServices(b, false, &wg, Stdout, Stderr) // @fixme: hax?
-	wg.Wait()
-	return nil
+	return startDefaultServices(b, false, Stdout, Stderr) // @fixme: hax?
 }
@@ -21,9 +21,10 @@ var fileKeys = []string{
 // b64Keys define config keys to be base64 encoded before stored
 var b64Keys = []string{"/deis/platform/sshPrivateKey"}
 
-// Config runs the config subcommand
-func Config(target string, action string, key []string, cb Backend) error {
-	return doConfig(target, action, key, cb, os.Stdout)
+// Config runs the config subcommand. Keys are checked against the target's
+// Schema before they are set, unless force is true.
+func Config(target string, action string, key []string, force bool, cb Backend) error {
+	return doConfig(target, action, key, force, cb, os.Stdout)
 }
 
 // CheckConfig looks for a value at a keyspace path
@@ -38,7 +39,7 @@ func CheckConfig(root string, k string, cb Backend) error {
 	return nil
 }
 
-func doConfig(target string, action string, key []string, cb Backend, w io.Writer) error {
+func doConfig(target string, action string, key []string, force bool, cb Backend, w io.Writer) error {
 	rootPath := "/deis/" + target + "/"
 
 	var vals []string
@@ -48,7 +49,13 @@ func doConfig(target string, action string, key []string, cb Backend, w io.Write
 	case "rm":
 		vals, err = doConfigRm(cb, rootPath, key)
 	case "set":
-		vals, err = doConfigSet(cb, rootPath, key)
+		vals, err = doConfigSet(cb, target, rootPath, key, !force)
+	case "describe":
+		vals, err = doConfigDescribe(target)
+	case "history":
+		vals, err = doConfigHistory(cb, rootPath, key)
+	case "rollback":
+		vals, err = doConfigRollback(cb, rootPath, key)
 	default:
 		vals, err = doConfigGet(cb, rootPath, key)
 	}
@@ -63,9 +70,24 @@ func doConfig(target string, action string, key []string, cb Backend, w io.Write
 	return nil
 }
 
-func doConfigSet(cb Backend, root string, kvs []string) ([]string, error) {
+func doConfigSet(cb Backend, target string, root string, kvs []string, validate bool) ([]string, error) {
 	var result []string
 
+	// check every key before setting any, so a typo doesn't leave the
+	// config half changed
+	for _, kv := range kvs {
+		split := strings.SplitN(kv, "=", 2)
+		if len(split) != 2 {
+			return result, fmt.Errorf("expected key=val, got %s", kv)
+		}
+		if !validate {
+			continue
+		}
+		if err := Validate(target, split[0], split[1]); err != nil {
+			return result, fmt.Errorf("%v (use --force to set it anyway)", err)
+		}
+	}
+
 	for _, kv := range kvs {
 
 		// split k/v from args
@@ -80,7 +102,7 @@ func doConfigSet(cb Backend, root string, kvs []string) ([]string, error) {
 		}
 
 		// set key/value in config backend
-		ret, err := cb.Set(path, val)
+		ret, err := Set(cb, path, val)
 		if err != nil {
 			return result, err
 		}
@@ -90,6 +112,18 @@ func doConfigSet(cb Backend, root string, kvs []string) ([]string, error) {
 	return result, nil
 }
 
+func doConfigDescribe(target string) ([]string, error) {
+	keys, err := Describe(target)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, len(keys))
+	for i, k := range keys {
+		result[i] = k.String()
+	}
+	return result, nil
+}
+
 func doConfigGet(cb Backend, root string, keys []string) ([]string, error) {
 	var result []string
 	for _, k := range keys {
@@ -105,7 +139,7 @@ func doConfigGet(cb Backend, root string, keys []string) ([]string, error) {
 func doConfigRm(cb Backend, root string, keys []string) ([]string, error) {
 	var result []string
 	for _, k := range keys {
-		err := cb.Delete(root + k)
+		err := Delete(cb, root+k)
 		if err != nil {
 			return result, err
 		}
@@ -114,6 +148,39 @@ func doConfigRm(cb Backend, root string, keys []string) ([]string, error) {
 	return result, nil
 }
 
+func doConfigHistory(cb Backend, root string, keys []string) ([]string, error) {
+	var result []string
+	if len(keys) == 0 {
+		keys = []string{""}
+	}
+	for _, k := range keys {
+		changes, err := History(cb, strings.TrimSuffix(root+k, "/"))
+		if err != nil {
+			return result, err
+		}
+		for _, c := range changes {
+			result = append(result, c.String())
+		}
+	}
+	return result, nil
+}
+
+func doConfigRollback(cb Backend, root string, keys []string) ([]string, error) {
+	var result []string
+	for _, k := range keys {
+		c, err := Rollback(cb, root+k)
+		if err != nil {
+			return result, err
+		}
+		if c.Existed {
+			result = append(result, fmt.Sprintf("%s restored to %s", k, summarize(c.Value)))
+		} else {
+			result = append(result, fmt.Sprintf("%s removed", k))
+		}
+	}
+	return result, nil
+}
+
 // valueForPath returns the canonical value for a user-defined path and value
 func valueForPath(path string, v string) (string, error) {
 
@@ -142,3 +209,15 @@ func valueForPath(path string, v string) (string, error) {
 	return v, nil
 
 }
+
+// Value returns the value that setting path to v would store, reading it from
+// a local file for keys such as sshPrivateKey.
+func Value(path string, v string) (string, error) {
+	return valueForPath(path, v)
+}
+
+// PlacementKey is the key holding the machine metadata a component's units
+// are constrained to, as a comma-separated list of key=value pairs.
+func PlacementKey(component string) string {
+	return "/deis/platform/placement/" + strings.TrimPrefix(component, "deis-")
+}
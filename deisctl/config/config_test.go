@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/deis/deis/deisctl/config/model"
@@ -18,7 +19,7 @@ func TestGetConfig(t *testing.T) {
 	testMock := mock.ConfigBackend{Expected: []*model.ConfigNode{{Key: "/deis/controller/testing", Value: "foo"}, {Key: "/deis/controller/port", Value: "8000"}}}
 	testWriter := bytes.Buffer{}
 
-	err := doConfig("controller", "get", []string{"testing", "port"}, testMock, &testWriter)
+	err := doConfig("controller", "get", []string{"testing", "port"}, false, testMock, &testWriter)
 
 	if err != nil {
 		t.Fatal(err)
@@ -37,7 +38,7 @@ func TestGetConfigError(t *testing.T) {
 	testMock := mock.ConfigBackend{Expected: []*model.ConfigNode{{Key: "/deis/controller/testing", Value: "foo"}}}
 	testWriter := bytes.Buffer{}
 
-	err := doConfig("controller", "get", []string{"port"}, testMock, &testWriter)
+	err := doConfig("controller", "get", []string{"port"}, false, testMock, &testWriter)
 
 	if err == nil {
 		t.Fatal("Error Expected")
@@ -47,29 +48,53 @@ func TestGetConfigError(t *testing.T) {
 func TestSetConfig(t *testing.T) {
 	t.Parallel()
 
-	testMock := mock.ConfigBackend{Expected: []*model.ConfigNode{{Key: "/deis/controller/testing", Value: "foo"}, {Key: "/deis/controller/port", Value: "8000"}}}
+	testMock := mock.ConfigBackend{Expected: []*model.ConfigNode{{Key: "/deis/controller/webEnabled", Value: "0"}, {Key: "/deis/controller/workers", Value: "2"}}}
 	testWriter := bytes.Buffer{}
 
-	err := doConfig("controller", "set", []string{"testing=bar", "port=1000"}, testMock, &testWriter)
+	err := doConfig("controller", "set", []string{"webEnabled=1", "workers=4"}, false, testMock, &testWriter)
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	expected := "bar\n1000\n"
+	expected := "1\n4\n"
 	output := testWriter.String()
 	if output != expected {
 		t.Error(fmt.Errorf("Expected: '%s', Got:'%s'", expected, output))
 	}
 }
 
+func TestSetConfigInvalid(t *testing.T) {
+	t.Parallel()
+
+	cb := mock.NewMemoryBackend(&model.ConfigNode{Key: "/deis/controller/webEnabled", Value: "0"})
+	testWriter := bytes.Buffer{}
+
+	// nothing is set if any key is invalid
+	err := doConfig("controller", "set", []string{"webEnabled=1", "webenabled=1"}, false, cb, &testWriter)
+	if err == nil || !strings.Contains(err.Error(), "did you mean webEnabled?") {
+		t.Fatalf("Expected a hint for webenabled, Got %v", err)
+	}
+	if value, _ := cb.Get("/deis/controller/webEnabled"); value != "0" {
+		t.Errorf("Expected webEnabled to be unchanged, Got %s", value)
+	}
+
+	// --force skips validation
+	if err := doConfig("controller", "set", []string{"someKey=value"}, true, cb, &testWriter); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := cb.Get("/deis/controller/someKey"); value != "value" {
+		t.Errorf("Expected someKey to be set, Got %s", value)
+	}
+}
+
 func TestDeleteConfig(t *testing.T) {
 	t.Parallel()
 
 	testMock := mock.ConfigBackend{Expected: []*model.ConfigNode{{Key: "/deis/controller/testing", Value: "foo"}, {Key: "/deis/controller/port", Value: "8000"}}}
 	testWriter := bytes.Buffer{}
 
-	err := doConfig("controller", "rm", []string{"testing", "port"}, testMock, &testWriter)
+	err := doConfig("controller", "rm", []string{"testing", "port"}, false, testMock, &testWriter)
 
 	if err != nil {
 		t.Fatal(err)
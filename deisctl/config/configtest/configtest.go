@@ -0,0 +1,92 @@
+// Package configtest provides the contract every config.Backend must satisfy.
+package configtest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/deis/deis/deisctl/config"
+)
+
+// TestBackend checks that cb behaves like a config.Backend. It only uses keys
+// under root, which should not exist before the test.
+func TestBackend(t *testing.T, cb config.Backend, root string) {
+	key := root + "/controller/port"
+
+	// missing keys
+	if value, err := cb.Get(key); err == nil {
+		t.Errorf("Get of a missing key: expected an error, got %q", value)
+	}
+	if value, err := cb.GetWithDefault(key, "8000"); err != nil || value != "8000" {
+		t.Errorf("GetWithDefault of a missing key: expected \"8000\", got %q, %v", value, err)
+	}
+	if _, err := cb.GetRecursive(root); err == nil {
+		t.Errorf("GetRecursive of a missing key: expected an error")
+	}
+	if err := cb.Delete(key); err == nil {
+		t.Errorf("Delete of a missing key: expected an error")
+	}
+	if value, err := cb.SetWithTTL(key, "8000", 60); err == nil {
+		t.Errorf("SetWithTTL of a missing key: expected an error, got %q", value)
+	}
+
+	// set and get
+	if value, err := cb.Set(key, "8000"); err != nil || value != "8000" {
+		t.Fatalf("Set: expected \"8000\", got %q, %v", value, err)
+	}
+	if value, err := cb.Get(key); err != nil || value != "8000" {
+		t.Errorf("Get: expected \"8000\", got %q, %v", value, err)
+	}
+	if value, err := cb.GetWithDefault(key, "80"); err != nil || value != "8000" {
+		t.Errorf("GetWithDefault: expected \"8000\", got %q, %v", value, err)
+	}
+	if value, err := cb.Set(key, "9000"); err != nil || value != "9000" {
+		t.Errorf("Set of an existing key: expected \"9000\", got %q, %v", value, err)
+	}
+	if value, err := cb.Get(key); err != nil || value != "9000" {
+		t.Errorf("Get after overwriting: expected \"9000\", got %q, %v", value, err)
+	}
+
+	// values under a key, at any depth
+	if _, err := cb.Set(root+"/controller/webEnabled", "1"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if _, err := cb.Set(root+"/router/hosts/10.0.0.1", "10.0.0.1:80"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	nodes, err := cb.GetRecursive(root)
+	if err != nil {
+		t.Fatalf("GetRecursive: %v", err)
+	}
+	got := map[string]string{}
+	for _, node := range nodes {
+		got[node.Key] = node.Value
+	}
+	expected := map[string]string{
+		root + "/controller/port":       "9000",
+		root + "/controller/webEnabled": "1",
+		root + "/router/hosts/10.0.0.1": "10.0.0.1:80",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("GetRecursive: expected %v, got %v", expected, got)
+	}
+
+	// ttls
+	if value, err := cb.SetWithTTL(key, "7000", 60); err != nil || value != "7000" {
+		t.Errorf("SetWithTTL: expected \"7000\", got %q, %v", value, err)
+	}
+	if value, err := cb.Get(key); err != nil || value != "7000" {
+		t.Errorf("Get before the ttl expires: expected \"7000\", got %q, %v", value, err)
+	}
+
+	// delete
+	if err := cb.Delete(key); err != nil {
+		t.Errorf("Delete: %v", err)
+	}
+	if value, err := cb.Get(key); err == nil {
+		t.Errorf("Get of a deleted key: expected an error, got %q", value)
+	}
+	if value, err := cb.Get(root + "/controller/webEnabled"); err != nil || value != "1" {
+		t.Errorf("Delete removed more than one key: got %q, %v", value, err)
+	}
+}
@@ -0,0 +1,13 @@
+package configtest
+
+import (
+	"testing"
+
+	"github.com/deis/deis/deisctl/test/mock"
+)
+
+func TestMemoryBackend(t *testing.T) {
+	t.Parallel()
+
+	TestBackend(t, mock.NewMemoryBackend(), "/deis/test")
+}
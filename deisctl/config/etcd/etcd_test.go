@@ -0,0 +1,30 @@
+package etcd
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/deis/deis/deisctl/backend/fleet"
+	"github.com/deis/deis/deisctl/config/configtest"
+)
+
+func TestConfigBackend(t *testing.T) {
+	// this needs a running etcd, such as one started with `etcd -data-dir /tmp/etcd`
+	endpoint := os.Getenv("DEISCTL_TEST_ETCD_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("DEISCTL_TEST_ETCD_ENDPOINT is not set")
+	}
+	fleet.Flags.Endpoint = endpoint
+	fleet.Flags.RequestTimeout = 10
+
+	cb, err := NewConfigBackend()
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := fmt.Sprintf("/deisctl-test/%d", time.Now().UnixNano())
+	defer cb.etcdlib.Delete(root, true)
+
+	configtest.TestBackend(t, cb, root)
+}
@@ -0,0 +1,200 @@
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/deis/deis/deisctl/utils"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Snapshot is the platform config, in the same format as the config section
+// of a manifest for "deisctl apply"
+type Snapshot struct {
+	// Config maps a config target, such as "platform" or "router", to the
+	// keys under /deis/<target>/.
+	Config map[string]map[string]string `yaml:"config"`
+}
+
+// ImportChange is a key that importing a snapshot changes
+type ImportChange struct {
+	Path    string
+	Existed bool
+	Old     string
+	New     string
+}
+
+func (c ImportChange) String() string {
+	if !c.Existed {
+		return fmt.Sprintf("+ %s = %s", c.Path, summarize(c.New))
+	}
+	return fmt.Sprintf("~ %s: %s -> %s", c.Path, summarize(c.Old), summarize(c.New))
+}
+
+// Export writes a snapshot of the config under /deis/<target>/ for each of the
+// targets, or of every target in the Schema if none are given. Only the keys
+// in a target's Schema are exported, so the state the controller keeps under
+// /deis, such as /deis/builder/users, is left out. Keys with a TTL, such as
+// the addresses components publish, are not config and are left out too.
+func Export(cb Backend, targets []string, w io.Writer) error {
+	s, err := takeSnapshot(cb, targets)
+	if err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// ReadSnapshot reads a snapshot written by Export.
+func ReadSnapshot(path string) (*Snapshot, error) {
+	data, err := ioutil.ReadFile(utils.ResolvePath(path))
+	if err != nil {
+		return nil, err
+	}
+	s := new(Snapshot)
+	if err := yaml.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// PlanImport returns the keys that importing the snapshot would change.
+func PlanImport(cb Backend, s *Snapshot) []ImportChange {
+	var changes []ImportChange
+	for _, target := range sortedKeys(s.Config) {
+		keys := make([]string, 0, len(s.Config[target]))
+		for k := range s.Config[target] {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			path := "/deis/" + target + "/" + k
+			c := ImportChange{Path: path, New: s.Config[target][k]}
+			if old, err := cb.Get(path); err == nil {
+				if exportValue(path, old) == c.New {
+					continue
+				}
+				c.Existed = true
+				c.Old = exportValue(path, old)
+			}
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
+
+// Import prints the keys that importing the snapshot changes, then sets them
+// unless dryRun is true or confirm returns false. Previous values are recorded
+// in the history.
+func Import(cb Backend, s *Snapshot, dryRun bool, confirm func() bool, w io.Writer) error {
+	changes := PlanImport(cb, s)
+	if len(changes) == 0 {
+		fmt.Fprintln(w, "Config is up to date.")
+		return nil
+	}
+	for _, c := range changes {
+		fmt.Fprintln(w, c)
+	}
+	if dryRun {
+		return nil
+	}
+	if !confirm() {
+		fmt.Fprintln(w, "Nothing imported.")
+		return nil
+	}
+
+	for _, c := range changes {
+		warnIfNotRecorded(recordChange(cb, c.Path, "import"))
+		if _, err := cb.Set(c.Path, importValue(c.Path, c.New)); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(w, "Imported %d keys.\n", len(changes))
+	return nil
+}
+
+func takeSnapshot(cb Backend, names []string) (*Snapshot, error) {
+	s := &Snapshot{Config: make(map[string]map[string]string)}
+
+	roots := make([]string, len(names))
+	for i, target := range names {
+		roots[i] = "/deis/" + target
+	}
+	all := len(names) == 0
+	if all {
+		roots = []string{"/deis"}
+	}
+
+	for _, root := range roots {
+		nodes, err := cb.GetRecursive(root)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %s: %v", root, err)
+		}
+		for _, node := range nodes {
+			if node.Expiration != nil || node.TTL > 0 {
+				continue
+			}
+			parts := strings.SplitN(strings.TrimPrefix(node.Key, "/deis/"), "/", 2)
+			if len(parts) != 2 || parts[1] == "" {
+				continue
+			}
+			target, key := parts[0], parts[1]
+			if _, ok := Schema[target]; (all || ok) && !inSchema(target, key) {
+				continue
+			}
+			if s.Config[target] == nil {
+				s.Config[target] = make(map[string]string)
+			}
+			s.Config[target][key] = exportValue(node.Key, node.Value)
+		}
+	}
+	return s, nil
+}
+
+// exportValue returns the value of path as it is written to a snapshot.
+// Base64 encoded keys are decoded so the snapshot is readable.
+func exportValue(path, value string) string {
+	if isB64Key(path) {
+		if decoded, err := base64.StdEncoding.DecodeString(value); err == nil {
+			return string(decoded)
+		}
+	}
+	return value
+}
+
+// importValue returns the value of path as it is stored, from its value in a
+// snapshot. Unlike "deisctl config set", keys such as sshPrivateKey hold the
+// file's contents rather than its path.
+func importValue(path, value string) string {
+	if isB64Key(path) {
+		return base64.StdEncoding.EncodeToString([]byte(value))
+	}
+	return value
+}
+
+func isB64Key(path string) bool {
+	for _, p := range b64Keys {
+		if path == p {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,184 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// historyRoot is where previous values of config keys are kept. It is outside
+// of /deis so components watching their keys don't see it.
+const historyRoot = "/deisctl/history"
+
+// historyLimit is how many previous values are kept for each key
+const historyLimit = 10
+
+// Change records the value a config key had before it was changed
+type Change struct {
+	// Path is the config key that was changed
+	Path string `json:"-"`
+	// Time is when the key was changed
+	Time time.Time `json:"time"`
+	// Action is what changed the key: "set", "rm" or "import"
+	Action string `json:"action"`
+	// Existed is whether the key existed before it was changed
+	Existed bool `json:"existed"`
+	// Value is the key's previous value, if it existed
+	Value string `json:"value,omitempty"`
+
+	// entry is where the change is stored
+	entry string
+}
+
+func (c *Change) String() string {
+	previous := "(not set)"
+	if c.Existed {
+		previous = summarize(c.Value)
+	}
+	return fmt.Sprintf("%s  %-6s %s  was %s", c.Time.Format(time.RFC3339), c.Action, c.Path, previous)
+}
+
+// Set sets path to val, recording the previous value so it can be rolled back
+func Set(cb Backend, path, val string) (string, error) {
+	warnIfNotRecorded(recordChange(cb, path, "set"))
+	return cb.Set(path, val)
+}
+
+// Delete deletes path, recording the previous value so it can be rolled back
+func Delete(cb Backend, path string) error {
+	warnIfNotRecorded(recordChange(cb, path, "rm"))
+	return cb.Delete(path)
+}
+
+// warnIfNotRecorded warns that a change can't be rolled back. The change is
+// still made, since failing to keep history shouldn't block fixing the config.
+func warnIfNotRecorded(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+}
+
+// History returns the recorded changes of path, and of every key under it,
+// newest first
+func History(cb Backend, path string) ([]*Change, error) {
+	nodes, err := cb.GetRecursive(historyRoot + path)
+	if err != nil {
+		// there is no history for keys that were never changed
+		return nil, nil
+	}
+
+	var changes []*Change
+	for _, node := range nodes {
+		dir, name := historyEntryPath(node.Key)
+		if name == "" || (dir != path && !strings.HasPrefix(dir, strings.TrimSuffix(path, "/")+"/")) {
+			continue
+		}
+		c := &Change{Path: dir, entry: node.Key}
+		if err := json.Unmarshal([]byte(node.Value), c); err != nil {
+			return nil, fmt.Errorf("invalid history entry %s: %v", name, err)
+		}
+		changes = append(changes, c)
+	}
+	sort.Sort(sort.Reverse(byTime(changes)))
+	return changes, nil
+}
+
+// Rollback restores the value path had before its last recorded change, and
+// forgets that change, so rolling back again goes further back
+func Rollback(cb Backend, path string) (*Change, error) {
+	changes, err := History(cb, path)
+	if err != nil {
+		return nil, err
+	}
+	var last *Change
+	for _, c := range changes {
+		if c.Path == path {
+			last = c
+			break
+		}
+	}
+	if last == nil {
+		return nil, fmt.Errorf("no history for %s", path)
+	}
+
+	if last.Existed {
+		if _, err := cb.Set(path, last.Value); err != nil {
+			return nil, err
+		}
+	} else if _, err := cb.Get(path); err == nil {
+		if err := cb.Delete(path); err != nil {
+			return nil, err
+		}
+	}
+	return last, cb.Delete(last.entry)
+}
+
+// recordChange saves the current value of path before action changes it, and
+// forgets the oldest changes of path beyond historyLimit
+func recordChange(cb Backend, path, action string) error {
+	now := time.Now().UTC()
+	c := &Change{Time: now, Action: action}
+	if value, err := cb.Get(path); err == nil {
+		c.Existed = true
+		c.Value = value
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	entry := fmt.Sprintf("%s%s/%020d", historyRoot, path, now.UnixNano())
+	if _, err := cb.Set(entry, string(data)); err != nil {
+		return fmt.Errorf("unable to record the previous value of %s: %v", path, err)
+	}
+
+	changes, err := History(cb, path)
+	if err != nil {
+		return err
+	}
+	kept := 0
+	for _, c := range changes {
+		if c.Path != path {
+			continue
+		}
+		kept++
+		if kept > historyLimit {
+			cb.Delete(c.entry)
+		}
+	}
+	return nil
+}
+
+// historyEntryPath returns the config key a history entry belongs to, and the
+// entry's name, or an empty key if it is not a history entry
+func historyEntryPath(entry string) (string, string) {
+	dir, name := path.Split(strings.TrimPrefix(entry, historyRoot))
+	if _, err := strconv.ParseInt(name, 10, 64); err != nil {
+		return "", ""
+	}
+	return strings.TrimSuffix(dir, "/"), name
+}
+
+// summarize shortens a value that is too long to print on one line, such as
+// the contents of a certificate
+func summarize(value string) string {
+	if strings.Contains(value, "\n") || len(value) > 60 {
+		return fmt.Sprintf("(%d bytes)", len(value))
+	}
+	return strconv.Quote(value)
+}
+
+type byTime []*Change
+
+func (c byTime) Len() int      { return len(c) }
+func (c byTime) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
+func (c byTime) Less(i, j int) bool {
+	if c[i].Time.Equal(c[j].Time) {
+		return c[i].entry < c[j].entry
+	}
+	return c[i].Time.Before(c[j].Time)
+}
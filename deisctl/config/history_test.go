@@ -0,0 +1,73 @@
+package config
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/deis/deis/deisctl/config/model"
+	"github.com/deis/deis/deisctl/test/mock"
+)
+
+func TestHistoryRollback(t *testing.T) {
+	t.Parallel()
+
+	cb := mock.NewMemoryBackend(&model.ConfigNode{Key: "/deis/controller/workers", Value: "0"})
+	testWriter := bytes.Buffer{}
+
+	for _, v := range []string{"1", "2"} {
+		if err := doConfig("controller", "set", []string{"workers=" + v}, false, cb, &testWriter); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := doConfig("controller", "set", []string{"registrationMode=admin_only"}, false, cb, &testWriter); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := History(cb, "/deis/controller")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 3 || changes[0].Path != "/deis/controller/registrationMode" || changes[0].Existed {
+		t.Fatalf("Expected 3 changes, newest first, Got %v", changes)
+	}
+
+	// rolling back walks back through the previous values
+	for _, expected := range []string{"1", "0"} {
+		if _, err := Rollback(cb, "/deis/controller/workers"); err != nil {
+			t.Fatal(err)
+		}
+		if value, _ := cb.Get("/deis/controller/workers"); value != expected {
+			t.Errorf("Expected '%s', Got '%s'", expected, value)
+		}
+	}
+	if _, err := Rollback(cb, "/deis/controller/workers"); err == nil {
+		t.Error("Expected an error once there is no more history")
+	}
+
+	// rolling back a key that didn't exist removes it
+	if _, err := Rollback(cb, "/deis/controller/registrationMode"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cb.Get("/deis/controller/registrationMode"); err == nil {
+		t.Error("Expected registrationMode to be removed")
+	}
+}
+
+func TestHistoryLimit(t *testing.T) {
+	t.Parallel()
+
+	cb := mock.NewMemoryBackend()
+	for i := 0; i < historyLimit+5; i++ {
+		if _, err := Set(cb, "/deis/router/bodySize", strings.Repeat("1", i+1)+"m"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	changes, err := History(cb, "/deis/router/bodySize")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != historyLimit {
+		t.Errorf("Expected %d changes, Got %d", historyLimit, len(changes))
+	}
+}
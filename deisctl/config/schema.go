@@ -0,0 +1,341 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Type is the kind of value a config key holds
+type Type string
+
+// The types of config values
+const (
+	String   Type = "string"
+	Int      Type = "int"
+	Bool     Type = "bool"
+	OnOff    Type = "on/off"
+	Enum     Type = "enum"
+	Size     Type = "size"
+	Duration Type = "duration"
+	File     Type = "file"
+)
+
+var (
+	sizePattern     = regexp.MustCompile(`^[0-9]+([kKmMgG][bB]?)?$`)
+	durationPattern = regexp.MustCompile(`^[0-9]+(ms|s|m|h|d|w|M|y)?$`)
+)
+
+// Key describes a config key a component reads
+type Key struct {
+	// Name is the key under /deis/<target>/. It may contain a * wildcard.
+	Name string
+	Type Type
+	// Values are the allowed values of an Enum
+	Values      []string
+	Default     string
+	Description string
+}
+
+// Validate returns an error if value is not valid for the key
+func (k Key) Validate(value string) error {
+	var ok bool
+	switch k.Type {
+	case Int:
+		_, err := strconv.Atoi(value)
+		ok = err == nil
+	case Bool:
+		ok = value == "true" || value == "false"
+	case OnOff:
+		ok = value == "on" || value == "off"
+	case Enum:
+		for _, v := range k.Values {
+			ok = ok || value == v
+		}
+	case Size:
+		ok = sizePattern.MatchString(value)
+	case Duration:
+		ok = durationPattern.MatchString(value)
+	default:
+		ok = true
+	}
+	if !ok {
+		return fmt.Errorf("invalid value %q for %s: expected %s", value, k.Name, k.expected())
+	}
+	return nil
+}
+
+// expected describes the values the key accepts
+func (k Key) expected() string {
+	switch k.Type {
+	case Int:
+		return "an integer"
+	case Bool:
+		return "true or false"
+	case OnOff:
+		return "on or off"
+	case Enum:
+		return "one of " + strings.Join(k.Values, ", ")
+	case Size:
+		return "a size, such as 1m or 512k"
+	case Duration:
+		return "a duration, such as 10m or 30s"
+	case File:
+		return "a path to a file"
+	}
+	return "a string"
+}
+
+func (k Key) String() string {
+	s := fmt.Sprintf("%-32s %-8s %s", k.Name, k.Type, k.Description)
+	if k.Type == Enum {
+		s += " (" + strings.Join(k.Values, ", ") + ")"
+	}
+	if k.Default != "" {
+		s += fmt.Sprintf(" [default: %s]", k.Default)
+	}
+	return s
+}
+
+// image is the key a component's unit reads the Docker image to run from
+var image = Key{Name: "image", Type: String, Description: "Docker image to run instead of the release's image"}
+
+// Schema lists the keys each config target's components read. Keys that
+// components publish themselves, such as their host and port, are left out.
+var Schema = map[string][]Key{
+	"builder": {
+		image,
+		{Name: "registryEmail", Type: String, Description: "email used to push images to the registry"},
+		{Name: "registryPassword", Type: String, Description: "password used to push images to the registry"},
+		{Name: "registryUsername", Type: String, Description: "username used to push images to the registry"},
+	},
+	"cache": {
+		image,
+		{Name: "maxmemory", Type: Size, Default: "50mb", Description: "maximum memory used for caching"},
+	},
+	"controller": {
+		{Name: "auth/ldap/bind/dn", Type: String, Description: "full user for LDAP bind, blank for anonymous bind"},
+		{Name: "auth/ldap/bind/password", Type: String, Description: "password of the LDAP bind user"},
+		{Name: "auth/ldap/endpoint", Type: String, Description: "full LDAP endpoint, such as ldap://ldap.company.com"},
+		{Name: "auth/ldap/group/basedn", Type: String, Description: "base DN where LDAP groups are located"},
+		{Name: "auth/ldap/group/filter", Type: String, Description: "field used to locate LDAP groups"},
+		{Name: "auth/ldap/group/type", Type: String, Description: "type of LDAP groups, such as groupOfNames"},
+		{Name: "auth/ldap/user/basedn", Type: String, Description: "base DN where LDAP users are located"},
+		{Name: "auth/ldap/user/filter", Type: String, Description: "field matched with the Deis username"},
+		{Name: "builderKey", Type: String, Description: "used by builder to authenticate with the controller"},
+		image,
+		{Name: "protocol", Type: Enum, Values: []string{"http", "https"}, Default: "http", Description: "protocol for controller"},
+		{Name: "registrationMode", Type: Enum, Values: []string{"enabled", "disabled", "admin_only"}, Default: "enabled", Description: "who may register users"},
+		{Name: "schedulerModule", Type: Enum, Values: []string{"fleet", "k8s", "mesos_marathon", "swarm"}, Default: "fleet", Description: "scheduler backend"},
+		{Name: "schedulerOptions", Type: String, Description: "options passed to the scheduler, as a Python dict literal"},
+		{Name: "schedulerTarget", Type: String, Default: "/var/run/fleet.sock", Description: "address or socket the scheduler is reached on"},
+		{Name: "secretKey", Type: String, Description: "used for secrets"},
+		{Name: "subdomain", Type: String, Default: "deis", Description: "subdomain used by the router for API requests"},
+		{Name: "unitHostname", Type: Enum, Values: []string{"default", "application", "server"}, Default: "default", Description: "hostname assigned to application containers"},
+		{Name: "webEnabled", Type: Enum, Values: []string{"0", "1"}, Default: "0", Description: "enable controller web UI"},
+		{Name: "workers", Type: Int, Description: "number of web worker processes"},
+	},
+	"database": {
+		{Name: "adminPass", Type: String, Description: "database admin password"},
+		{Name: "adminUser", Type: String, Default: "postgres", Description: "database admin user"},
+		{Name: "bucketName", Type: String, Default: "db_wal", Description: "store bucket used for WAL logs and backups"},
+		{Name: "engine", Type: String, Default: "postgresql_psycopg2", Description: "database engine"},
+		image,
+		{Name: "name", Type: String, Default: "deis", Description: "database name"},
+		{Name: "password", Type: String, Description: "database password"},
+		{Name: "user", Type: String, Default: "deis", Description: "database user"},
+	},
+	"logger": {
+		image,
+	},
+	"logs": {
+		{Name: "drain", Type: String, Description: "syslog URI logs are also sent to"},
+		{Name: "host", Type: String, Description: "host of an external syslog server that replaces the logger component"},
+		{Name: "port", Type: Int, Default: "514", Description: "port of an external syslog server that replaces the logger component"},
+		{Name: "protocol", Type: Enum, Values: []string{"udp", "tcp"}, Default: "udp", Description: "protocol logspout sends logs with"},
+	},
+	"logspout": {
+		image,
+	},
+	"mesos-marathon": {
+		image,
+	},
+	"mesos-master": {
+		image,
+	},
+	"mesos-slave": {
+		image,
+	},
+	"platform": {
+		{Name: "domain", Type: String, Description: "domain applications and the controller are served on"},
+		{Name: "enablePlacementOptions", Type: Bool, Default: "false", Description: "schedule components on machines with matching metadata"},
+		{Name: "placement/*", Type: String, Description: "machine metadata a component's units run on, as key=value pairs"},
+		{Name: "secretsKey", Type: String, Description: "encrypts app config set with \"deis config:set --secret\""},
+		{Name: "sshPrivateKey", Type: File, Description: "private key used to reach hosts, read from a local file"},
+		{Name: "version", Type: String, Description: "release whose images components run, unless their image is set"},
+	},
+	"publisher": {
+		image,
+	},
+	"registry": {
+		{Name: "bucketName", Type: String, Default: "registry", Description: "store bucket used for image layers"},
+		image,
+		{Name: "protocol", Type: Enum, Values: []string{"http", "https"}, Default: "http", Description: "protocol for registry"},
+		{Name: "s3accessKey", Type: String, Description: "access key of the S3 bucket storing image layers"},
+		{Name: "s3bucket", Type: String, Description: "S3 bucket storing image layers, instead of store-gateway"},
+		{Name: "s3encrypt", Type: Bool, Default: "true", Description: "encrypt image layers stored in S3"},
+		{Name: "s3path", Type: String, Default: "/registry", Description: "path in the S3 bucket image layers are stored under"},
+		{Name: "s3region", Type: String, Description: "region of the S3 bucket storing image layers"},
+		{Name: "s3secretKey", Type: String, Description: "secret key of the S3 bucket storing image layers"},
+		{Name: "s3secure", Type: Bool, Default: "true", Description: "reach S3 over HTTPS"},
+		{Name: "smtpFrom", Type: String, Default: "docker-registry@localdomain.local", Description: "sender of registry exception emails"},
+		{Name: "smtpHost", Type: String, Description: "SMTP server registry exceptions are emailed through"},
+		{Name: "smtpLogin", Type: String, Description: "SMTP user"},
+		{Name: "smtpPassword", Type: String, Description: "SMTP password"},
+		{Name: "smtpPort", Type: Int, Default: "25", Description: "SMTP port"},
+		{Name: "smtpSecure", Type: Bool, Default: "false", Description: "reach the SMTP server over TLS"},
+		{Name: "smtpTo", Type: String, Default: "noise+dockerregistry@localdomain.local", Description: "recipient of registry exception emails"},
+		{Name: "swiftAuthURL", Type: String, Description: "Keystone URL of the Swift cluster storing image layers, instead of store-gateway"},
+		{Name: "swiftContainer", Type: String, Description: "Swift container storing image layers"},
+		{Name: "swiftPassword", Type: String, Description: "Swift password"},
+		{Name: "swiftRegionName", Type: String, Description: "Swift region"},
+		{Name: "swiftTenantName", Type: String, Description: "Swift tenant"},
+		{Name: "swiftUser", Type: String, Description: "Swift user"},
+	},
+	"router": {
+		{Name: "affinityArg", Type: String, Description: "query string variable hashed for session affinity"},
+		{Name: "bodySize", Type: Size, Default: "1m", Description: "nginx client_max_body_size"},
+		{Name: "builder/timeout/connect", Type: Int, Default: "10000", Description: "builder proxy_connect_timeout, in milliseconds"},
+		{Name: "builder/timeout/tcp", Type: Int, Default: "1200000", Description: "builder proxy_timeout, in milliseconds"},
+		{Name: "controller/timeout/connect", Type: Duration, Default: "10m", Description: "controller proxy_connect_timeout"},
+		{Name: "controller/timeout/read", Type: Duration, Default: "20m", Description: "controller proxy_read_timeout"},
+		{Name: "controller/timeout/send", Type: Duration, Default: "20m", Description: "controller proxy_send_timeout"},
+		{Name: "controller/whitelist", Type: String, Description: "comma separated IPs or CIDRs allowed to reach the controller"},
+		{Name: "defaultTimeout", Type: Int, Default: "1300", Description: "default timeout in seconds"},
+		{Name: "enforceHTTPS", Type: Bool, Default: "false", Description: "redirect all HTTP traffic to HTTPS"},
+		{Name: "enforceWhitelist", Type: Bool, Default: "false", Description: "deny connections unless whitelisted"},
+		{Name: "errorLogLevel", Type: Enum, Values: []string{"debug", "info", "notice", "warn", "error", "crit", "alert", "emerg"}, Default: "error", Description: "nginx error_log level"},
+		{Name: "firewall/enabled", Type: Bool, Default: "false", Description: "enable the naxsi firewall"},
+		{Name: "firewall/errorCode", Type: Int, Default: "400", Description: "status returned for requests the firewall denies"},
+		{Name: "gzip", Type: OnOff, Default: "on", Description: "nginx gzip"},
+		{Name: "gzipCompLevel", Type: Enum, Values: []string{"1", "2", "3", "4", "5", "6", "7", "8", "9"}, Default: "5", Description: "nginx gzip_comp_level"},
+		{Name: "gzipDisable", Type: String, Default: "msie6", Description: "nginx gzip_disable"},
+		{Name: "gzipHttpVersion", Type: Enum, Values: []string{"1.0", "1.1"}, Default: "1.1", Description: "nginx gzip_http_version"},
+		{Name: "gzipMinLength", Type: Int, Default: "256", Description: "nginx gzip_min_length"},
+		{Name: "gzipProxied", Type: String, Default: "any", Description: "nginx gzip_proxied"},
+		{Name: "gzipTypes", Type: String, Description: "nginx gzip_types"},
+		{Name: "gzipVary", Type: OnOff, Default: "on", Description: "nginx gzip_vary"},
+		{Name: "hsts/enabled", Type: Bool, Default: "false", Description: "send Strict-Transport-Security headers"},
+		{Name: "hsts/includeSubDomains", Type: Bool, Default: "false", Description: "enforce HSTS on all subdomains"},
+		{Name: "hsts/maxAge", Type: Int, Default: "10886400", Description: "seconds user agents observe HSTS"},
+		{Name: "hsts/preload", Type: Bool, Default: "false", Description: "allow the domain in the HSTS preload list"},
+		image,
+		{Name: "maxWorkerConnections", Type: Int, Default: "768", Description: "nginx worker_connections"},
+		{Name: "proxyProtocol", Type: Bool, Default: "false", Description: "accept the PROXY protocol from a load balancer"},
+		{Name: "proxyRealIpCidr", Type: String, Default: "10.0.0.0/8", Description: "CIDR of the load balancer in front of the router"},
+		{Name: "serverNameHashBucketSize", Type: Int, Default: "64", Description: "nginx server_names_hash_bucket_size"},
+		{Name: "serverNameHashMaxSize", Type: Int, Default: "512", Description: "nginx server_names_hash_max_size"},
+		{Name: "sslBufferSize", Type: Size, Default: "4k", Description: "nginx ssl_buffer_size"},
+		{Name: "sslCert", Type: File, Description: "cluster-wide SSL certificate, read from a local file"},
+		{Name: "sslCiphers", Type: String, Description: "cluster-wide enabled SSL ciphers"},
+		{Name: "sslDhparam", Type: File, Description: "cluster-wide SSL dhparam, read from a local file"},
+		{Name: "sslKey", Type: File, Description: "cluster-wide SSL private key, read from a local file"},
+		{Name: "sslProtocols", Type: String, Default: "TLSv1 TLSv1.1 TLSv1.2", Description: "nginx ssl_protocols"},
+		{Name: "sslSessionCache", Type: String, Description: "nginx ssl_session_cache"},
+		{Name: "sslSessionTickets", Type: OnOff, Default: "on", Description: "nginx ssl_session_tickets"},
+		{Name: "sslSessionTimeout", Type: Duration, Default: "10m", Description: "nginx ssl_session_timeout"},
+		{Name: "workerProcesses", Type: String, Default: "auto", Description: "nginx worker_processes"},
+	},
+	"store": {
+		{Name: "delayStart", Type: Int, Default: "15", Description: "seconds Ceph delays OSD recovery after a restart"},
+		{Name: "gateway/accessKey", Type: String, Description: "S3 API access key of store-gateway, generated by Ceph unless set"},
+		{Name: "gateway/secretKey", Type: String, Description: "S3 API secret key of store-gateway, generated by Ceph unless set"},
+		{Name: "maxPGsPerOSDWarning", Type: Int, Default: "1536", Description: "placement groups per OSD above which Ceph warns"},
+		{Name: "minSize", Type: Int, Description: "store daemons needed for the cluster to accept writes"},
+		{Name: "pgNum", Type: Int, Description: "number of Ceph placement groups for the storage pools"},
+		{Name: "size", Type: Int, Description: "number of replicas of data stored in Ceph"},
+	},
+	"store-admin": {
+		image,
+	},
+	"store-daemon": {
+		image,
+	},
+	"store-gateway": {
+		image,
+	},
+	"store-metadata": {
+		image,
+	},
+	"store-monitor": {
+		image,
+	},
+	"swarm": {
+		image,
+	},
+	"zookeeper": {
+		image,
+	},
+}
+
+// Describe returns the keys of a config target, ordered by name
+func Describe(target string) ([]Key, error) {
+	keys, ok := Schema[target]
+	if !ok {
+		return nil, fmt.Errorf("unknown config target %s, expected one of %s", target, strings.Join(targets(), ", "))
+	}
+	sorted := append([]Key{}, keys...)
+	sort.Sort(byName(sorted))
+	return sorted, nil
+}
+
+// Validate returns an error unless key is in the target's schema and value is
+// valid for it
+func Validate(target, key, value string) error {
+	keys, err := Describe(target)
+	if err != nil {
+		return err
+	}
+	if k, ok := schemaKey(keys, key); ok {
+		return k.Validate(value)
+	}
+	for _, k := range keys {
+		if strings.EqualFold(k.Name, key) {
+			return fmt.Errorf("unknown key %s for %s, did you mean %s?", key, target, k.Name)
+		}
+	}
+	return fmt.Errorf("unknown key %s for %s, see \"deisctl config %s describe\"", key, target, target)
+}
+
+// inSchema returns whether key is in the target's schema
+func inSchema(target, key string) bool {
+	_, ok := schemaKey(Schema[target], key)
+	return ok
+}
+
+func schemaKey(keys []Key, key string) (Key, bool) {
+	for _, k := range keys {
+		if matched, _ := path.Match(k.Name, key); matched {
+			return k, true
+		}
+	}
+	return Key{}, false
+}
+
+func targets() []string {
+	names := make([]string, 0, len(Schema))
+	for name := range Schema {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type byName []Key
+
+func (k byName) Len() int           { return len(k) }
+func (k byName) Swap(i, j int)      { k[i], k[j] = k[j], k[i] }
+func (k byName) Less(i, j int) bool { return k[i].Name < k[j].Name }
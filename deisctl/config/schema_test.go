@@ -0,0 +1,182 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	valid := [][3]string{
+		{"router", "bodySize", "20m"},
+		{"router", "gzip", "off"},
+		{"router", "hsts/maxAge", "3600"},
+		{"router", "sslSessionTimeout", "5m"},
+		{"router", "errorLogLevel", "warn"},
+		{"controller", "registrationMode", "admin_only"},
+		{"platform", "placement/router", "router=true"},
+		{"platform", "enablePlacementOptions", "true"},
+		{"cache", "maxmemory", "128mb"},
+	}
+	for _, v := range valid {
+		if err := Validate(v[0], v[1], v[2]); err != nil {
+			t.Errorf("Expected %s %s=%s to be valid, Got %v", v[0], v[1], v[2], err)
+		}
+	}
+
+	invalid := [][3]string{
+		{"router", "bodySize", "big"},
+		{"router", "gzip", "true"},
+		{"router", "hsts/maxAge", "1h"},
+		{"router", "errorLogLevel", "verbose"},
+		{"router", "enforceHTTPS", "yes"},
+		{"controller", "registrationMode", "closed"},
+		{"controller", "unknownKey", "value"},
+		{"unknown", "key", "value"},
+	}
+	for _, v := range invalid {
+		if err := Validate(v[0], v[1], v[2]); err == nil {
+			t.Errorf("Expected %s %s=%s to be invalid", v[0], v[1], v[2])
+		}
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	t.Parallel()
+
+	keys, err := Describe("controller")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < len(keys); i++ {
+		if keys[i-1].Name > keys[i].Name {
+			t.Fatalf("Expected keys ordered by name, Got %s before %s", keys[i-1].Name, keys[i].Name)
+		}
+	}
+
+	var found bool
+	for _, k := range keys {
+		if k.Name == "registrationMode" {
+			found = true
+			if s := k.String(); !strings.Contains(s, "admin_only") || !strings.Contains(s, "[default: enabled]") {
+				t.Errorf("Expected allowed values and default, Got %s", s)
+			}
+		}
+	}
+	if !found {
+		t.Error("Expected registrationMode to be described")
+	}
+}
+
+// published are the keys templates read that components or the platform set
+// themselves, rather than users
+var published = []string{
+	"/deis/*/host",
+	"/deis/*/port",
+	"/deis/scheduler/*/*",
+	"/deis/store/adminKeyring",
+	"/deis/store/fsid",
+	"/deis/store/gateway/host",
+	"/deis/store/gateway/port",
+	"/deis/store/hosts/*",
+	"/deis/store/monKeyring",
+	"/deis/store/monSetupLock",
+}
+
+func TestSchemaCoversTemplates(t *testing.T) {
+	t.Parallel()
+
+	templateDirs := []string{
+		"../../builder/rootfs/etc/confd/templates",
+		"../../controller/templates",
+		"../../database/templates",
+		"../../registry/templates",
+		"../../router/rootfs/etc/confd/templates",
+		"../../store/base/templates",
+	}
+	keyRegex := regexp.MustCompile(`(?:getv|exists|gets) "/deis/([^/"]+)/([^"]+)"`)
+
+	for _, dir := range templateDirs {
+		for path, data := range readFiles(t, dir, "") {
+			for _, m := range keyRegex.FindAllStringSubmatch(string(data), -1) {
+				key := "/deis/" + m[1] + "/" + m[2]
+				if !isPublished(key) && !inSchema(m[1], m[2]) {
+					t.Errorf("%s reads %s, which is not in the schema", path, key)
+				}
+			}
+		}
+	}
+}
+
+func TestSchemaCoversDocs(t *testing.T) {
+	t.Parallel()
+
+	commandRegex := regexp.MustCompile("deisctl config ([a-z-]+) set ([^\n`]*)")
+	continuedRegex := regexp.MustCompile(`\\\n\s*`)
+	placeholderRegex := regexp.MustCompile(`[$<]`)
+
+	for path, data := range readFiles(t, "../../docs", ".rst") {
+		// join commands continued over several lines
+		text := continuedRegex.ReplaceAllString(string(data), " ")
+		for _, m := range commandRegex.FindAllStringSubmatch(text, -1) {
+			args := strings.Fields(m[2])
+			if hasForce(args) {
+				continue
+			}
+			for _, arg := range args {
+				kv := strings.SplitN(arg, "=", 2)
+				if len(kv) != 2 {
+					break
+				}
+				value := strings.Trim(kv[1], `"'`)
+				if placeholderRegex.MatchString(value) {
+					if !inSchema(m[1], kv[0]) {
+						t.Errorf("%s sets %s %s, which is not in the schema", path, m[1], kv[0])
+					}
+				} else if err := Validate(m[1], kv[0], value); err != nil {
+					t.Errorf("%s: %v", path, err)
+				}
+			}
+		}
+	}
+}
+
+func isPublished(key string) bool {
+	for _, pattern := range published {
+		if matched, _ := filepath.Match(pattern, key); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func hasForce(args []string) bool {
+	for _, arg := range args {
+		if arg == "--force" {
+			return true
+		}
+	}
+	return false
+}
+
+// readFiles returns the contents of the files under dir with the extension ext
+func readFiles(t *testing.T, dir, ext string) map[string][]byte {
+	files := make(map[string][]byte)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(path, ext) {
+			return err
+		}
+		data, err := ioutil.ReadFile(path)
+		files[path] = data
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
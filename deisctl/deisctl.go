@@ -30,8 +30,10 @@ Usage: deisctl [options] <command> [<args>...]
 
 Commands, use "deisctl help <command>" to learn more:
   install           install components, or the entire platform
+  apply             install, start and configure the platform from a manifest
   uninstall         uninstall components
   list              list installed components
+  list-unit-files   list the unit files loaded into the cluster
   start             start components
   stop              stop components
   restart           stop, then start components
@@ -39,13 +41,14 @@ Commands, use "deisctl help <command>" to learn more:
   scale             grow or shrink the number of routers, registries or store gateways
   journal           print the log output of a component
   config            set platform or component values
+  doctor            diagnose problems with the platform
   refresh-units     refresh unit files from GitHub
   ssh               open an interactive shell on a machine in the cluster
   dock              open an interactive shell on a container in the cluster
   help              show the help screen for a command
   upgrade-prep      prepare a running cluster for upgrade
   upgrade-takeover  allow an upgrade to gracefully takeover a running cluster
-  rolling-restart   perform a rolling restart of a Deis component (currently only router is supported)
+  rolling-restart   perform a rolling restart of a scalable Deis component
 
 Options:
   -h --help                   show this help screen
@@ -95,6 +98,8 @@ Options:
 	switch command {
 	case "list":
 		err = c.List(argv)
+	case "list-unit-files":
+		err = c.ListUnitFiles(argv)
 	case "scale":
 		err = c.Scale(argv)
 	case "start":
@@ -109,10 +114,14 @@ Options:
 		err = c.Journal(argv)
 	case "install":
 		err = c.Install(argv)
+	case "apply":
+		err = c.Apply(argv)
 	case "uninstall":
 		err = c.Uninstall(argv)
 	case "config":
 		err = c.Config(argv)
+	case "doctor":
+		err = c.Doctor(argv)
 	case "refresh-units":
 		err = c.RefreshUnits(argv)
 	case "ssh":
@@ -3,6 +3,7 @@ package mock
 import (
 	"fmt"
 	"regexp"
+	"strings"
 
 	"github.com/deis/deis/deisctl/config/model"
 )
@@ -65,7 +66,7 @@ func (cb ConfigBackend) GetRecursive(key string) ([]*model.ConfigNode, error) {
 	var configNodes []*model.ConfigNode
 
 	for _, expect := range cb.Expected {
-		if r.MatchString(expect.Key) {
+		if r.MatchString(expect.Key) || strings.HasPrefix(expect.Key, strings.TrimSuffix(key, "/")+"/") {
 			configNodes = append(configNodes, expect)
 		}
 	}
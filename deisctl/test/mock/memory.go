@@ -0,0 +1,102 @@
+package mock
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/deis/deis/deisctl/config/model"
+)
+
+// MemoryBackend is an in memory config datastore that behaves like etcd, for
+// tests that set keys which don't exist yet
+type MemoryBackend struct {
+	mutex sync.Mutex
+	store map[string]*model.ConfigNode
+}
+
+// NewMemoryBackend returns a MemoryBackend holding the given nodes
+func NewMemoryBackend(nodes ...*model.ConfigNode) *MemoryBackend {
+	cb := &MemoryBackend{store: make(map[string]*model.ConfigNode)}
+	for _, node := range nodes {
+		cb.store[node.Key] = node
+	}
+	return cb
+}
+
+// Get a value by key from an in memory config backend
+func (cb *MemoryBackend) Get(key string) (string, error) {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	node, ok := cb.store[key]
+	if !ok {
+		return "", fmt.Errorf("%s does not exist", key)
+	}
+	return node.Value, nil
+}
+
+// GetWithDefault gets a value by key from an in memory config backend and
+// return a default value if not found
+func (cb *MemoryBackend) GetWithDefault(key string, defaultValue string) (string, error) {
+	value, err := cb.Get(key)
+	if err != nil {
+		return defaultValue, nil
+	}
+	return value, nil
+}
+
+// Set a value for the specified key in an in memory config backend
+func (cb *MemoryBackend) Set(key, value string) (string, error) {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	cb.store[key] = &model.ConfigNode{Key: key, Value: value}
+	return value, nil
+}
+
+// SetWithTTL sets a value for an existing key in an in memory config
+// backend-- with a time to live
+func (cb *MemoryBackend) SetWithTTL(key string, value string, ttl uint64) (string, error) {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	if _, ok := cb.store[key]; !ok {
+		return "", fmt.Errorf("%s does not exist", key)
+	}
+	cb.store[key] = &model.ConfigNode{Key: key, Value: value, TTL: int64(ttl)}
+	return value, nil
+}
+
+// Delete a key/value pair by key from an in memory config backend
+func (cb *MemoryBackend) Delete(key string) error {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	if _, ok := cb.store[key]; !ok {
+		return fmt.Errorf("%s does not exist", key)
+	}
+	delete(cb.store, key)
+	return nil
+}
+
+// GetRecursive returns a slice of all key/value pairs "under" a specified key
+// in an in memory config backend, ordered by key
+func (cb *MemoryBackend) GetRecursive(key string) ([]*model.ConfigNode, error) {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	prefix := "/" + strings.Trim(key, "/") + "/"
+	var keys []string
+	for k := range cb.store {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s does not exist", key)
+	}
+	sort.Strings(keys)
+	nodes := make([]*model.ConfigNode, len(keys))
+	for i, k := range keys {
+		node := *cb.store[k]
+		nodes[i] = &node
+	}
+	return nodes, nil
+}
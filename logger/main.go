@@ -3,16 +3,13 @@ package main
 import (
 	"flag"
 	"fmt"
-	"log"
-	"os"
-	"os/signal"
-	"strconv"
-	"strings"
-	"syscall"
-	"time"
-
-	"github.com/coreos/go-etcd/etcd"
+
 	"github.com/deis/deis/logger/syslogd"
+	"github.com/deis/deis/pkg/boot"
+	"github.com/deis/deis/pkg/boot/types"
+	"github.com/deis/deis/pkg/etcd"
+	logger "github.com/deis/deis/pkg/log"
+	oswrapper "github.com/deis/deis/pkg/os"
 )
 
 var (
@@ -20,11 +17,13 @@ var (
 	logPort         int
 	drainURI        string
 	enablePublish   bool
-	publishHost     string
 	publishPath     string
-	publishPort     string
 	publishInterval int
-	publishTTL      int
+
+	log         = logger.New()
+	drainChan   = make(chan string)
+	exitChan    = make(chan bool)
+	cleanupChan = make(chan bool)
 )
 
 func init() {
@@ -33,78 +32,109 @@ func init() {
 	flag.StringVar(&drainURI, "drain-uri", "", "default drainURI, once set in etcd, this has no effect.")
 	flag.StringVar(&syslogd.LogRoot, "log-root", "/data/logs", "log path to store logs")
 	flag.BoolVar(&enablePublish, "enable-publish", false, "enable publishing to service discovery")
-	flag.StringVar(&publishHost, "publish-host", getopt("HOST", "127.0.0.1"), "service discovery hostname")
-	flag.IntVar(&publishInterval, "publish-interval", 10, "publish interval in seconds")
-	flag.StringVar(&publishPath, "publish-path", getopt("ETCD_PATH", "/deis/logs"), "path to publish host/port information")
-	flag.StringVar(&publishPort, "publish-port", getopt("ETCD_PORT", "4001"), "service discovery port")
-	flag.IntVar(&publishTTL, "publish-ttl", publishInterval*2, "publish TTL in seconds")
+	flag.IntVar(&publishInterval, "publish-interval", 10, "interval in seconds between checks for a new drain URI")
+	flag.StringVar(&publishPath, "publish-path", oswrapper.Getopt("ETCD_PATH", "/deis/logs"), "path to publish host/port information")
+
+	boot.RegisterComponent(new(LoggerBoot), "boot")
 }
 
 func main() {
 	flag.Parse()
+	boot.Start(publishPath, logPort)
+}
+
+// LoggerBoot struct to boot the syslog server.
+type LoggerBoot struct{}
 
-	client := etcd.NewClient([]string{"http://" + publishHost + ":" + publishPort})
-	ticker := time.NewTicker(time.Duration(publishInterval) * time.Second)
-	signalChan := make(chan os.Signal, 1)
-	drainChan := make(chan string)
-	exitChan := make(chan bool)
-	cleanupChan := make(chan bool)
-	signal.Notify(signalChan, syscall.SIGTERM, syscall.SIGINT)
-
-	// ensure the drain key exists in etcd.
-	if _, err := client.Get(publishPath+"/drain", false, false); err != nil {
-		setEtcd(client, publishPath+"/drain", drainURI, 0)
+// MkdirsEtcd creates a directory in etcd.
+func (lb *LoggerBoot) MkdirsEtcd() []string {
+	return []string{publishPath}
+}
+
+// EtcdDefaults ensures the drain key exists in etcd.
+func (lb *LoggerBoot) EtcdDefaults() map[string]string {
+	return map[string]string{
+		publishPath + "/drain": drainURI,
 	}
+}
 
+// PreBoot starts the syslog server.
+func (lb *LoggerBoot) PreBoot(currentBoot *types.CurrentBoot) {
 	go syslogd.Listen(exitChan, cleanupChan, drainChan, fmt.Sprintf("%s:%d", logAddr, logPort))
-	if enablePublish {
-		publishKeys(client, publishHost, publishPath, strconv.Itoa(logPort), uint64(time.Duration(publishTTL)*time.Second))
-	}
+}
+
+// PreBootScripts runs preboot scripts.
+func (lb *LoggerBoot) PreBootScripts(currentBoot *types.CurrentBoot) []*types.Script {
+	return []*types.Script{}
+}
+
+// UseConfd does not use confd.
+func (lb *LoggerBoot) UseConfd() (bool, bool) {
+	return false, false
+}
+
+// BootDaemons returns no daemons, since the syslog server runs in this process.
+func (lb *LoggerBoot) BootDaemons(currentBoot *types.CurrentBoot) []*types.ServiceDaemon {
+	return []*types.ServiceDaemon{}
+}
+
+// WaitForPorts returns no ports, since syslog listens on UDP.
+func (lb *LoggerBoot) WaitForPorts() []int {
+	return []int{}
+}
 
-	for {
-		select {
-		case <-ticker.C:
-			if enablePublish {
-				publishKeys(client, publishHost, publishPath, strconv.Itoa(logPort), uint64(time.Duration(publishTTL)*time.Second))
+// HealthChecks checks that the syslog server is bound to its port.
+func (lb *LoggerBoot) HealthChecks(currentBoot *types.CurrentBoot) []*types.HealthCheck {
+	return []*types.HealthCheck{
+		&types.HealthCheck{Name: "syslog", Check: func() error {
+			if !syslogd.Listening() {
+				return fmt.Errorf("not listening on %s:%d", logAddr, logPort)
 			}
+			return nil
+		}},
+	}
+}
+
+// PublishKeys publishes the logger's host and port, if publishing is enabled.
+func (lb *LoggerBoot) PublishKeys(currentBoot *types.CurrentBoot) map[string]string {
+	if !enablePublish {
+		return map[string]string{}
+	}
+	return currentBoot.HostPortKeys(currentBoot.EtcdPath)
+}
+
+// PostBootScripts returns type script.
+func (lb *LoggerBoot) PostBootScripts(currentBoot *types.CurrentBoot) []*types.Script {
+	return []*types.Script{}
+}
+
+// PostBoot does nothing, since the syslog server logs when it is running.
+func (lb *LoggerBoot) PostBoot(currentBoot *types.CurrentBoot) {
+}
+
+// ScheduleTasks polls etcd for changes in the log drain.
+func (lb *LoggerBoot) ScheduleTasks(currentBoot *types.CurrentBoot) []*types.Cron {
+	return []*types.Cron{
+		&types.Cron{
+			Frequency: fmt.Sprintf("@every %ds", publishInterval),
 			// HACK (bacongobbler): poll etcd every publishInterval for changes in the log drain value.
 			// etcd's .Watch() implementation is broken when you use TTLs
 			//
 			// https://github.com/coreos/etcd/issues/2679
-			resp, err := client.Get(publishPath+"/drain", false, false)
-			if err != nil {
-				log.Printf("warning: could not retrieve drain URI from etcd: %v\n", err)
-				continue
-			}
-			if resp != nil && resp.Node != nil {
-				drainChan <- resp.Node.Value
-			}
-		case <-signalChan:
-			close(exitChan)
-		case <-cleanupChan:
-			ticker.Stop()
-			return
-		}
+			Code: func() {
+				drainChan <- etcd.Get(currentBoot.EtcdClient, publishPath+"/drain")
+			},
+		},
 	}
 }
 
-// publishKeys sets relevant etcd keys with a time-to-live.
-func publishKeys(client *etcd.Client, host, etcdPath, port string, ttl uint64) {
-	setEtcd(client, etcdPath+"/host", host, ttl)
-	setEtcd(client, etcdPath+"/port", port, ttl)
+// PreShutdown stops the syslog server once it has written the messages it received.
+func (lb *LoggerBoot) PreShutdown(currentBoot *types.CurrentBoot) {
+	close(exitChan)
+	<-cleanupChan
 }
 
-func setEtcd(client *etcd.Client, key, value string, ttl uint64) {
-	_, err := client.Set(key, value, ttl)
-	if err != nil && !strings.Contains(err.Error(), "Key already exists") {
-		log.Println(err)
-	}
-}
-
-func getopt(name, dfault string) string {
-	value := os.Getenv(name)
-	if value == "" {
-		value = dfault
-	}
-	return value
+// PreShutdownScripts returns type script.
+func (lb *LoggerBoot) PreShutdownScripts(currentBoot *types.CurrentBoot) []*types.Script {
+	return []*types.Script{}
 }
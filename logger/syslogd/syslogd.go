@@ -7,6 +7,7 @@ import (
 	"os"
 	"path"
 	"regexp"
+	"sync/atomic"
 
 	"github.com/deis/deis/logger/syslog"
 
@@ -16,6 +17,14 @@ import (
 // LogRoot is the log path to store logs.
 var LogRoot string
 
+// listening is set to 1 once the syslog server is bound to its address.
+var listening int32
+
+// Listening returns whether the syslog server is bound to its address.
+func Listening() bool {
+	return atomic.LoadInt32(&listening) == 1
+}
+
 type handler struct {
 	// To simplify implementation of our handler we embed helper
 	// syslog.BaseHandler struct.
@@ -114,7 +123,10 @@ func Listen(exitChan, cleanupDone chan bool, drainChan chan string, bindAddr str
 	s := syslog.NewServer()
 	h := newHandler()
 	s.AddHandler(h)
-	s.Listen(bindAddr)
+	if err := s.Listen(bindAddr); err != nil {
+		log.Fatalf("unable to listen on %s: %v", bindAddr, err)
+	}
+	atomic.StoreInt32(&listening, 1)
 	fmt.Println("Syslog server started...")
 	fmt.Println("deis-logger running")
 
@@ -124,6 +136,7 @@ func Listen(exitChan, cleanupDone chan bool, drainChan chan string, bindAddr str
 		case <-exitChan:
 			// Shutdown the server
 			fmt.Println("Shutting down...")
+			atomic.StoreInt32(&listening, 0)
 			s.Shutdown()
 			cleanupDone <- true
 		case d := <-drainChan:
@@ -2,52 +2,179 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"io"
 	"log"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/fsouza/go-dockerclient"
 )
 
 type AttachManager struct {
 	sync.Mutex
-	attached map[string]*LogPump
-	channels map[chan *AttachEvent]struct{}
-	client   *docker.Client
+	attached  map[string]*LogPump
+	attaching map[string]bool
+	channels  map[chan *AttachEvent]struct{}
+	client    *docker.Client
+	config    *AppConfig
+	// limit is the rate limit applied to each container, if set.
+	limit *RateLimit
+	// appLimits holds the rate limit shared by each app's containers.
+	appLimits map[string]*tokenBucket
 }
 
-func NewAttachManager(client *docker.Client) *AttachManager {
+// NewAttachManager attaches to all running containers, and to containers as they start.
+//
+// config may be nil, in which case apps have no log policies. limit may be
+// nil, in which case containers are not rate limited.
+func NewAttachManager(client *docker.Client, config *AppConfig, limit *RateLimit) *AttachManager {
 	m := &AttachManager{
-		attached: make(map[string]*LogPump),
-		channels: make(map[chan *AttachEvent]struct{}),
-		client:   client,
+		attached:  make(map[string]*LogPump),
+		attaching: make(map[string]bool),
+		channels:  make(map[chan *AttachEvent]struct{}),
+		client:    client,
+		config:    config,
+		limit:     limit,
+		appLimits: make(map[string]*tokenBucket),
 	}
-	containers, err := client.ListContainers(docker.ListContainersOptions{})
-	assert(err, "attacher")
-	for _, listing := range containers {
-		m.attach(listing.ID[:12])
+	go m.monitor()
+	return m
+}
+
+// ContainerInfo describes a container logspout is attached to.
+type ContainerInfo struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	AttachedAt time.Time `json:"attached_at"`
+}
+
+// Containers returns the containers currently attached.
+func (m *AttachManager) Containers() []*ContainerInfo {
+	m.Lock()
+	defer m.Unlock()
+	containers := make([]*ContainerInfo, 0, len(m.attached))
+	for id, pump := range m.attached {
+		containers = append(containers, &ContainerInfo{ID: id, Name: pump.Name, AttachedAt: pump.attachedAt})
 	}
-	go func() {
+	sort.Sort(byName(containers))
+	return containers
+}
+
+type byName []*ContainerInfo
+
+func (b byName) Len() int           { return len(b) }
+func (b byName) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byName) Less(i, j int) bool { return b[i].Name < b[j].Name }
+
+// monitor subscribes to Docker events and attaches to containers as they start.
+//
+// Whenever the event stream is lost, it resubscribes with backoff and
+// attaches to any containers that started in the meantime.
+func (m *AttachManager) monitor() {
+	backoff := minBackoff
+	for {
 		events := make(chan *docker.APIEvents)
-		assert(client.AddEventListener(events), "attacher")
-		for msg := range events {
+		err := m.client.AddEventListener(events)
+		if err == nil {
+			err = m.sync()
+			if err == nil {
+				backoff = minBackoff
+				err = m.watch(events)
+			}
+			m.unsubscribe(events)
+		}
+		log.Printf("attacher: docker events: %v (resubscribing in %s)", err, backoff)
+		time.Sleep(backoff)
+		backoff = nextBackoff(backoff)
+	}
+}
+
+// sync attaches to every running container that isn't already attached.
+func (m *AttachManager) sync() error {
+	containers, err := m.client.ListContainers(docker.ListContainersOptions{})
+	if err != nil {
+		return err
+	}
+	for _, listing := range containers {
+		go m.attach(listing.ID[:12])
+	}
+	return nil
+}
+
+// watch handles events until the event stream is lost.
+func (m *AttachManager) watch(events chan *docker.APIEvents) error {
+	ping := time.NewTicker(10 * time.Second)
+	defer ping.Stop()
+	for {
+		select {
+		case msg, ok := <-events:
+			if !ok {
+				return errors.New("event stream closed")
+			}
 			debug("event:", msg.ID[:12], msg.Status)
 			if msg.Status == "start" {
 				go m.attach(msg.ID[:12])
 			}
+		case <-ping.C:
+			// the docker client reconnects its event stream silently and
+			// gives up silently, so make sure the daemon is still there.
+			if err := m.client.Ping(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (m *AttachManager) unsubscribe(events chan *docker.APIEvents) {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		// keep draining so that removing the listener can't deadlock with a
+		// pending event.
+		for {
+			select {
+			case <-events:
+			case <-done:
+				return
+			}
 		}
-		log.Fatal("ruh roh") // todo: loop?
 	}()
-	return m
+	m.client.RemoveEventListener(events)
 }
 
+// attach streams a container's output to a new LogPump.
+//
+// Errors are logged rather than fatal, since containers routinely exit
+// before they can be inspected or attached to.
 func (m *AttachManager) attach(id string) {
+	m.Lock()
+	if _, ok := m.attached[id]; ok || m.attaching[id] {
+		m.Unlock()
+		return
+	}
+	m.attaching[id] = true
+	m.Unlock()
+	defer func() {
+		m.Lock()
+		delete(m.attaching, id)
+		m.Unlock()
+	}()
+
 	container, err := m.client.InspectContainer(id)
-	assert(err, "attacher")
-	name := container.Name[1:]
+	if err != nil {
+		log.Println("attacher:", id+":", err)
+		return
+	}
+	if !container.State.Running {
+		debug("attach:", id, "not running")
+		return
+	}
+	name := strings.TrimPrefix(container.Name, "/")
 	success := make(chan struct{})
-	failure := make(chan error)
+	failure := make(chan error, 1)
 	outrd, outwr := io.Pipe()
 	errrd, errwr := io.Pipe()
 	go func() {
@@ -75,15 +202,38 @@ func (m *AttachManager) attach(id string) {
 	}()
 	_, ok := <-success
 	if ok {
+		app := getAppName(name)
+		policy := m.config.Policy(app)
+		pump := NewLogPump(outrd, errrd, id, name, policy.Multiline, m.limiter(app, policy.RateLimit))
 		m.Lock()
-		m.attached[id] = NewLogPump(outrd, errrd, id, name)
+		m.attached[id] = pump
 		m.Unlock()
 		success <- struct{}{}
 		m.send(&AttachEvent{ID: id, Name: name, Type: "attach"})
 		debug("attach:", id, name, "success")
 		return
 	}
-	debug("attach:", id, "failure:", <-failure)
+	log.Println("attacher:", id+":", <-failure)
+}
+
+// limiter returns the rate limiter for a new container of app.
+func (m *AttachManager) limiter(app string, appLimit *RateLimit) RateLimiter {
+	var limiter RateLimiter
+	if m.limit != nil {
+		limiter = append(limiter, newTokenBucket(m.limit))
+	}
+	m.Lock()
+	defer m.Unlock()
+	if appLimit == nil {
+		delete(m.appLimits, app)
+		return limiter
+	}
+	bucket, ok := m.appLimits[app]
+	if !ok || bucket.limit != *appLimit {
+		bucket = newTokenBucket(appLimit)
+		m.appLimits[app] = bucket
+	}
+	return append(limiter, bucket)
 }
 
 func (m *AttachManager) send(event *AttachEvent) {
@@ -99,9 +249,13 @@ func (m *AttachManager) addListener(ch chan *AttachEvent) {
 	m.Lock()
 	defer m.Unlock()
 	m.channels[ch] = struct{}{}
+	events := make([]*AttachEvent, 0, len(m.attached))
+	for id, pump := range m.attached {
+		events = append(events, &AttachEvent{ID: id, Name: pump.Name, Type: "attach"})
+	}
 	go func() {
-		for id, pump := range m.attached {
-			ch <- &AttachEvent{ID: id, Name: pump.Name, Type: "attach"}
+		for _, event := range events {
+			ch <- event
 		}
 	}()
 }
@@ -137,12 +291,12 @@ func (m *AttachManager) Listen(source *Source, logstream chan *Log, closer <-cha
 				(source.Name != "" && event.Name == source.Name) ||
 				(source.Filter != "" && strings.Contains(event.Name, source.Filter))) {
 				pump := m.Get(event.ID)
+				if pump == nil {
+					// the container detached before we got here
+					continue
+				}
 				pump.AddListener(logstream)
-				defer func() {
-					if pump != nil {
-						pump.RemoveListener(logstream)
-					}
-				}()
+				defer pump.RemoveListener(logstream)
 			} else if source.ID != "" && event.Type == "detach" &&
 				strings.HasPrefix(event.ID, source.ID) {
 				return
@@ -155,33 +309,95 @@ func (m *AttachManager) Listen(source *Source, logstream chan *Log, closer <-cha
 
 type LogPump struct {
 	sync.Mutex
-	ID       string
-	Name     string
-	channels map[chan *Log]struct{}
+	ID         string
+	Name       string
+	channels   map[chan *Log]struct{}
+	attachedAt time.Time
 }
 
-func NewLogPump(stdout, stderr io.Reader, id, name string) *LogPump {
+// NewLogPump sends each line of a container's output to the pump's listeners.
+//
+// If policy is not nil, continuation lines are joined onto the line before
+// them and sent as one message. Messages the limiter does not allow are
+// dropped, and counted in a message sent every suppressedInterval.
+func NewLogPump(stdout, stderr io.Reader, id, name string, policy *MultilinePolicy, limiter RateLimiter) *LogPump {
 	obj := &LogPump{
-		ID:       id,
-		Name:     name,
-		channels: make(map[chan *Log]struct{}),
+		ID:         id,
+		Name:       name,
+		channels:   make(map[chan *Log]struct{}),
+		attachedAt: time.Now(),
 	}
 	pump := func(typ string, source io.Reader) {
-		buf := bufio.NewReader(source)
-		for {
-			data, err := buf.ReadBytes('\n')
-			if err != nil {
-				if err != io.EOF {
-					debug("pump:", id, typ+":", err)
+		lines := make(chan string)
+		go func() {
+			defer close(lines)
+			buf := bufio.NewReader(source)
+			for {
+				data, err := buf.ReadBytes('\n')
+				if err != nil {
+					if err != io.EOF {
+						debug("pump:", id, typ+":", err)
+					}
+					return
 				}
+				lines <- strings.TrimSuffix(string(data), "\n")
+			}
+		}()
+
+		var suppressed int
+		var report <-chan time.Time
+		if limiter != nil {
+			ticker := time.NewTicker(suppressedInterval)
+			defer ticker.Stop()
+			report = ticker.C
+		}
+		emit := func(logline *Log) {
+			if !limiter.Allow() {
+				suppressed++
 				return
 			}
-			obj.send(&Log{
-				Data: strings.TrimSuffix(string(data), "\n"),
-				ID:   id,
-				Name: name,
-				Type: typ,
-			})
+			obj.send(logline)
+		}
+		reportSuppressed := func() {
+			if suppressed > 0 {
+				obj.send(&Log{Data: suppressedMessage(suppressed), ID: id, Name: name, Type: typ})
+				suppressed = 0
+			}
+		}
+
+		var joiner *multilineJoiner
+		if policy != nil {
+			joiner = &multilineJoiner{policy: policy}
+		}
+		var timeout <-chan time.Time
+		flush := func() {
+			if pending := joiner.Flush(); pending != nil {
+				emit(pending)
+			}
+			timeout = nil
+		}
+		for {
+			select {
+			case line, ok := <-lines:
+				if !ok {
+					flush()
+					reportSuppressed()
+					return
+				}
+				logline := &Log{Data: line, ID: id, Name: name, Type: typ}
+				if joiner == nil {
+					emit(logline)
+					continue
+				}
+				if done := joiner.Add(logline); done != nil {
+					emit(done)
+				}
+				timeout = time.After(policy.Timeout)
+			case <-timeout:
+				flush()
+			case <-report:
+				reportSuppressed()
+			}
 		}
 	}
 	go pump("stdout", stdout)
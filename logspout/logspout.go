@@ -3,7 +3,6 @@ package main
 import (
 	"fmt"
 	"log"
-	"net"
 	"net/http"
 	"net/url"
 	"os"
@@ -14,7 +13,6 @@ import (
 	"time"
 
 	"github.com/coreos/go-etcd/etcd"
-	dtime "github.com/deis/deis/pkg/time"
 	"github.com/fsouza/go-dockerclient"
 	"github.com/go-martini/martini"
 	"golang.org/x/net/websocket"
@@ -58,42 +56,6 @@ func (c Colorizer) Get(key string) string {
 	return "\x1b[" + bright + "3" + strconv.Itoa(7-(i%7)) + "m"
 }
 
-func syslogStreamer(target Target, types []string, logstream chan *Log) {
-	typestr := "," + strings.Join(types, ",") + ","
-	for logline := range logstream {
-		if typestr != ",," && !strings.Contains(typestr, logline.Type) {
-			continue
-		}
-		tag, pid, data := getLogParts(logline)
-		var conn net.Conn
-		if strings.EqualFold(target.Protocol, "tcp") {
-			addr, err := net.ResolveTCPAddr("tcp", target.Addr)
-			assert(err, "syslog")
-			tcpconn, err := net.DialTCP("tcp", nil, addr)
-			assert(err, "syslog")
-			assert(tcpconn.SetWriteBuffer(1048576), "syslog")
-			conn = tcpconn
-		} else if strings.EqualFold(target.Protocol, "udp") {
-			addr, err := net.ResolveUDPAddr("udp", target.Addr)
-			assert(err, "syslog")
-			udpconn, err := net.DialUDP("udp", nil, addr)
-			assert(err, "syslog")
-			assert(udpconn.SetWriteBuffer(1048576), "syslog")
-			conn = udpconn
-		} else {
-			assert(fmt.Errorf("%s is not a supported protocol, use either udp or tcp", target.Protocol), "syslog")
-		}
-		// HACK: Go's syslog package hardcodes the log format, so let's send our own message
-		_, err := fmt.Fprintf(conn,
-			"%s %s[%s]: %s",
-			time.Now().Format(getopt("DATETIME_FORMAT", dtime.DeisDatetimeFormat)),
-			tag,
-			pid,
-			data)
-		assert(err, "syslog")
-	}
-}
-
 // getLogParts returns a custom tag and PID for containers that
 // match Deis' specific application name format. Otherwise,
 // it returns the original name and 1 as the PID.  Additionally,
@@ -119,6 +81,18 @@ func getLogParts(logline *Log) (string, string, string) {
 	return logline.Name, "1", logline.Data
 }
 
+// getAppName returns the name of the Deis application a container
+// belongs to, or the empty string for containers that are not part of one.
+func getAppName(name string) string {
+	if match := getMatch(`(^[a-z0-9-]+)_(v[0-9]+)\.([a-z-_]+\.[0-9]+)$`, name); match != nil {
+		return match[1]
+	}
+	if match := getMatch(`^k8s_([a-z0-9-]+)-[a-z]+\.[\da-f]+_[a-z0-9-]+-([a-z]+-[\da-z]*)_`, name); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
 func getMatch(regex string, name string) []string {
 	r := regexp.MustCompile(regex)
 	match := r.FindStringSubmatch(name)
@@ -200,24 +174,40 @@ func main() {
 	port := getopt("PORT", "8000")
 	endpoint := getopt("DOCKER_HOST", "unix:///var/run/docker.sock")
 	routespath := getopt("ROUTESPATH", "/var/lib/logspout")
+	etcdroutes := getopt("ETCD_ROUTESPATH", "/deis/logspout/routes")
+
+	var etcdClient *etcd.Client
+	var config *AppConfig
+	if etcdHost := os.Getenv("ETCD_HOST"); etcdHost != "" {
+		connectionString := []string{"http://" + etcdHost + ":4001"}
+		debug("etcd:", connectionString[0])
+		etcdClient = etcd.NewClient(connectionString)
+		etcdClient.SetDialTimeout(3 * time.Second)
+		config = NewAppConfig(etcdClient, "/deis/config")
+		assert(config.Load(), "appconfig")
+		go config.Watch()
+	}
+
+	var limit *RateLimit
+	if rate := os.Getenv("LOG_RATE_LIMIT"); rate != "" {
+		var err error
+		limit, err = parseRateLimit(rate, os.Getenv("LOG_RATE_BURST"))
+		assert(err, "LOG_RATE_LIMIT")
+	}
 
 	client, err := docker.NewClient(endpoint)
 	assert(err, "docker")
-	attacher := NewAttachManager(client)
+	attacher := NewAttachManager(client, config, limit)
 	router := NewRouteManager(attacher)
 
 	// HACK: if we are connecting to etcd, get the logger's connection
 	// details from there
-	if etcdHost := os.Getenv("ETCD_HOST"); etcdHost != "" {
-		connectionString := []string{"http://" + etcdHost + ":4001"}
-		debug("etcd:", connectionString[0])
-		etcd := etcd.NewClient(connectionString)
-		etcd.SetDialTimeout(3 * time.Second)
-		hostResp, err := etcd.Get("/deis/logs/host", false, false)
+	if etcdClient != nil {
+		hostResp, err := etcdClient.Get("/deis/logs/host", false, false)
 		assert(err, "url")
-		portResp, err := etcd.Get("/deis/logs/port", false, false)
+		portResp, err := etcdClient.Get("/deis/logs/port", false, false)
 		assert(err, "url")
-		protocol := getEtcdValueOrDefault(etcd, "/deis/logs/protocol", "udp")
+		protocol := getEtcdValueOrDefault(etcdClient, "/deis/logs/protocol", "udp")
 		host := fmt.Sprintf("%s:%s", hostResp.Node.Value, portResp.Node.Value)
 		log.Printf("routing all to %s://%s", protocol, host)
 		router.Add(&Route{Target: Target{Type: "syslog", Addr: host, Protocol: protocol}})
@@ -227,10 +217,33 @@ func main() {
 		u, err := url.Parse(os.Args[1])
 		assert(err, "url")
 		log.Println("routing all to " + os.Args[1])
-		router.Add(&Route{Target: Target{Type: u.Scheme, Addr: u.Host}})
+		target := Target{Type: u.Scheme, Addr: u.Host}
+		if u.Scheme == "http" || u.Scheme == "https" {
+			target.Addr = os.Args[1]
+		}
+		assert(validateTarget(target), "url")
+		router.Add(&Route{Target: target})
 	}
 
-	if _, err := os.Stat(routespath); err == nil {
+	if etcdClient != nil {
+		store := NewRouteEtcdStore(etcdClient, etcdroutes)
+		// carry over any routes persisted on this host before they were kept
+		// in etcd, unless etcd already has routes
+		if _, err := os.Stat(routespath); err == nil {
+			routes, err := RouteFileStore(routespath).GetAll()
+			assert(err, "persistor")
+			imported, err := store.Import(routes)
+			assert(err, "persistor")
+			if imported {
+				log.Printf("copied %d routes from %s to etcd", len(routes), routespath)
+			} else if len(routes) > 0 {
+				log.Printf("etcd already holds routes, ignoring %d routes in %s", len(routes), routespath)
+			}
+		}
+		log.Println("loading and persisting routes in etcd at " + etcdroutes)
+		assert(router.Load(store), "persistor")
+		go store.Watch(router)
+	} else if _, err := os.Stat(routespath); err == nil {
 		log.Println("loading and persisting routes in " + routespath)
 		assert(router.Load(RouteFileStore(routespath)), "persistor")
 	}
@@ -269,10 +282,19 @@ func main() {
 		attacher.Listen(source, logstream, closer)
 	})
 
+	m.Get("/containers", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		w.Write(append(marshal(attacher.Containers()), '\n'))
+	})
+
 	m.Get("/routes", func(w http.ResponseWriter, req *http.Request) {
 		w.Header().Add("Content-Type", "application/json")
 		routes, _ := router.GetAll()
-		w.Write(append(marshal(routes), '\n'))
+		statuses := make([]*RouteStatus, 0, len(routes))
+		for _, route := range routes {
+			statuses = append(statuses, route.Status())
+		}
+		w.Write(append(marshal(statuses), '\n'))
 	})
 
 	m.Post("/routes", func(w http.ResponseWriter, req *http.Request) (int, string) {
@@ -281,7 +303,9 @@ func main() {
 			return http.StatusBadRequest, "Bad request: " + err.Error()
 		}
 
-		// TODO: validate?
+		if err := validateTarget(route.Target); err != nil {
+			return http.StatusBadRequest, "Bad request: " + err.Error()
+		}
 		router.Add(route)
 
 		w.Header().Add("Content-Type", "application/json")
@@ -294,7 +318,7 @@ func main() {
 			http.NotFound(w, req)
 			return
 		}
-		w.Write(append(marshal(route), '\n'))
+		w.Write(append(marshal(route.Status()), '\n'))
 	})
 
 	m.Delete("/routes/:id", func(w http.ResponseWriter, req *http.Request, params martini.Params) {
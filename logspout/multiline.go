@@ -0,0 +1,239 @@
+package main
+
+import (
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-etcd/etcd"
+)
+
+const (
+	multilinePatternKey = "deis_log_multiline_pattern"
+	multilineTimeoutKey = "deis_log_multiline_timeout"
+
+	defaultMultilineTimeout = time.Second
+	// maxMultilineLines caps how many lines are joined into one message.
+	maxMultilineLines = 500
+)
+
+// AppLogPolicy is how logspout treats the output of an app's containers.
+type AppLogPolicy struct {
+	// Multiline joins continuation lines, if set.
+	Multiline *MultilinePolicy
+	// RateLimit limits the lines sent across all of the app's containers
+	// on this host, if set.
+	RateLimit *RateLimit
+}
+
+// MultilinePolicy joins lines matching Pattern onto the line before them.
+//
+// A joined message is flushed when a line that does not match arrives, or
+// when no line has arrived for Timeout.
+type MultilinePolicy struct {
+	Pattern *regexp.Regexp
+	Timeout time.Duration
+}
+
+// AppConfig holds the log policy of each app, as set in /deis/config/<app>/deis_log_*.
+type AppConfig struct {
+	sync.RWMutex
+	client   appConfigClient
+	path     string
+	policies map[string]AppLogPolicy
+}
+
+// appConfigClient is the part of *etcd.Client AppConfig reads apps' config with.
+type appConfigClient interface {
+	Get(key string, sort, recursive bool) (*etcd.Response, error)
+	Watch(prefix string, waitIndex uint64, recursive bool,
+		receiver chan *etcd.Response, stop chan bool) (*etcd.Response, error)
+}
+
+func NewAppConfig(client *etcd.Client, path string) *AppConfig {
+	return &AppConfig{
+		client:   client,
+		path:     strings.TrimSuffix(path, "/"),
+		policies: make(map[string]AppLogPolicy),
+	}
+}
+
+// Policy returns the log policy for app. Apps without one get the zero policy.
+func (ac *AppConfig) Policy(app string) AppLogPolicy {
+	if ac == nil || app == "" {
+		return AppLogPolicy{}
+	}
+	ac.RLock()
+	defer ac.RUnlock()
+	return ac.policies[app]
+}
+
+// Load reads the policies of all apps, replacing the ones read before.
+func (ac *AppConfig) Load() error {
+	_, err := ac.snapshot()
+	return err
+}
+
+// snapshot reads the policies of all apps and returns the index to watch for
+// changes to them from.
+func (ac *AppConfig) snapshot() (uint64, error) {
+	var apps []string
+	var index uint64
+	resp, err := ac.client.Get(ac.path, false, false)
+	if err != nil {
+		etcdErr, ok := err.(*etcd.EtcdError)
+		if !ok || etcdErr.ErrorCode != etcdKeyNotFound {
+			return 0, err
+		}
+		index = etcdErr.Index + 1
+	} else {
+		index = resp.EtcdIndex + 1
+		for _, node := range resp.Node.Nodes {
+			apps = append(apps, strings.TrimPrefix(node.Key, ac.path+"/"))
+		}
+	}
+	policies := make(map[string]AppLogPolicy)
+	for _, app := range apps {
+		if policy, ok := ac.fetch(app); ok {
+			policies[app] = policy
+		}
+	}
+	ac.Lock()
+	defer ac.Unlock()
+	ac.policies = policies
+	return index, nil
+}
+
+// Watch reloads an app's policy whenever its config changes.
+//
+// Changes are watched for from the index of a full read of the policies.
+// After the watch fails, the policies are read again in full, as changes may
+// have been missed in the meantime.
+//
+// This blocks, so it is usually run on its own goroutine.
+func (ac *AppConfig) Watch() {
+	for {
+		index, err := ac.snapshot()
+		if err != nil {
+			log.Println("appconfig: resync:", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		ac.watchFrom(index)
+		time.Sleep(time.Second)
+	}
+}
+
+// watchFrom reloads the policies of apps changed from index onwards until the
+// watch fails.
+func (ac *AppConfig) watchFrom(index uint64) {
+	for {
+		resp, err := ac.client.Watch(ac.path, index, true, nil, nil)
+		if err != nil {
+			log.Println("appconfig: watch:", err)
+			return
+		}
+		index = resp.Node.ModifiedIndex + 1
+		parts := strings.Split(strings.TrimPrefix(resp.Node.Key, ac.path+"/"), "/")
+		if len(parts) == 1 || strings.HasPrefix(parts[1], "deis_log_") {
+			ac.reload(parts[0])
+		}
+	}
+}
+
+func (ac *AppConfig) reload(app string) {
+	policy, ok := ac.fetch(app)
+	ac.Lock()
+	defer ac.Unlock()
+	if !ok {
+		delete(ac.policies, app)
+		return
+	}
+	ac.policies[app] = policy
+}
+
+// fetch reads an app's policy, returning false if the app has none.
+func (ac *AppConfig) fetch(app string) (AppLogPolicy, bool) {
+	var policy AppLogPolicy
+	var err error
+	if policy.Multiline, err = ac.fetchMultiline(app); err != nil {
+		log.Println("appconfig:", app+":", err)
+	}
+	if policy.RateLimit, err = ac.fetchRateLimit(app); err != nil {
+		log.Println("appconfig:", app+":", err)
+	}
+	if policy.Multiline == nil && policy.RateLimit == nil {
+		return policy, false
+	}
+	debug("appconfig:", app, policy.Multiline, policy.RateLimit)
+	return policy, true
+}
+
+// get returns the value of one of an app's config keys, or "" if it isn't set.
+func (ac *AppConfig) get(app, key string) (string, error) {
+	resp, err := ac.client.Get(ac.path+"/"+app+"/"+key, false, false)
+	if err != nil {
+		if strings.Contains(err.Error(), "Key not found") {
+			return "", nil
+		}
+		return "", err
+	}
+	return resp.Node.Value, nil
+}
+
+func (ac *AppConfig) fetchMultiline(app string) (*MultilinePolicy, error) {
+	value, err := ac.get(app, multilinePatternKey)
+	if value == "" || err != nil {
+		return nil, err
+	}
+	pattern, err := regexp.Compile(value)
+	if err != nil {
+		return nil, err
+	}
+	policy := &MultilinePolicy{Pattern: pattern, Timeout: defaultMultilineTimeout}
+	if timeout, _ := ac.get(app, multilineTimeoutKey); timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil || d <= 0 {
+			log.Println("appconfig:", app+":", "ignoring bad timeout", timeout)
+		} else {
+			policy.Timeout = d
+		}
+	}
+	return policy, nil
+}
+
+// multilineJoiner joins the lines of one output stream that match a
+// MultilinePolicy's pattern onto the line before them.
+//
+// A nil multilineJoiner holds no lines.
+type multilineJoiner struct {
+	policy  *MultilinePolicy
+	pending *Log
+	joined  int
+}
+
+// Add adds a line. If the line does not continue the pending message, the
+// pending message is complete and is returned, and the line starts a new one.
+func (j *multilineJoiner) Add(logline *Log) *Log {
+	if j.pending != nil && j.joined < maxMultilineLines && j.policy.Pattern.MatchString(logline.Data) {
+		j.pending.Data += "\n" + logline.Data
+		j.joined++
+		return nil
+	}
+	done := j.Flush()
+	j.pending = logline
+	j.joined = 1
+	return done
+}
+
+// Flush returns the pending message, or nil if there is none, and forgets it.
+func (j *multilineJoiner) Flush() *Log {
+	if j == nil {
+		return nil
+	}
+	pending := j.pending
+	j.pending = nil
+	return pending
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coreos/go-etcd/etcd"
+)
+
+func TestMultilineJoiner(t *testing.T) {
+	policy := &MultilinePolicy{Pattern: regexp.MustCompile(`^\s`)}
+
+	tests := []struct {
+		lines    []string
+		expected []string
+	}{
+		{nil, nil},
+		{[]string{"one", "two"}, []string{"one", "two"}},
+		{[]string{"panic", "\tframe 1", "\tframe 2", "next"}, []string{"panic\n\tframe 1\n\tframe 2", "next"}},
+		{[]string{"  orphan", "one"}, []string{"  orphan", "one"}},
+		{[]string{"one", " a", "two", " b"}, []string{"one\n a", "two\n b"}},
+	}
+
+	for _, tt := range tests {
+		joiner := &multilineJoiner{policy: policy}
+		var actual []string
+		for _, line := range tt.lines {
+			if done := joiner.Add(&Log{Data: line}); done != nil {
+				actual = append(actual, done.Data)
+			}
+		}
+		if pending := joiner.Flush(); pending != nil {
+			actual = append(actual, pending.Data)
+		}
+		if !reflect.DeepEqual(actual, tt.expected) {
+			t.Errorf("Joining %q: expected %q, got %q", tt.lines, tt.expected, actual)
+		}
+	}
+}
+
+func TestMultilineJoinerLimit(t *testing.T) {
+	joiner := &multilineJoiner{policy: &MultilinePolicy{Pattern: regexp.MustCompile(`^\s`)}}
+	joiner.Add(&Log{Data: "first"})
+	var done *Log
+	for i := 1; i <= maxMultilineLines && done == nil; i++ {
+		done = joiner.Add(&Log{Data: " more"})
+	}
+	if done == nil {
+		t.Fatalf("Expected a message once %d lines were joined", maxMultilineLines)
+	}
+	if n := strings.Count(done.Data, "\n") + 1; n != maxMultilineLines {
+		t.Errorf("Expected %d lines in the message, got %d", maxMultilineLines, n)
+	}
+}
+
+func TestMultilineJoinerNil(t *testing.T) {
+	var joiner *multilineJoiner
+	if pending := joiner.Flush(); pending != nil {
+		t.Errorf("Expected a nil joiner to hold nothing, got %v", pending)
+	}
+}
+
+// fakeAppConfigClient serves apps' config from a map of keys to values. Its
+// watch fails once, after running outage, and then blocks until stop is closed.
+type fakeAppConfigClient struct {
+	sync.Mutex
+	keys    map[string]string
+	outage  func(keys map[string]string)
+	watches int
+	stop    chan struct{}
+}
+
+func (c *fakeAppConfigClient) Get(key string, sort, recursive bool) (*etcd.Response, error) {
+	c.Lock()
+	defer c.Unlock()
+	if key == "/deis/config" {
+		dir := &etcd.Node{Key: key, Dir: true}
+		apps := make(map[string]bool)
+		for k := range c.keys {
+			app := strings.Split(strings.TrimPrefix(k, key+"/"), "/")[0]
+			if !apps[app] {
+				apps[app] = true
+				dir.Nodes = append(dir.Nodes, &etcd.Node{Key: key + "/" + app, Dir: true})
+			}
+		}
+		return &etcd.Response{Node: dir, EtcdIndex: 10}, nil
+	}
+	if value, ok := c.keys[key]; ok {
+		return &etcd.Response{Node: &etcd.Node{Key: key, Value: value}, EtcdIndex: 10}, nil
+	}
+	return nil, &etcd.EtcdError{ErrorCode: etcdKeyNotFound, Message: "Key not found", Index: 10}
+}
+
+func (c *fakeAppConfigClient) Watch(prefix string, waitIndex uint64, recursive bool,
+	receiver chan *etcd.Response, stop chan bool) (*etcd.Response, error) {
+	c.Lock()
+	c.watches++
+	if c.watches == 1 {
+		c.outage(c.keys)
+		c.Unlock()
+		return nil, &etcd.EtcdError{ErrorCode: 401, Message: "The event in requested index is outdated and cleared"}
+	}
+	c.Unlock()
+	<-c.stop
+	return nil, errors.New("stopped")
+}
+
+func TestAppConfigWatchResyncs(t *testing.T) {
+	client := &fakeAppConfigClient{
+		keys: map[string]string{
+			"/deis/config/old/deis_log_multiline_pattern": "^\\s",
+		},
+		outage: func(keys map[string]string) {
+			delete(keys, "/deis/config/old/deis_log_multiline_pattern")
+			keys["/deis/config/new/deis_log_multiline_pattern"] = "^\\s"
+		},
+		stop: make(chan struct{}),
+	}
+	defer close(client.stop)
+	ac := &AppConfig{client: client, path: "/deis/config", policies: make(map[string]AppLogPolicy)}
+
+	if err := ac.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if ac.Policy("old").Multiline == nil {
+		t.Fatal("Expected old to have a multiline policy")
+	}
+
+	go ac.Watch()
+	deadline := time.Now().Add(5 * time.Second)
+	for ac.Policy("new").Multiline == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the policy set while the watch was down to be loaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if ac.Policy("old").Multiline != nil {
+		t.Error("Expected the policy removed while the watch was down to be forgotten")
+	}
+}
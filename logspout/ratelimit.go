@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	rateLimitKey = "deis_log_rate_limit"
+	rateBurstKey = "deis_log_rate_burst"
+)
+
+// suppressedInterval is how often a pump reports the lines it has suppressed.
+const suppressedInterval = 10 * time.Second
+
+// RateLimit allows Rate lines per second, with bursts of up to Burst lines.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+func (r *RateLimit) String() string {
+	return fmt.Sprintf("%g/s (burst %d)", r.Rate, r.Burst)
+}
+
+// tokenBucket enforces a RateLimit. It is safe for concurrent use.
+type tokenBucket struct {
+	sync.Mutex
+	limit  RateLimit
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(limit *RateLimit) *tokenBucket {
+	return &tokenBucket{limit: *limit, tokens: float64(limit.Burst), last: time.Now()}
+}
+
+// Allow takes a token from the bucket, returning false if there are none.
+func (b *tokenBucket) Allow() bool {
+	return RateLimiter{b}.Allow()
+}
+
+// refill adds the tokens earned since the bucket was last refilled. The
+// caller must hold the bucket's lock.
+func (b *tokenBucket) refill(now time.Time) {
+	b.tokens += now.Sub(b.last).Seconds() * b.limit.Rate
+	if max := float64(b.limit.Burst); b.tokens > max {
+		b.tokens = max
+	}
+	b.last = now
+}
+
+// RateLimiter allows a line only if every one of its buckets does.
+//
+// A nil RateLimiter allows every line.
+type RateLimiter []*tokenBucket
+
+// Allow takes a token from every bucket, or from none of them if any bucket
+// is empty.
+//
+// Buckets shared between limiters must be in the same order in each, as
+// they are locked in order.
+func (rl RateLimiter) Allow() bool {
+	for _, b := range rl {
+		b.Lock()
+		defer b.Unlock()
+	}
+	now := time.Now()
+	for _, b := range rl {
+		b.refill(now)
+		if b.tokens < 1 {
+			return false
+		}
+	}
+	for _, b := range rl {
+		b.tokens--
+	}
+	return true
+}
+
+// suppressedMessage is sent in place of lines dropped by a RateLimiter.
+func suppressedMessage(n int) string {
+	return fmt.Sprintf("logspout: %d lines suppressed by rate limit", n)
+}
+
+func (ac *AppConfig) fetchRateLimit(app string) (*RateLimit, error) {
+	value, err := ac.get(app, rateLimitKey)
+	if value == "" || err != nil {
+		return nil, err
+	}
+	burst, _ := ac.get(app, rateBurstKey)
+	return parseRateLimit(value, burst)
+}
+
+// parseRateLimit parses a rate in lines per second and an optional burst.
+//
+// The burst defaults to one second's worth of lines.
+func parseRateLimit(rate, burst string) (*RateLimit, error) {
+	r, err := strconv.ParseFloat(rate, 64)
+	if err != nil {
+		return nil, err
+	}
+	if r <= 0 {
+		return nil, nil
+	}
+	limit := &RateLimit{Rate: r, Burst: int(r)}
+	if burst != "" {
+		if limit.Burst, err = strconv.Atoi(burst); err != nil {
+			return nil, err
+		}
+	}
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+	return limit, nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	tests := []struct {
+		limit RateLimit
+		// idle is how long the bucket sits before the lines arrive
+		idle     time.Duration
+		lines    int
+		expected int
+	}{
+		{RateLimit{Rate: 10, Burst: 5}, 0, 8, 5},
+		{RateLimit{Rate: 10, Burst: 5}, time.Second, 8, 5},
+		{RateLimit{Rate: 1, Burst: 1}, 0, 3, 1},
+		{RateLimit{Rate: 0.5, Burst: 3}, 0, 3, 3},
+	}
+
+	for _, tt := range tests {
+		b := newTokenBucket(&tt.limit)
+		b.last = b.last.Add(-tt.idle)
+		allowed := 0
+		for i := 0; i < tt.lines; i++ {
+			if b.Allow() {
+				allowed++
+			}
+		}
+		if allowed != tt.expected {
+			t.Errorf("%s after %s: expected %d of %d lines allowed, got %d", &tt.limit, tt.idle, tt.expected, tt.lines, allowed)
+		}
+	}
+}
+
+func TestTokenBucketRefill(t *testing.T) {
+	b := newTokenBucket(&RateLimit{Rate: 10, Burst: 5})
+	for b.Allow() {
+	}
+
+	b.last = b.last.Add(-200 * time.Millisecond)
+	allowed := 0
+	for b.Allow() {
+		allowed++
+	}
+	if allowed != 2 {
+		t.Errorf("Expected 2 lines allowed after 200ms at 10/s, got %d", allowed)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	full := func() *tokenBucket { return newTokenBucket(&RateLimit{Rate: 1, Burst: 2}) }
+	empty := func() *tokenBucket {
+		b := full()
+		b.tokens = 0
+		return b
+	}
+
+	tests := []struct {
+		limiter  RateLimiter
+		expected bool
+		// tokens are the tokens expected to be left in each bucket
+		tokens []int
+	}{
+		{nil, true, nil},
+		{RateLimiter{full()}, true, []int{1}},
+		{RateLimiter{full(), full()}, true, []int{1, 1}},
+		{RateLimiter{empty(), full()}, false, []int{0, 2}},
+		{RateLimiter{full(), empty()}, false, []int{2, 0}},
+	}
+
+	for i, tt := range tests {
+		if actual := tt.limiter.Allow(); actual != tt.expected {
+			t.Errorf("Limiter %d: expected %t, got %t", i, tt.expected, actual)
+		}
+		var tokens []int
+		for _, b := range tt.limiter {
+			tokens = append(tokens, int(b.tokens))
+		}
+		if !reflect.DeepEqual(tokens, tt.tokens) {
+			t.Errorf("Limiter %d: expected %v tokens left, got %v", i, tt.tokens, tokens)
+		}
+	}
+}
+
+func TestParseRateLimit(t *testing.T) {
+	tests := []struct {
+		rate, burst string
+		expected    *RateLimit
+		ok          bool
+	}{
+		{"100", "", &RateLimit{Rate: 100, Burst: 100}, true},
+		{"100", "500", &RateLimit{Rate: 100, Burst: 500}, true},
+		{"0.5", "", &RateLimit{Rate: 0.5, Burst: 1}, true},
+		{"10", "0", &RateLimit{Rate: 10, Burst: 1}, true},
+		{"0", "", nil, true},
+		{"-1", "", nil, true},
+		{"fast", "", nil, false},
+		{"10", "lots", nil, false},
+	}
+
+	for _, tt := range tests {
+		actual, err := parseRateLimit(tt.rate, tt.burst)
+		if (err == nil) != tt.ok {
+			t.Errorf("Parsing %q, %q: expected ok=%t, got %v", tt.rate, tt.burst, tt.ok, err)
+			continue
+		}
+		if !reflect.DeepEqual(actual, tt.expected) {
+			t.Errorf("Parsing %q, %q: expected %v, got %v", tt.rate, tt.burst, tt.expected, actual)
+		}
+	}
+}
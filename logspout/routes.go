@@ -1,16 +1,21 @@
 package main
 
 import (
+	"bytes"
 	"crypto/sha1"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
 	"os"
+	"path"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/coreos/go-etcd/etcd"
 )
 
 type RouteStore interface {
@@ -25,10 +30,13 @@ type RouteManager struct {
 	persistor RouteStore
 	attacher  *AttachManager
 	routes    map[string]*Route
+	// local holds the IDs of routes added before there was a persistor,
+	// which Resync leaves alone
+	local map[string]bool
 }
 
 func NewRouteManager(attacher *AttachManager) *RouteManager {
-	return &RouteManager{attacher: attacher, routes: make(map[string]*Route)}
+	return &RouteManager{attacher: attacher, routes: make(map[string]*Route), local: make(map[string]bool)}
 }
 
 func (rm *RouteManager) Load(persistor RouteStore) error {
@@ -37,7 +45,7 @@ func (rm *RouteManager) Load(persistor RouteStore) error {
 		return err
 	}
 	for _, route := range routes {
-		rm.Add(route)
+		rm.add(route, false)
 	}
 	rm.persistor = persistor
 	return nil
@@ -64,6 +72,10 @@ func (rm *RouteManager) GetAll() ([]*Route, error) {
 }
 
 func (rm *RouteManager) Add(route *Route) error {
+	return rm.add(route, true)
+}
+
+func (rm *RouteManager) add(route *Route, persist bool) error {
 	rm.Lock()
 	defer rm.Unlock()
 	if route.ID == "" {
@@ -72,7 +84,11 @@ func (rm *RouteManager) Add(route *Route) error {
 		route.ID = fmt.Sprintf("%x", h.Sum(nil))[:12]
 	}
 	route.closer = make(chan bool)
+	route.stats = new(RouteStats)
 	rm.routes[route.ID] = route
+	if persist && rm.persistor == nil {
+		rm.local[route.ID] = true
+	}
 	types := []string{}
 	if route.Source != nil {
 		types = append(types, route.Source.Types...)
@@ -80,10 +96,10 @@ func (rm *RouteManager) Add(route *Route) error {
 	go func() {
 		logstream := make(chan *Log)
 		defer close(logstream)
-		go syslogStreamer(route.Target, types, logstream)
+		go routeStreamer(route.Target, types, logstream, route.stats)
 		rm.attacher.Listen(route.Source, logstream, route.closer)
 	}()
-	if rm.persistor != nil {
+	if persist && rm.persistor != nil {
 		if err := rm.persistor.Add(route); err != nil {
 			log.Println("persistor:", err)
 		}
@@ -92,6 +108,10 @@ func (rm *RouteManager) Add(route *Route) error {
 }
 
 func (rm *RouteManager) Remove(id string) bool {
+	return rm.remove(id, true)
+}
+
+func (rm *RouteManager) remove(id string, persist bool) bool {
 	rm.Lock()
 	defer rm.Unlock()
 	route, ok := rm.routes[id]
@@ -99,12 +119,53 @@ func (rm *RouteManager) Remove(id string) bool {
 		route.closer <- true
 	}
 	delete(rm.routes, id)
-	if rm.persistor != nil {
+	delete(rm.local, id)
+	if persist && rm.persistor != nil {
 		rm.persistor.Remove(id)
 	}
 	return ok
 }
 
+// Sync applies a route that was changed in the persistor by another host.
+//
+// Unchanged routes are left running. Changed routes are restarted.
+func (rm *RouteManager) Sync(route *Route) {
+	if existing, err := rm.Get(route.ID); err == nil {
+		if bytes.Equal(marshal(existing), marshal(route)) {
+			return
+		}
+		rm.remove(route.ID, false)
+	}
+	rm.add(route, false)
+}
+
+// Forget stops a route that was removed from the persistor by another host.
+func (rm *RouteManager) Forget(id string) {
+	rm.remove(id, false)
+}
+
+// Resync makes the running routes match all the routes in the persistor, for
+// when changes may have been missed.
+func (rm *RouteManager) Resync(routes []*Route) {
+	current := make(map[string]bool)
+	for _, route := range routes {
+		current[route.ID] = true
+		rm.Sync(route)
+	}
+
+	var gone []string
+	rm.Lock()
+	for id := range rm.routes {
+		if !current[id] && !rm.local[id] {
+			gone = append(gone, id)
+		}
+	}
+	rm.Unlock()
+	for _, id := range gone {
+		rm.Forget(id)
+	}
+}
+
 type RouteFileStore string
 
 func (fs RouteFileStore) Filename(id string) string {
@@ -153,3 +214,139 @@ func (fs RouteFileStore) Remove(id string) bool {
 	}
 	return false
 }
+
+// etcd error codes
+const (
+	etcdKeyNotFound = 100
+	etcdNodeExist   = 105
+)
+
+// RouteEtcdStore persists routes in etcd so that they apply to every host.
+type RouteEtcdStore struct {
+	client *etcd.Client
+	path   string
+}
+
+func NewRouteEtcdStore(client *etcd.Client, path string) *RouteEtcdStore {
+	return &RouteEtcdStore{client: client, path: strings.TrimSuffix(path, "/")}
+}
+
+func (es *RouteEtcdStore) Key(id string) string {
+	return es.path + "/" + id
+}
+
+func (es *RouteEtcdStore) Get(id string) (*Route, error) {
+	resp, err := es.client.Get(es.Key(id), false, false)
+	if err != nil {
+		return nil, err
+	}
+	route := new(Route)
+	if err := json.Unmarshal([]byte(resp.Node.Value), route); err != nil {
+		return nil, err
+	}
+	return route, nil
+}
+
+func (es *RouteEtcdStore) GetAll() ([]*Route, error) {
+	routes, _, err := es.snapshot()
+	return routes, err
+}
+
+// snapshot returns the routes in etcd and the index to watch for changes to
+// them from.
+func (es *RouteEtcdStore) snapshot() ([]*Route, uint64, error) {
+	resp, err := es.client.Get(es.path, false, false)
+	if err != nil {
+		if etcdErr, ok := err.(*etcd.EtcdError); ok && etcdErr.ErrorCode == etcdKeyNotFound {
+			return nil, etcdErr.Index + 1, nil
+		}
+		return nil, 0, err
+	}
+	var routes []*Route
+	for _, node := range resp.Node.Nodes {
+		route := new(Route)
+		if err := json.Unmarshal([]byte(node.Value), route); err != nil {
+			log.Println("etcd store:", node.Key, err)
+			continue
+		}
+		routes = append(routes, route)
+	}
+	return routes, resp.EtcdIndex + 1, nil
+}
+
+// Import copies routes into etcd if no routes have ever been kept there.
+//
+// The routes directory is created atomically, so only the first host to start
+// imports its routes, and only once. It returns whether the routes were
+// imported.
+func (es *RouteEtcdStore) Import(routes []*Route) (bool, error) {
+	if _, err := es.client.CreateDir(es.path, 0); err != nil {
+		if etcdErr, ok := err.(*etcd.EtcdError); ok && etcdErr.ErrorCode == etcdNodeExist {
+			return false, nil
+		}
+		return false, err
+	}
+	for _, route := range routes {
+		if err := es.Add(route); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (es *RouteEtcdStore) Add(route *Route) error {
+	_, err := es.client.Set(es.Key(route.ID), string(marshal(route)), 0)
+	return err
+}
+
+func (es *RouteEtcdStore) Remove(id string) bool {
+	_, err := es.client.Delete(es.Key(id), false)
+	return err == nil
+}
+
+// Watch applies route changes made in etcd, usually by other hosts, to rm.
+//
+// Changes are watched for from the index of a full read of the routes. After
+// the watch fails, the routes are read and applied again in full, as changes
+// may have been missed in the meantime.
+//
+// This blocks, so it is usually run on its own goroutine.
+func (es *RouteEtcdStore) Watch(rm *RouteManager) {
+	for {
+		routes, index, err := es.snapshot()
+		if err != nil {
+			log.Println("etcd store: resync:", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		rm.Resync(routes)
+		es.watchFrom(rm, index)
+		time.Sleep(time.Second)
+	}
+}
+
+// watchFrom applies changes from index onwards until the watch fails.
+func (es *RouteEtcdStore) watchFrom(rm *RouteManager, index uint64) {
+	for {
+		resp, err := es.client.Watch(es.path, index, true, nil, nil)
+		if err != nil {
+			log.Println("etcd store: watch:", err)
+			return
+		}
+		index = resp.Node.ModifiedIndex + 1
+		id := path.Base(resp.Node.Key)
+		switch resp.Action {
+		case "delete", "expire", "compareAndDelete":
+			debug("etcd store: removing route", id)
+			rm.Forget(id)
+		default:
+			route := new(Route)
+			if err := json.Unmarshal([]byte(resp.Node.Value), route); err != nil {
+				log.Println("etcd store:", resp.Node.Key, err)
+				continue
+			}
+			debug("etcd store: applying route", id)
+			rm.Sync(route)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	dtime "github.com/deis/deis/pkg/time"
+)
+
+const (
+	minBackoff = 100 * time.Millisecond
+	maxBackoff = 30 * time.Second
+)
+
+// RouteStats counts what happened to the log lines sent down a route.
+type RouteStats struct {
+	mu        sync.Mutex
+	Sent      uint64     `json:"sent"`
+	Dropped   uint64     `json:"dropped"`
+	Errors    uint64     `json:"errors"`
+	Connected bool       `json:"connected"`
+	LastError string     `json:"last_error,omitempty"`
+	LastErrAt *time.Time `json:"last_error_at,omitempty"`
+}
+
+// Snapshot returns a copy of the stats that is safe to marshal.
+func (s *RouteStats) Snapshot() RouteStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return RouteStats{
+		Sent:      s.Sent,
+		Dropped:   s.Dropped,
+		Errors:    s.Errors,
+		Connected: s.Connected,
+		LastError: s.LastError,
+		LastErrAt: s.LastErrAt,
+	}
+}
+
+func (s *RouteStats) sent(n int) {
+	s.mu.Lock()
+	s.Sent += uint64(n)
+	s.mu.Unlock()
+}
+
+func (s *RouteStats) dropped(n int) {
+	s.mu.Lock()
+	s.Dropped += uint64(n)
+	s.mu.Unlock()
+}
+
+func (s *RouteStats) connected(ok bool) {
+	s.mu.Lock()
+	s.Connected = ok
+	s.mu.Unlock()
+}
+
+func (s *RouteStats) failed(err error) {
+	s.mu.Lock()
+	s.Errors++
+	s.Connected = false
+	s.LastError = err.Error()
+	now := time.Now()
+	s.LastErrAt = &now
+	s.mu.Unlock()
+}
+
+// routeStreamer formats log lines for a route's target and queues them for
+// the target's writer.
+//
+// The queue holds up to SYSLOG_BUFFER lines. While the target is unreachable,
+// the oldest lines are dropped to make room for new ones. The writer is shut
+// down when logstream is closed.
+func routeStreamer(target Target, types []string, logstream chan *Log, stats *RouteStats) {
+	typestr := "," + strings.Join(types, ",") + ","
+	size, err := strconv.Atoi(getopt("SYSLOG_BUFFER", "1024"))
+	if err != nil || size < 1 {
+		size = 1024
+	}
+	format := getFormatter(target)
+
+	queue := make(chan []byte, size)
+	done := make(chan struct{})
+	go getWriter(target, stats).run(queue, done)
+	defer close(done)
+
+	for logline := range logstream {
+		if typestr != ",," && !strings.Contains(typestr, logline.Type) {
+			continue
+		}
+		msg, err := format(logline)
+		if err != nil {
+			log.Println("format:", err)
+			continue
+		}
+		enqueue(queue, msg, stats)
+	}
+}
+
+// enqueue adds msg to queue, dropping the oldest message if queue is full.
+//
+// Only the route's streamer sends on queue, so there is room for msg once
+// one message has been taken off it, by the writer or by enqueue itself.
+func enqueue(queue chan []byte, msg []byte, stats *RouteStats) {
+	select {
+	case queue <- msg:
+	default:
+		// the queue is full, so drop the oldest line
+		select {
+		case <-queue:
+			stats.dropped(1)
+		default:
+		}
+		queue <- msg
+	}
+}
+
+// formatSyslog formats a log line as a syslog message.
+func formatSyslog(logline *Log) ([]byte, error) {
+	tag, pid, data := getLogParts(logline)
+	// HACK: Go's syslog package hardcodes the log format, so let's send our own message
+	return []byte(fmt.Sprintf("%s %s[%s]: %s",
+		time.Now().Format(getopt("DATETIME_FORMAT", dtime.DeisDatetimeFormat)),
+		tag,
+		pid,
+		data)), nil
+}
+
+// logWriter delivers queued messages to a route's target until done is closed.
+type logWriter interface {
+	run(queue <-chan []byte, done <-chan struct{})
+}
+
+// connWriter sends queued messages over one long-lived connection,
+// reconnecting with exponential backoff when the connection fails.
+type connWriter struct {
+	target   Target
+	stats    *RouteStats
+	protocol string
+	// frame prepares a message to be written to the connection.
+	frame func(msg []byte) [][]byte
+	conn  net.Conn
+}
+
+func (w *connWriter) run(queue <-chan []byte, done <-chan struct{}) {
+	defer w.close()
+	backoff := minBackoff
+	for {
+		var msg []byte
+		select {
+		case msg = <-queue:
+		case <-done:
+			return
+		}
+		for {
+			err := w.write(msg)
+			if err == nil {
+				w.stats.sent(1)
+				backoff = minBackoff
+				break
+			}
+			w.stats.failed(err)
+			log.Printf("%s: %s: %v (retrying in %s)", w.target.Type, w.target.Addr, err, backoff)
+			w.close()
+			if !sleep(backoff, done) {
+				return
+			}
+			backoff = nextBackoff(backoff)
+		}
+	}
+}
+
+func (w *connWriter) write(msg []byte) error {
+	if w.conn == nil {
+		if err := w.dial(); err != nil {
+			return err
+		}
+	}
+	for _, packet := range w.frame(msg) {
+		if _, err := w.conn.Write(packet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *connWriter) dial() error {
+	conn, err := net.DialTimeout(w.protocol, w.target.Addr, 5*time.Second)
+	if err != nil {
+		return err
+	}
+	switch c := conn.(type) {
+	case *net.TCPConn:
+		c.SetWriteBuffer(1048576)
+		c.SetKeepAlive(true)
+	case *net.UDPConn:
+		c.SetWriteBuffer(1048576)
+	}
+	w.conn = conn
+	w.stats.connected(true)
+	return nil
+}
+
+func (w *connWriter) close() {
+	if w.conn != nil {
+		w.conn.Close()
+		w.conn = nil
+	}
+	w.stats.connected(false)
+}
+
+// delimit returns a frame function that terminates each message with delim.
+func delimit(delim byte) func([]byte) [][]byte {
+	return func(msg []byte) [][]byte {
+		return [][]byte{append(msg, delim)}
+	}
+}
+
+// whole is the frame function for protocols where each write is one message.
+func whole(msg []byte) [][]byte {
+	return [][]byte{msg}
+}
+
+// sleep waits for d, returning false if done is closed first.
+func sleep(d time.Duration, done <-chan struct{}) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-done:
+		return false
+	}
+}
+
+func nextBackoff(backoff time.Duration) time.Duration {
+	if backoff *= 2; backoff > maxBackoff {
+		return maxBackoff
+	}
+	return backoff
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEnqueue(t *testing.T) {
+	tests := []struct {
+		size     int
+		msgs     []string
+		expected []string
+		dropped  uint64
+	}{
+		{3, []string{"a", "b"}, []string{"a", "b"}, 0},
+		{3, []string{"a", "b", "c"}, []string{"a", "b", "c"}, 0},
+		{3, []string{"a", "b", "c", "d"}, []string{"b", "c", "d"}, 1},
+		{2, []string{"a", "b", "c", "d", "e"}, []string{"d", "e"}, 3},
+		{1, []string{"a", "b"}, []string{"b"}, 1},
+	}
+
+	for _, tt := range tests {
+		queue := make(chan []byte, tt.size)
+		stats := new(RouteStats)
+		for _, msg := range tt.msgs {
+			enqueue(queue, []byte(msg), stats)
+		}
+		close(queue)
+
+		var actual []string
+		for msg := range queue {
+			actual = append(actual, string(msg))
+		}
+		if !reflect.DeepEqual(actual, tt.expected) {
+			t.Errorf("Queue of %d given %v: expected %v, got %v", tt.size, tt.msgs, tt.expected, actual)
+		}
+		if dropped := stats.Snapshot().Dropped; dropped != tt.dropped {
+			t.Errorf("Queue of %d given %v: expected %d dropped, got %d", tt.size, tt.msgs, tt.dropped, dropped)
+		}
+	}
+}
+
+func TestNextBackoff(t *testing.T) {
+	tests := []struct {
+		backoff, expected time.Duration
+	}{
+		{minBackoff, 2 * minBackoff},
+		{10 * time.Second, 20 * time.Second},
+		{20 * time.Second, maxBackoff},
+		{maxBackoff, maxBackoff},
+	}
+
+	for _, tt := range tests {
+		if actual := nextBackoff(tt.backoff); actual != tt.expected {
+			t.Errorf("Expected the backoff after %s to be %s, got %s", tt.backoff, tt.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// gelfChunkSize keeps each GELF UDP chunk within a typical MTU.
+	gelfChunkSize = 1420
+	// gelfMaxChunks is the most chunks a GELF message may be split into.
+	gelfMaxChunks = 128
+)
+
+var gelfMagic = []byte{0x1e, 0x0f}
+
+// defaultProtocols is the protocol used by each target type when none is given.
+var defaultProtocols = map[string]string{
+	"syslog": "udp",
+	"json":   "tcp",
+	"gelf":   "udp",
+}
+
+// targetProtocol returns the network used to reach a target.
+func targetProtocol(target Target) (string, error) {
+	protocol := strings.ToLower(target.Protocol)
+	if protocol == "" {
+		protocol = defaultProtocols[target.Type]
+	}
+	if protocol != "udp" && protocol != "tcp" {
+		return "", fmt.Errorf("%s is not a supported protocol, use either udp or tcp", target.Protocol)
+	}
+	return protocol, nil
+}
+
+// validateTarget returns an error if logspout can't send logs to target.
+func validateTarget(target Target) error {
+	switch target.Type {
+	case "syslog", "json", "gelf":
+		_, err := targetProtocol(target)
+		return err
+	case "http", "https":
+		u, err := url.Parse(target.Addr)
+		if err != nil {
+			return err
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("%s is not an http or https URL", target.Addr)
+		}
+		return nil
+	}
+	return fmt.Errorf("%s is not a supported target type, use syslog, json, gelf or http", target.Type)
+}
+
+// getFormatter returns the function that turns log lines into messages for target.
+func getFormatter(target Target) func(*Log) ([]byte, error) {
+	switch target.Type {
+	case "json", "http", "https":
+		return formatJSON
+	case "gelf":
+		return formatGELF
+	}
+	return formatSyslog
+}
+
+// getWriter returns the writer that delivers messages to target.
+func getWriter(target Target, stats *RouteStats) logWriter {
+	if target.Type == "http" || target.Type == "https" {
+		return &httpWriter{target: target, stats: stats, client: &http.Client{Timeout: 10 * time.Second}}
+	}
+	protocol, err := targetProtocol(target)
+	if err != nil {
+		// routes are validated when they are created, so this is a route
+		// persisted by an older logspout; fall back to the old default.
+		log.Println("target:", err)
+		protocol = "udp"
+	}
+	w := &connWriter{target: target, stats: stats, protocol: protocol, frame: whole}
+	switch {
+	case target.Type == "gelf" && protocol == "udp":
+		w.frame = gelfChunks
+	case target.Type == "gelf":
+		w.frame = delimit(0)
+	case protocol == "tcp":
+		// messages on a stream are delimited by newlines
+		w.frame = delimit('\n')
+	}
+	return w
+}
+
+// instanceRegexp matches the instance number at the end of a process type,
+// such as the ".1" of "web.1".
+var instanceRegexp = regexp.MustCompile(`\.[0-9]+$`)
+
+// LogRecord is a log line along with the Deis application that wrote it.
+type LogRecord struct {
+	*Log
+	Time     time.Time `json:"time"`
+	App      string    `json:"app"`
+	ProcType string    `json:"proctype"`
+	Release  string    `json:"release,omitempty"`
+}
+
+func newLogRecord(logline *Log) *LogRecord {
+	app, proctype, data := getLogParts(logline)
+	record := &LogRecord{
+		Log:      &Log{ID: logline.ID, Name: logline.Name, Type: logline.Type, Data: data},
+		Time:     time.Now(),
+		App:      app,
+		ProcType: instanceRegexp.ReplaceAllString(proctype, ""),
+	}
+	if match := getMatch(`^[a-z0-9-]+_(v[0-9]+)\.`, logline.Name); match != nil {
+		record.Release = match[1]
+	}
+	return record
+}
+
+// formatJSON formats a log line as a single line of JSON.
+func formatJSON(logline *Log) ([]byte, error) {
+	return json.Marshal(newLogRecord(logline))
+}
+
+type gelfMessage struct {
+	Version       string  `json:"version"`
+	Host          string  `json:"host"`
+	ShortMessage  string  `json:"short_message"`
+	Timestamp     float64 `json:"timestamp"`
+	Level         int     `json:"level"`
+	ContainerID   string  `json:"_container_id"`
+	ContainerName string  `json:"_container_name"`
+	Type          string  `json:"_type"`
+	App           string  `json:"_app"`
+	ProcType      string  `json:"_proctype"`
+	Release       string  `json:"_release,omitempty"`
+}
+
+// formatGELF formats a log line as a GELF 1.1 message.
+func formatGELF(logline *Log) ([]byte, error) {
+	record := newLogRecord(logline)
+	level := 6 // informational
+	if record.Type == "stderr" {
+		level = 3 // error
+	}
+	return json.Marshal(&gelfMessage{
+		Version:       "1.1",
+		Host:          gelfHost(),
+		ShortMessage:  record.Data,
+		Timestamp:     float64(record.Time.UnixNano()) / float64(time.Second),
+		Level:         level,
+		ContainerID:   record.ID,
+		ContainerName: record.Name,
+		Type:          record.Type,
+		App:           record.App,
+		ProcType:      record.ProcType,
+		Release:       record.Release,
+	})
+}
+
+func gelfHost() string {
+	if host := os.Getenv("HOST"); host != "" {
+		return host
+	}
+	host, _ := os.Hostname()
+	return host
+}
+
+// gelfChunks splits a GELF message into chunks that each fit in a UDP packet.
+//
+// The short_message of messages too large for gelfMaxChunks chunks is
+// truncated.
+func gelfChunks(msg []byte) [][]byte {
+	if len(msg) <= gelfChunkSize {
+		return [][]byte{msg}
+	}
+	if len(msg) > gelfChunkSize*gelfMaxChunks {
+		var err error
+		if msg, err = truncateGELF(msg, gelfChunkSize*gelfMaxChunks); err != nil {
+			log.Println("gelf:", err)
+			return nil
+		}
+	}
+	id := make([]byte, 8)
+	rand.Read(id)
+	count := (len(msg) + gelfChunkSize - 1) / gelfChunkSize
+	chunks := make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * gelfChunkSize
+		if end > len(msg) {
+			end = len(msg)
+		}
+		chunk := make([]byte, 0, 12+end-i*gelfChunkSize)
+		chunk = append(chunk, gelfMagic...)
+		chunk = append(chunk, id...)
+		chunk = append(chunk, byte(i), byte(count))
+		chunk = append(chunk, msg[i*gelfChunkSize:end]...)
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// truncateGELF shortens the short_message of a GELF message until the message
+// is at most max bytes long.
+func truncateGELF(msg []byte, max int) ([]byte, error) {
+	var m gelfMessage
+	if err := json.Unmarshal(msg, &m); err != nil {
+		return nil, err
+	}
+	for len(msg) > max {
+		if m.ShortMessage == "" {
+			return nil, fmt.Errorf("message of %d bytes is too large to send", len(msg))
+		}
+		// escaping may make the message longer than its text, so this
+		// repeats until the message fits
+		n := len(m.ShortMessage) - (len(msg) - max)
+		if n < 0 {
+			n = 0
+		}
+		for n > 0 && !utf8.RuneStart(m.ShortMessage[n]) {
+			n--
+		}
+		m.ShortMessage = m.ShortMessage[:n]
+		var err error
+		if msg, err = json.Marshal(&m); err != nil {
+			return nil, err
+		}
+	}
+	return msg, nil
+}
+
+// httpWriter POSTs queued messages to a URL in batches, as a JSON array.
+//
+// A batch is sent once it holds HTTP_BATCH_SIZE messages (100 by default),
+// or once it is a second old. Failed batches are retried with exponential
+// backoff, except those the server rejects as bad requests, which are dropped.
+type httpWriter struct {
+	target Target
+	stats  *RouteStats
+	client *http.Client
+}
+
+func (w *httpWriter) run(queue <-chan []byte, done <-chan struct{}) {
+	size, err := strconv.Atoi(getopt("HTTP_BATCH_SIZE", "100"))
+	if err != nil || size < 1 {
+		size = 100
+	}
+	for {
+		var batch [][]byte
+		select {
+		case msg := <-queue:
+			batch = append(batch, msg)
+		case <-done:
+			return
+		}
+		flush := time.After(time.Second)
+	fill:
+		for len(batch) < size {
+			select {
+			case msg := <-queue:
+				batch = append(batch, msg)
+			case <-flush:
+				break fill
+			case <-done:
+				return
+			}
+		}
+		if !w.send(batch, done) {
+			return
+		}
+	}
+}
+
+// send POSTs a batch until it is accepted, rejected, or done is closed.
+func (w *httpWriter) send(batch [][]byte, done <-chan struct{}) bool {
+	body := append([]byte{'['}, bytes.Join(batch, []byte{','})...)
+	body = append(body, ']')
+	backoff := minBackoff
+	for {
+		resp, err := w.client.Post(w.target.Addr, "application/json", bytes.NewReader(body))
+		if err == nil {
+			resp.Body.Close()
+			switch {
+			case resp.StatusCode < 300:
+				w.stats.connected(true)
+				w.stats.sent(len(batch))
+				return true
+			case resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests:
+				w.stats.failed(fmt.Errorf("POST %s: %s", w.target.Addr, resp.Status))
+				w.stats.dropped(len(batch))
+				return true
+			}
+			err = fmt.Errorf("POST %s: %s", w.target.Addr, resp.Status)
+		}
+		w.stats.failed(err)
+		log.Printf("http: %v (retrying in %s)", err, backoff)
+		if !sleep(backoff, done) {
+			return false
+		}
+		backoff = nextBackoff(backoff)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// gelfReassemble joins the chunks of a chunked GELF message.
+func gelfReassemble(t *testing.T, chunks [][]byte) []byte {
+	var msg []byte
+	for i, chunk := range chunks {
+		if !bytes.HasPrefix(chunk, gelfMagic) {
+			t.Fatalf("Expected chunk %d to start with the GELF magic bytes", i)
+		}
+		if !bytes.Equal(chunk[2:10], chunks[0][2:10]) {
+			t.Errorf("Expected chunk %d to have the message ID of chunk 0", i)
+		}
+		if int(chunk[10]) != i || int(chunk[11]) != len(chunks) {
+			t.Errorf("Expected chunk %d of %d, got %d of %d", i, len(chunks), chunk[10], chunk[11])
+		}
+		msg = append(msg, chunk[12:]...)
+	}
+	return msg
+}
+
+func TestGelfChunks(t *testing.T) {
+	message := func(size int) []byte {
+		msg, err := json.Marshal(&gelfMessage{Version: "1.1", ShortMessage: strings.Repeat("x", size)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+
+	tests := []struct {
+		msg    []byte
+		chunks int
+		// truncated is whether short_message is expected to be shortened
+		truncated bool
+	}{
+		{message(10), 1, false},
+		{message(gelfChunkSize - len(message(0))), 1, false},
+		{message(gelfChunkSize), 2, false},
+		{message(gelfChunkSize * 3), 4, false},
+		{message(gelfChunkSize * gelfMaxChunks), gelfMaxChunks, true},
+		{message(gelfChunkSize * gelfMaxChunks * 2), gelfMaxChunks, true},
+	}
+
+	for _, tt := range tests {
+		chunks := gelfChunks(tt.msg)
+		if len(chunks) != tt.chunks {
+			t.Errorf("Message of %d bytes: expected %d chunks, got %d", len(tt.msg), tt.chunks, len(chunks))
+			continue
+		}
+		for i, chunk := range chunks {
+			if len(chunk) > gelfChunkSize+12 {
+				t.Errorf("Message of %d bytes: chunk %d is %d bytes", len(tt.msg), i, len(chunk))
+			}
+		}
+
+		msg := chunks[0]
+		if len(chunks) > 1 {
+			msg = gelfReassemble(t, chunks)
+		}
+		var actual gelfMessage
+		if err := json.Unmarshal(msg, &actual); err != nil {
+			t.Errorf("Message of %d bytes: expected valid JSON, got %v", len(tt.msg), err)
+			continue
+		}
+		if truncated := !bytes.Equal(msg, tt.msg); truncated != tt.truncated {
+			t.Errorf("Message of %d bytes: expected truncated=%t, got %t", len(tt.msg), tt.truncated, truncated)
+		}
+	}
+}
+
+func TestTruncateGELF(t *testing.T) {
+	empty, err := json.Marshal(&gelfMessage{Version: "1.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := len(empty)
+
+	tests := []struct {
+		text string
+		max  int
+	}{
+		{strings.Repeat("x", 100), base + 50},
+		// escaped characters encode to more bytes than they take in the text
+		{strings.Repeat("\"", 100), base + 50},
+		{strings.Repeat("\t\n", 100), base + 31},
+		// multibyte characters are not split
+		{strings.Repeat("é", 100), base + 51},
+		{"short", base},
+	}
+
+	for _, tt := range tests {
+		msg, err := json.Marshal(&gelfMessage{Version: "1.1", ShortMessage: tt.text})
+		if err != nil {
+			t.Fatal(err)
+		}
+		truncated, err := truncateGELF(msg, tt.max)
+		if err != nil {
+			t.Errorf("Truncating %q to %d bytes: %v", tt.text, tt.max, err)
+			continue
+		}
+		if len(truncated) > tt.max {
+			t.Errorf("Truncating %q to %d bytes: got %d bytes", tt.text, tt.max, len(truncated))
+		}
+		var actual gelfMessage
+		if err := json.Unmarshal(truncated, &actual); err != nil {
+			t.Errorf("Truncating %q: expected valid JSON, got %v", tt.text, err)
+			continue
+		}
+		if !strings.HasPrefix(tt.text, actual.ShortMessage) || !utf8.ValidString(actual.ShortMessage) {
+			t.Errorf("Truncating %q: expected a prefix, got %q", tt.text, actual.ShortMessage)
+		}
+	}
+
+	if _, err := truncateGELF(empty, base-1); err == nil {
+		t.Error("Expected an error for a message that is too large without its short_message")
+	}
+}
+
+func TestLogRecordProcType(t *testing.T) {
+	tests := map[string]string{
+		"go_v2.web.1":          "web",
+		"go_v2.worker.12":      "worker",
+		"deis-builder":         "1",
+		"example-go_v10.cmd.3": "cmd",
+	}
+
+	for name, expected := range tests {
+		if actual := newLogRecord(&Log{Name: name}).ProcType; actual != expected {
+			t.Errorf("Expected the proctype of %s to be %s, got %s", name, expected, actual)
+		}
+	}
+}
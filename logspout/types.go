@@ -25,6 +25,21 @@ type Route struct {
 	Source *Source `json:"source,omitempty"`
 	Target Target  `json:"target"`
 	closer chan bool
+	stats  *RouteStats
+}
+
+// RouteStatus is a route along with what has been sent down it.
+type RouteStatus struct {
+	*Route
+	Stats RouteStats `json:"stats"`
+}
+
+func (r *Route) Status() *RouteStatus {
+	status := &RouteStatus{Route: r}
+	if r.stats != nil {
+		status.Stats = r.stats.Snapshot()
+	}
+	return status
 }
 
 type Source struct {
@@ -1,13 +1,15 @@
 package main
 
 import (
+	"strconv"
 	"strings"
 
-	"github.com/deis/deis/mesos/pkg/boot"
-	"github.com/deis/deis/mesos/pkg/etcd"
-	logger "github.com/deis/deis/mesos/pkg/log"
-	"github.com/deis/deis/mesos/pkg/os"
-	"github.com/deis/deis/mesos/pkg/types"
+	"github.com/deis/deis/pkg/boot"
+	"github.com/deis/deis/pkg/boot/types"
+	"github.com/deis/deis/pkg/etcd"
+	"github.com/deis/deis/pkg/health"
+	logger "github.com/deis/deis/pkg/log"
+	"github.com/deis/deis/pkg/os"
 )
 
 const (
@@ -64,6 +66,19 @@ func (mb *MesosBoot) WaitForPorts() []int {
 	return []int{}
 }
 
+// HealthChecks checks that mesos-master accepts connections.
+func (mb *MesosBoot) HealthChecks(currentBoot *types.CurrentBoot) []*types.HealthCheck {
+	addr := currentBoot.Host.String() + ":" + strconv.Itoa(currentBoot.Port)
+	return []*types.HealthCheck{
+		&types.HealthCheck{Name: "mesos-master", Check: health.TCPCheck(addr)},
+	}
+}
+
+// PublishKeys publishes the host and port of mesos-master under its IP.
+func (mb *MesosBoot) PublishKeys(currentBoot *types.CurrentBoot) map[string]string {
+	return currentBoot.HostPortKeys(currentBoot.EtcdPath + "/" + currentBoot.Host.String())
+}
+
 // PostBootScripts returns type script.
 func (mb *MesosBoot) PostBootScripts(currentBoot *types.CurrentBoot) []*types.Script {
 	return []*types.Script{}
@@ -84,6 +99,11 @@ func (mb *MesosBoot) UseConfd() (bool, bool) {
 	return false, false
 }
 
+// PreShutdown logs that mesos-master is stopping.
+func (mb *MesosBoot) PreShutdown(currentBoot *types.CurrentBoot) {
+	log.Info("mesos-master: stopping...")
+}
+
 // PreShutdownScripts returns type script.
 func (mb *MesosBoot) PreShutdownScripts(currentBoot *types.CurrentBoot) []*types.Script {
 	return []*types.Script{}
@@ -1,13 +1,15 @@
 package main
 
 import (
+	"strconv"
 	"strings"
 
-	"github.com/deis/deis/mesos/pkg/boot"
-	"github.com/deis/deis/mesos/pkg/etcd"
-	logger "github.com/deis/deis/mesos/pkg/log"
-	"github.com/deis/deis/mesos/pkg/os"
-	"github.com/deis/deis/mesos/pkg/types"
+	"github.com/deis/deis/pkg/boot"
+	"github.com/deis/deis/pkg/boot/types"
+	"github.com/deis/deis/pkg/etcd"
+	"github.com/deis/deis/pkg/health"
+	logger "github.com/deis/deis/pkg/log"
+	"github.com/deis/deis/pkg/os"
 )
 
 const (
@@ -63,6 +65,19 @@ func (mb *MesosBoot) WaitForPorts() []int {
 	return []int{}
 }
 
+// HealthChecks checks that mesos-slave accepts connections.
+func (mb *MesosBoot) HealthChecks(currentBoot *types.CurrentBoot) []*types.HealthCheck {
+	addr := currentBoot.Host.String() + ":" + strconv.Itoa(currentBoot.Port)
+	return []*types.HealthCheck{
+		&types.HealthCheck{Name: "mesos-slave", Check: health.TCPCheck(addr)},
+	}
+}
+
+// PublishKeys publishes the host and port of mesos-slave under its IP.
+func (mb *MesosBoot) PublishKeys(currentBoot *types.CurrentBoot) map[string]string {
+	return currentBoot.HostPortKeys(currentBoot.EtcdPath + "/" + currentBoot.Host.String())
+}
+
 // PostBootScripts returns type script.
 func (mb *MesosBoot) PostBootScripts(currentBoot *types.CurrentBoot) []*types.Script {
 	return []*types.Script{}
@@ -83,6 +98,11 @@ func (mb *MesosBoot) UseConfd() (bool, bool) {
 	return false, false
 }
 
+// PreShutdown logs that mesos-slave is stopping.
+func (mb *MesosBoot) PreShutdown(currentBoot *types.CurrentBoot) {
+	log.Info("mesos-slave: stopping...")
+}
+
 // PreShutdownScripts returns type script.
 func (mb *MesosBoot) PreShutdownScripts(currentBoot *types.CurrentBoot) []*types.Script {
 	return []*types.Script{}
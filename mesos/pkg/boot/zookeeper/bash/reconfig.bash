@@ -0,0 +1,12 @@
+set -eo pipefail
+
+# set debug based on envvar
+[[ $DEBUG ]] && set -x
+
+main() {
+  export PATH=$PATH:/jre/bin
+
+  echo "reconfiguring zookeeper cluster with members $MEMBERS"
+  echo ""
+  /opt/zookeeper/bin/zkCli.sh -server "$HOST" reconfig -members "$MEMBERS"
+}
@@ -12,13 +12,18 @@ import (
 
 	"github.com/deis/deis/mesos/bindata/zookeeper"
 	"github.com/deis/deis/mesos/pkg/boot/zookeeper"
-	"github.com/deis/deis/mesos/pkg/confd"
-	"github.com/deis/deis/mesos/pkg/etcd"
-	logger "github.com/deis/deis/mesos/pkg/log"
-	oswrapper "github.com/deis/deis/mesos/pkg/os"
+	"github.com/deis/deis/pkg/confd"
+	"github.com/deis/deis/pkg/etcd"
+	logger "github.com/deis/deis/pkg/log"
+	oswrapper "github.com/deis/deis/pkg/os"
 	"github.com/deis/deis/version"
 )
 
+const (
+	// reconcileInterval is how often the ensemble is matched to the machines in fleet
+	reconcileInterval = 1 * time.Minute
+)
+
 var (
 	etcdPath   = oswrapper.Getopt("ETCD_PATH", "/zookeeper/nodes")
 	log        = logger.New()
@@ -37,7 +42,16 @@ func main() {
 	log.Infof("boot version [%v]", version.Version)
 	log.Info("zookeeper: starting...")
 
-	zookeeper.CheckZkMappingInFleet(etcdPath, etcdClient, etcdURL)
+	// nodes that leave fleet are removed from the ensemble after the grace period
+	gracePeriod, err := strconv.Atoi(oswrapper.Getopt("DEPARTED_GRACE_PERIOD", "600"))
+	if err != nil {
+		log.Fatalf("invalid DEPARTED_GRACE_PERIOD: %v", err)
+	}
+	grace := time.Duration(gracePeriod) * time.Second
+
+	if err := zookeeper.CheckZkMappingInFleet(etcdPath, etcdClient, etcdURL, grace); err != nil {
+		log.Fatalf("unable to assign zookeeper ids: %v", err)
+	}
 
 	// we need to write the file /opt/zookeeper-data/data/myid with the id of this node
 	os.MkdirAll("/opt/zookeeper-data/data", 0640)
@@ -78,7 +92,7 @@ func main() {
 	}()
 
 	// wait for confd to run once and install initial templates
-	confd.WaitForInitialConf(getConfdNodes(host, etcdCtlPeers, 4001), 10*time.Second)
+	confd.WaitForInitialConf(getConfdNodes(host, etcdCtlPeers, 4001), "/app", 10*time.Second)
 
 	params := make(map[string]string)
 	params["HOST"] = host
@@ -86,7 +100,7 @@ func main() {
 		params["DEBUG"] = "true"
 	}
 
-	err := oswrapper.RunScript("pkg/boot/zookeeper/bash/add-node.bash", params, bindata.Asset)
+	err = oswrapper.RunScript("pkg/boot/zookeeper/bash/add-node.bash", params, bindata.Asset)
 	if err != nil {
 		log.Printf("command finished with error: %v", err)
 	}
@@ -97,6 +111,8 @@ func main() {
 
 	log.Info("zookeeper: running...")
 
+	go reconcile(etcdClient, etcdURL, grace, params)
+
 	go func() {
 		log.Debugf("starting pprof http server in port 6060")
 		http.ListenAndServe("localhost:6060", nil)
@@ -115,6 +131,33 @@ func main() {
 	zkServer.Stop()
 }
 
+// reconcile periodically matches the ensemble to the machines in fleet, and
+// reconfigures zookeeper when its members change. Only the node holding the
+// zookeeper setup lock makes changes
+func reconcile(etcdClient *etcd.Client, etcdURL []string, grace time.Duration, params map[string]string) {
+	for {
+		time.Sleep(reconcileInterval)
+
+		plan, err := zookeeper.ReconcileMembership(etcdPath, etcdClient, etcdURL, grace, reconcileInterval)
+		if err != nil {
+			log.Warningf("unable to reconcile zookeeper nodes: %v", err)
+			continue
+		}
+		if !plan.Changed() {
+			continue
+		}
+
+		reconfigParams := map[string]string{"MEMBERS": plan.Reconfig()}
+		for key, value := range params {
+			reconfigParams[key] = value
+		}
+		err = oswrapper.RunScript("pkg/boot/zookeeper/bash/reconfig.bash", reconfigParams, bindata.Asset)
+		if err != nil {
+			log.Printf("command finished with error: %v", err)
+		}
+	}
+}
+
 func getConfdNodes(host, etcdCtlPeers string, port int) []string {
 	result := []string{host + ":" + strconv.Itoa(port)}
 
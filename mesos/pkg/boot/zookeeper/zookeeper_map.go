@@ -1,39 +1,51 @@
 package zookeeper
 
 import (
+	"fmt"
 	"strconv"
+	"time"
 
-	"github.com/deis/deis/mesos/pkg/etcd"
 	"github.com/deis/deis/mesos/pkg/fleet"
-	logger "github.com/deis/deis/mesos/pkg/log"
+	"github.com/deis/deis/pkg/etcd"
+	logger "github.com/deis/deis/pkg/log"
 )
 
 const (
-	etcdLock = "/zookeeper/setupLock"
+	etcdLock        = "/zookeeper/setupLock"
+	etcdFence       = "/zookeeper/setupFence"
+	etcdLockTTL     = 10
+	etcdLockTimeout = 2 * time.Minute
 )
 
-var (
-	log = logger.New()
-)
+var log = logger.New()
 
 // CheckZkMappingInFleet verifies if there is a mapping for each node in
 // the CoreOS cluster using the metadata zookeeper=true to filter wich
 // nodes zookeeper should run
-func CheckZkMappingInFleet(etcdPath string, etcdClient *etcd.Client, etcdURL []string) {
-	// check if the nodes with the required role already have the an id.
-	// If not get fleet nodes with the required role and preassing the
-	// ids for every node in the cluster
-	err := etcd.AcquireLock(etcdClient, etcdLock, 10)
+func CheckZkMappingInFleet(etcdPath string, etcdClient *etcd.Client, etcdURL []string, grace time.Duration) error {
+	_, err := ReconcileMembership(etcdPath, etcdClient, etcdURL, grace, etcdLockTimeout)
+	return err
+}
+
+// ReconcileMembership updates the mapping in etcd so the ensemble matches
+// the machines in fleet with the metadata zookeeper=true (or every machine,
+// if none has it). It returns the plan that was applied.
+func ReconcileMembership(etcdPath string, etcdClient *etcd.Client, etcdURL []string, grace, timeout time.Duration) (*Plan, error) {
+	// the mapping is only changed while the lock is held, so two nodes
+	// booting at the same time never hand out the same id
+	lock, err := etcd.AcquireLock(etcdClient, etcdLock, etcdLockTTL, timeout, nil)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("unable to acquire %s: %v", etcdLock, err)
 	}
+	defer lock.Release()
+	log.Debugf("holding %s with token %d", etcdLock, lock.Token())
 
-	zkNodes := etcd.GetList(etcdClient, etcdPath)
+	zkNodes := getNodes(etcdPath, etcdClient)
 	log.Debugf("zookeeper nodes %v", zkNodes)
 
 	machines, err := getMachines(etcdURL)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	log.Debugf("machines %v", machines)
 
@@ -45,71 +57,89 @@ func CheckZkMappingInFleet(etcdPath string, etcdClient *etcd.Client, etcdURL []s
 		machines = fleet.GetNodesInCluster(etcdURL)
 	}
 
-	if len(zkNodes) == 0 {
-		log.Debug("initializing zookeeper cluster")
-		for index, newZkNode := range machines {
-			log.Debug("adding node %v to zookeeper cluster", newZkNode)
-			etcd.Set(etcdClient, etcdPath+"/"+newZkNode+"/id", strconv.Itoa(index+1), 0)
-		}
-	} else {
-		// we check if some machine in the fleet cluster with the
-		// required role is not initialized (no zookeeper node id).
-		machinesNotInitialized := difference(machines, zkNodes)
-		if len(machinesNotInitialized) > 0 {
-			nextNodeID := getNextNodeID(etcdPath, etcdClient, zkNodes)
-			for _, zkNode := range machinesNotInitialized {
-				etcd.Set(etcdClient, etcdPath+"/"+zkNode+"/id", strconv.Itoa(nextNodeID), 0)
-				nextNodeID++
-			}
-		}
-	}
-
-	// release the etcd lock
-	etcd.ReleaseLock(etcdClient)
-}
+	plan := Reconcile(zkNodes, machines, time.Now(), grace)
 
-// getMachines return the list of machines that can run zookeeper or an empty list
-func getMachines(etcdURL []string) ([]string, error) {
-	metadata, err := fleet.ParseMetadata("zookeeper=true")
+	// every write is refused once a later holder of the lock claims the
+	// fence, so a holder that lost the lock can't overwrite its changes
+	fence, err := lock.Fence(etcdFence)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("unable to claim %s: %v", etcdFence, err)
 	}
 
-	return fleet.GetNodesWithMetadata(etcdURL, metadata)
-}
-
-// getNextNodeID returns the next id to use as zookeeper node index
-func getNextNodeID(etcdPath string, etcdClient *etcd.Client, nodes []string) int {
-	result := 0
-	for _, node := range nodes {
-		id := etcd.Get(etcdClient, etcdPath+"/"+node+"/id")
-		numericID, err := strconv.Atoi(id)
-		if id != "" && err == nil && numericID > result {
-			result = numericID
+	for _, node := range plan.Added {
+		log.Infof("adding node %v to zookeeper cluster with id %d", node.Host, node.ID)
+		if err := fence.Set(etcdPath+"/"+node.Host+"/id", strconv.Itoa(node.ID)); err != nil {
+			return nil, err
+		}
+		if err := fence.Set(etcdPath+"/"+node.Host+"/role", node.Role); err != nil {
+			return nil, err
+		}
+	}
+	for _, node := range plan.Departed {
+		log.Warningf("zookeeper node %v left fleet, removing it after %v", node.Host, grace)
+		if err := fence.Set(etcdPath+"/"+node.Host+"/departed", node.Departed.Format(time.RFC3339)); err != nil {
+			return nil, err
+		}
+	}
+	for _, node := range plan.Returned {
+		log.Infof("zookeeper node %v is back in fleet", node.Host)
+		if err := fence.Delete(etcdPath + "/" + node.Host + "/departed"); err != nil {
+			return nil, err
+		}
+	}
+	for _, node := range plan.Removed {
+		log.Infof("removing departed node %v from zookeeper cluster", node.Host)
+		if err := fence.Delete(etcdPath + "/" + node.Host); err != nil {
+			return nil, err
+		}
+	}
+	for _, node := range plan.Roles {
+		log.Infof("zookeeper node %v is now a %s", node.Host, node.Role)
+		if err := fence.Set(etcdPath+"/"+node.Host+"/role", node.Role); err != nil {
+			return nil, err
 		}
 	}
 
-	return result + 1
+	return plan, nil
 }
 
-// difference get the elements present in the first slice and not in
-// the second one returning those elemenets in a new string slice.
-func difference(slice1 []string, slice2 []string) []string {
-	diffStr := []string{}
-	m := map[string]int{}
+// getNodes returns the zookeeper nodes stored in etcd
+func getNodes(etcdPath string, etcdClient *etcd.Client) []*Node {
+	nodes := []*Node{}
+	for _, host := range etcd.GetList(etcdClient, etcdPath) {
+		id, err := strconv.Atoi(etcd.Get(etcdClient, etcdPath+"/"+host+"/id"))
+		if err != nil {
+			log.Warningf("zookeeper node %v has no valid id, ignoring it", host)
+			continue
+		}
 
-	for _, s1Val := range slice1 {
-		m[s1Val] = 1
-	}
-	for _, s2Val := range slice2 {
-		m[s2Val] = m[s2Val] + 1
+		node := &Node{
+			Host: host,
+			ID:   id,
+			Role: etcd.Get(etcdClient, etcdPath+"/"+host+"/role"),
+		}
+		// nodes added before roles were stored are participants
+		if node.Role == "" {
+			node.Role = RoleParticipant
+		}
+		if departed := etcd.Get(etcdClient, etcdPath+"/"+host+"/departed"); departed != "" {
+			node.Departed, err = time.Parse(time.RFC3339, departed)
+			if err != nil {
+				log.Warningf("zookeeper node %v has an invalid departure time %q", host, departed)
+				node.Departed = time.Now()
+			}
+		}
+		nodes = append(nodes, node)
 	}
+	return nodes
+}
 
-	for mKey, mVal := range m {
-		if mVal == 1 {
-			diffStr = append(diffStr, mKey)
-		}
+// getMachines return the list of machines that can run zookeeper or an empty list
+func getMachines(etcdURL []string) ([]string, error) {
+	metadata, err := fleet.ParseMetadata("zookeeper=true")
+	if err != nil {
+		return nil, err
 	}
 
-	return diffStr
+	return fleet.GetNodesWithMetadata(etcdURL, metadata)
 }
@@ -0,0 +1,158 @@
+package zookeeper
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// RoleParticipant is the role of a node that votes in the ensemble
+	RoleParticipant = "participant"
+	// RoleObserver is the role of a node that follows the ensemble without voting
+	RoleObserver = "observer"
+)
+
+// Node is a member of the zookeeper ensemble, as stored in /zookeeper/nodes
+type Node struct {
+	Host string
+	ID   int
+	Role string
+	// Departed is when the node was first seen missing from fleet, or zero
+	Departed time.Time
+}
+
+// Server returns the node's line in the zookeeper dynamic configuration
+func (n *Node) Server() string {
+	return fmt.Sprintf("server.%d=%s:2181:2888:%s;%s:3888", n.ID, n.Host, n.Role, n.Host)
+}
+
+// Plan describes how the ensemble changes to match the machines in fleet
+type Plan struct {
+	// Added are machines that joined and were given a new id
+	Added []*Node
+	// Departed are nodes that went missing from fleet since the last reconciliation
+	Departed []*Node
+	// Returned are departed nodes that are back in fleet
+	Returned []*Node
+	// Removed are nodes that were missing for longer than the grace period
+	Removed []*Node
+	// Roles are nodes whose role changed
+	Roles []*Node
+	// Members is the ensemble after the plan is applied, ordered by id
+	Members []*Node
+}
+
+// Changed returns whether the ensemble's configuration changed, so zookeeper
+// has to be reconfigured
+func (p *Plan) Changed() bool {
+	return len(p.Added) > 0 || len(p.Removed) > 0 || len(p.Roles) > 0
+}
+
+// Reconfig returns the members of the ensemble in the format expected by
+// zkCli.sh reconfig -members
+func (p *Plan) Reconfig() string {
+	servers := make([]string, len(p.Members))
+	for i, node := range p.Members {
+		servers[i] = node.Server()
+	}
+	return strings.Join(servers, ",")
+}
+
+// Reconcile plans the changes that make the ensemble match the machines
+// currently in fleet. New machines get the next free id. Nodes missing from
+// fleet are marked as departed and removed once they have been gone for
+// longer than grace. If the ensemble would have an even number of voters,
+// one node becomes an observer so the quorum stays odd.
+func Reconcile(nodes []*Node, machines []string, now time.Time, grace time.Duration) *Plan {
+	plan := &Plan{}
+
+	inFleet := map[string]bool{}
+	for _, machine := range machines {
+		inFleet[machine] = true
+	}
+
+	known := map[string]bool{}
+	nextID := 1
+	for _, node := range nodes {
+		known[node.Host] = true
+		if node.ID >= nextID {
+			nextID = node.ID + 1
+		}
+	}
+
+	for _, node := range nodes {
+		member := *node
+		switch {
+		case inFleet[node.Host]:
+			if !node.Departed.IsZero() {
+				member.Departed = time.Time{}
+				plan.Returned = append(plan.Returned, &member)
+			}
+		case len(machines) == 0:
+			// fleet returned no machines at all, which is more likely an
+			// error than every machine leaving, so nothing is departed
+		case node.Departed.IsZero():
+			member.Departed = now
+			plan.Departed = append(plan.Departed, &member)
+		case now.Sub(node.Departed) > grace:
+			plan.Removed = append(plan.Removed, &member)
+			continue
+		}
+		plan.Members = append(plan.Members, &member)
+	}
+
+	sorted := append([]string{}, machines...)
+	sort.Strings(sorted)
+	for _, machine := range sorted {
+		if known[machine] {
+			continue
+		}
+		known[machine] = true
+		member := &Node{Host: machine, ID: nextID}
+		nextID++
+		plan.Added = append(plan.Added, member)
+		plan.Members = append(plan.Members, member)
+	}
+
+	sort.Sort(byID(plan.Members))
+	assignRoles(plan)
+	return plan
+}
+
+// assignRoles makes every member a participant except, when there is an even
+// number of members, the one that is least useful as a voter: a departed
+// node if there is one, or else the newest node
+func assignRoles(plan *Plan) {
+	observer := -1
+	if len(plan.Members) > 1 && len(plan.Members)%2 == 0 {
+		observer = len(plan.Members) - 1
+		for i := len(plan.Members) - 1; i >= 0; i-- {
+			if !plan.Members[i].Departed.IsZero() {
+				observer = i
+				break
+			}
+		}
+	}
+
+	for i, node := range plan.Members {
+		role := RoleParticipant
+		if i == observer {
+			role = RoleObserver
+		}
+		if node.Role != role {
+			isNew := node.Role == ""
+			node.Role = role
+			if !isNew {
+				plan.Roles = append(plan.Roles, node)
+			}
+		}
+	}
+}
+
+type byID []*Node
+
+func (n byID) Len() int           { return len(n) }
+func (n byID) Swap(i, j int)      { n[i], n[j] = n[j], n[i] }
+func (n byID) Less(i, j int) bool { return n[i].ID < n[j].ID }
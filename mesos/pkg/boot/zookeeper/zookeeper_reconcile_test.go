@@ -0,0 +1,110 @@
+package zookeeper
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func hosts(nodes []*Node) []string {
+	result := []string{}
+	for _, node := range nodes {
+		result = append(result, node.Host)
+	}
+	return result
+}
+
+func TestReconcileInitializesCluster(t *testing.T) {
+	plan := Reconcile(nil, []string{"10.0.0.3", "10.0.0.1", "10.0.0.2"}, time.Now(), time.Minute)
+
+	expected := "server.1=10.0.0.1:2181:2888:participant;10.0.0.1:3888," +
+		"server.2=10.0.0.2:2181:2888:participant;10.0.0.2:3888," +
+		"server.3=10.0.0.3:2181:2888:participant;10.0.0.3:3888"
+	if plan.Reconfig() != expected {
+		t.Errorf("Expected '%v' but returned '%v'", expected, plan.Reconfig())
+	}
+	if len(plan.Added) != 3 || !plan.Changed() {
+		t.Errorf("Expected 3 added nodes but returned %v", hosts(plan.Added))
+	}
+}
+
+func TestReconcileKeepsOddQuorum(t *testing.T) {
+	nodes := []*Node{
+		{Host: "10.0.0.1", ID: 1, Role: RoleParticipant},
+		{Host: "10.0.0.2", ID: 2, Role: RoleParticipant},
+		{Host: "10.0.0.3", ID: 3, Role: RoleParticipant},
+	}
+	plan := Reconcile(nodes, []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"}, time.Now(), time.Minute)
+
+	if !reflect.DeepEqual(hosts(plan.Added), []string{"10.0.0.4"}) {
+		t.Fatalf("Expected '%v' but returned '%v'", []string{"10.0.0.4"}, hosts(plan.Added))
+	}
+	if plan.Added[0].ID != 4 || plan.Added[0].Role != RoleObserver {
+		t.Errorf("Expected the new node to be observer 4 but returned %v %v", plan.Added[0].Role, plan.Added[0].ID)
+	}
+	if len(plan.Roles) != 0 {
+		t.Errorf("Expected no role changes but returned %v", hosts(plan.Roles))
+	}
+}
+
+func TestReconcileRemovesDepartedNodes(t *testing.T) {
+	now := time.Now()
+	nodes := []*Node{
+		{Host: "10.0.0.1", ID: 1, Role: RoleParticipant},
+		{Host: "10.0.0.2", ID: 2, Role: RoleParticipant},
+		{Host: "10.0.0.3", ID: 3, Role: RoleParticipant},
+	}
+
+	// 10.0.0.1 leaves, but stays a member during the grace period
+	plan := Reconcile(nodes, []string{"10.0.0.2", "10.0.0.3"}, now, time.Minute)
+	if !reflect.DeepEqual(hosts(plan.Departed), []string{"10.0.0.1"}) {
+		t.Fatalf("Expected '%v' but returned '%v'", []string{"10.0.0.1"}, hosts(plan.Departed))
+	}
+	if len(plan.Removed) != 0 || len(plan.Members) != 3 {
+		t.Errorf("Expected the departed node to be kept during the grace period")
+	}
+	if plan.Changed() {
+		t.Errorf("Expected the ensemble not to change")
+	}
+
+	// once the grace period passes it is removed, and a node is demoted so
+	// there is an odd number of voters
+	plan = Reconcile(plan.Members, []string{"10.0.0.2", "10.0.0.3"}, now.Add(2*time.Minute), time.Minute)
+	if !reflect.DeepEqual(hosts(plan.Removed), []string{"10.0.0.1"}) {
+		t.Fatalf("Expected '%v' but returned '%v'", []string{"10.0.0.1"}, hosts(plan.Removed))
+	}
+	if !reflect.DeepEqual(hosts(plan.Roles), []string{"10.0.0.3"}) {
+		t.Errorf("Expected '%v' but returned '%v'", []string{"10.0.0.3"}, hosts(plan.Roles))
+	}
+	expected := "server.2=10.0.0.2:2181:2888:participant;10.0.0.2:3888," +
+		"server.3=10.0.0.3:2181:2888:observer;10.0.0.3:3888"
+	if plan.Reconfig() != expected {
+		t.Errorf("Expected '%v' but returned '%v'", expected, plan.Reconfig())
+	}
+}
+
+func TestReconcileReturnedNode(t *testing.T) {
+	now := time.Now()
+	nodes := []*Node{
+		{Host: "10.0.0.1", ID: 1, Role: RoleParticipant, Departed: now.Add(-30 * time.Second)},
+		{Host: "10.0.0.2", ID: 2, Role: RoleParticipant},
+		{Host: "10.0.0.3", ID: 3, Role: RoleParticipant},
+	}
+
+	plan := Reconcile(nodes, []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}, now, time.Minute)
+	if !reflect.DeepEqual(hosts(plan.Returned), []string{"10.0.0.1"}) {
+		t.Fatalf("Expected '%v' but returned '%v'", []string{"10.0.0.1"}, hosts(plan.Returned))
+	}
+	if plan.Changed() {
+		t.Errorf("Expected the ensemble not to change")
+	}
+}
+
+func TestReconcileIgnoresEmptyFleet(t *testing.T) {
+	nodes := []*Node{{Host: "10.0.0.1", ID: 1, Role: RoleParticipant}}
+
+	plan := Reconcile(nodes, []string{}, time.Now(), time.Minute)
+	if len(plan.Departed) != 0 || plan.Changed() {
+		t.Errorf("Expected no changes when fleet returns no machines")
+	}
+}
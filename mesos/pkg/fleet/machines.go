@@ -6,7 +6,7 @@ import (
 
 	"github.com/coreos/fleet/etcd"
 	"github.com/coreos/fleet/registry"
-	logger "github.com/deis/deis/mesos/pkg/log"
+	logger "github.com/deis/deis/pkg/log"
 )
 
 var log = logger.New()
@@ -0,0 +1,376 @@
+//go:generate go-extpoints
+
+// Package boot runs the lifecycle shared by the platform components: it
+// creates their etcd defaults, runs confd, launches their daemons, waits for
+// their ports, publishes them to etcd and runs their scheduled tasks.
+//
+// A component implements extpoints.BootComponent, registers it with
+// RegisterComponent and calls Start.
+//
+// The component's health checks are served on /healthz and /readyz on
+// $HEALTH_PORT (8099 by default). /readyz passes once the component has
+// booted and published itself to etcd.
+//
+// On SIGTERM the component's etcd keys are deleted right away, then it has
+// $SHUTDOWN_GRACE_PERIOD seconds (5 by default) to finish its in-flight work
+// before its daemons are killed.
+package boot
+
+import (
+	"net/http"
+	_ "net/http/pprof" //pprof is used for profiling servers
+	"os"
+	"os/signal"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/deis/deis/pkg/boot/extpoints"
+	"github.com/deis/deis/pkg/boot/types"
+	"github.com/deis/deis/pkg/confd"
+	"github.com/deis/deis/pkg/etcd"
+	"github.com/deis/deis/pkg/health"
+	logger "github.com/deis/deis/pkg/log"
+	"github.com/deis/deis/pkg/net"
+	oswrapper "github.com/deis/deis/pkg/os"
+	"github.com/deis/deis/version"
+	"github.com/robfig/cron"
+)
+
+const (
+	timeout time.Duration = 10 * time.Second
+	ttl     time.Duration = timeout * 2
+	// readyTimeout is how long a component's health checks have to pass
+	// before it is published
+	readyTimeout time.Duration = 2 * time.Minute
+)
+
+var (
+	signalChan  = make(chan os.Signal, 1)
+	log         = logger.New()
+	bootProcess = extpoints.BootComponents
+	component   extpoints.BootComponent
+	healthz     = health.NewServer()
+
+	// stopPublishing is closed to unpublish the component from etcd
+	stopPublishing = make(chan struct{})
+	publishers     sync.WaitGroup
+
+	daemons      []*oswrapper.Daemon
+	daemonsMutex sync.Mutex
+)
+
+func init() {
+	runtime.GOMAXPROCS(runtime.NumCPU())
+}
+
+// RegisterComponent register an externsion to be used with this application
+func RegisterComponent(component extpoints.BootComponent, name string) bool {
+	return bootProcess.Register(component, name)
+}
+
+// Start initiate the boot process of the current component
+// etcdPath is the base path used to publish the component in etcd
+// externalPort is the port the component is published with in etcd
+func Start(etcdPath string, externalPort int) {
+	log.Infof("boot version [%v]", version.Version)
+
+	go func() {
+		log.Debugf("starting pprof http server in port 6060")
+		http.ListenAndServe("localhost:6060", nil)
+	}()
+
+	go func() {
+		healthPort := oswrapper.Getopt("HEALTH_PORT", health.DefaultPort)
+		log.Debugf("starting health http server in port %v", healthPort)
+		if err := healthz.ListenAndServe(":" + healthPort); err != nil {
+			log.Errorf("error serving health checks: %v", err)
+		}
+	}()
+
+	signal.Notify(signalChan,
+		syscall.SIGHUP,
+		syscall.SIGINT,
+		syscall.SIGKILL,
+		syscall.SIGTERM,
+		syscall.SIGQUIT,
+		os.Interrupt,
+	)
+
+	// Wait for a signal and exit
+	exitChan := make(chan int)
+	go func() {
+		for {
+			s := <-signalChan
+			log.Debugf("Signal received: %v", s)
+			switch s {
+			case syscall.SIGTERM:
+				exitChan <- 0
+			case syscall.SIGQUIT:
+				exitChan <- 0
+			case syscall.SIGKILL:
+				exitChan <- 1
+			default:
+				exitChan <- 1
+			}
+		}
+	}()
+
+	component = bootProcess.Lookup("boot")
+	if component == nil {
+		log.Error("error loading boot extension...")
+		signalChan <- syscall.SIGINT
+	}
+
+	host := oswrapper.Getopt("HOST", "127.0.0.1")
+	etcdPort, _ := strconv.Atoi(oswrapper.Getopt("ETCD_PORT", "4001"))
+	gracePeriod, _ := strconv.Atoi(oswrapper.Getopt("SHUTDOWN_GRACE_PERIOD", "5"))
+	etcdPeers := oswrapper.Getopt("ETCD_PEERS", "127.0.0.1:"+strconv.Itoa(etcdPort))
+	etcdClient := etcd.NewClient(etcd.GetHTTPEtcdUrls(host+":"+strconv.Itoa(etcdPort), etcdPeers))
+
+	etcdURL := etcd.GetHTTPEtcdUrls(host+":"+strconv.Itoa(etcdPort), etcdPeers)
+
+	currentBoot := &types.CurrentBoot{
+		ConfdDir:    oswrapper.Getopt("CONFD_DIR", "/app"),
+		ConfdNodes:  getConfdNodes(host+":"+strconv.Itoa(etcdPort), etcdPeers),
+		EtcdClient:  etcdClient,
+		EtcdPath:    etcdPath,
+		EtcdPort:    etcdPort,
+		EtcdPeers:   etcdPeers,
+		EtcdURL:     etcdURL,
+		GracePeriod: time.Duration(gracePeriod) * time.Second,
+		Host:        net.ParseIP(host),
+		Timeout:     timeout,
+		TTL:         timeout * 2,
+		Port:        externalPort,
+	}
+
+	// do the real work in a goroutine to be able to exit if
+	// a signal is received during the boot process
+	go start(currentBoot)
+
+	code := <-exitChan
+
+	if component != nil {
+		shutdown(currentBoot)
+
+		// pre shutdown tasks
+		log.Debugf("executing pre shutdown scripts")
+		preShutdownScripts := component.PreShutdownScripts(currentBoot)
+		runAllScripts(signalChan, preShutdownScripts)
+	}
+
+	log.Debugf("execution terminated with exit code %v", code)
+	os.Exit(code)
+}
+
+// Fail shuts the component down with a non-zero exit code after a fatal
+// error in one of its own goroutines, unpublishing it and running its
+// PreShutdown like any other stop signal would.
+func Fail(err error) {
+	log.Errorf("fatal error: %v", err)
+	signalChan <- syscall.SIGINT
+}
+
+func start(currentBoot *types.CurrentBoot) {
+	log.Info("starting component...")
+
+	log.Debug("creating required etcd directories")
+	for _, key := range component.MkdirsEtcd() {
+		etcd.Mkdir(currentBoot.EtcdClient, key)
+	}
+
+	log.Debug("setting default etcd values")
+	for key, value := range component.EtcdDefaults() {
+		etcd.SetDefault(currentBoot.EtcdClient, key, value)
+	}
+
+	// preboot code runs before confd, so it can start anything confd's
+	// reload commands expect to be running
+	log.Debug("running preboot code")
+	component.PreBoot(currentBoot)
+
+	initial, daemon := component.UseConfd()
+	if initial {
+		// wait for confd to run once and install initial templates
+		log.Debug("waiting for initial confd configuration")
+		confd.WaitForInitialConf(currentBoot.ConfdNodes, currentBoot.ConfdDir, currentBoot.Timeout)
+	}
+
+	log.Debug("running pre boot scripts")
+	preBootScripts := component.PreBootScripts(currentBoot)
+	runAllScripts(signalChan, preBootScripts)
+
+	if daemon {
+		// spawn confd in the background to update services based on etcd changes
+		log.Debug("launching confd")
+		go confd.Launch(signalChan, currentBoot.ConfdNodes, currentBoot.ConfdDir)
+	}
+
+	log.Debug("running boot daemons")
+	servicesToStart := component.BootDaemons(currentBoot)
+	daemonsMutex.Lock()
+	for _, daemon := range servicesToStart {
+		daemons = append(daemons, oswrapper.StartDaemon(signalChan, daemon.Command, daemon.Args))
+	}
+	daemonsMutex.Unlock()
+
+	for _, check := range component.HealthChecks(currentBoot) {
+		healthz.AddCheck(check.Name, check.Check)
+	}
+
+	// if the returned ips contains the value contained in $HOST it means
+	// that we are running docker with --net=host
+	ipToListen := "0.0.0.0"
+	netIfaces := net.GetNetworkInterfaces()
+	for _, iface := range netIfaces {
+		if strings.Index(iface.IP, currentBoot.Host.String()) > -1 {
+			ipToListen = currentBoot.Host.String()
+			break
+		}
+	}
+
+	portsToWaitFor := component.WaitForPorts()
+	log.Debugf("waiting for a service in the port %v in ip %v", portsToWaitFor, ipToListen)
+	for _, portToWait := range portsToWaitFor {
+		if portToWait > 0 {
+			err := net.WaitForPort("tcp", ipToListen, portToWait, timeout)
+			if err != nil {
+				log.Errorf("error waiting for port %v using ip %v: %v", portToWait, ipToListen, err)
+				signalChan <- syscall.SIGINT
+			}
+		}
+	}
+
+	log.Debug("waiting for the health checks to pass")
+	if err := waitForHealthy(readyTimeout); err != nil {
+		log.Errorf("component is not healthy after %v:\n%v", readyTimeout, err)
+		signalChan <- syscall.SIGINT
+		return
+	}
+
+	// we only publish the service in etcd if the component has keys to publish
+	publishKeys := component.PublishKeys(currentBoot)
+	if len(publishKeys) > 0 {
+		log.Debug("starting periodic publication in etcd...")
+		log.Debugf("etcd publication keys %v", publishKeys)
+		publishers.Add(1)
+		go func() {
+			defer publishers.Done()
+			etcd.PublishService(currentBoot.EtcdClient, publishKeys, uint64(ttl.Seconds()), timeout, stopPublishing)
+		}()
+
+		// Wait for the first publication
+		time.Sleep(timeout / 2)
+	}
+
+	log.Debug("running post boot scripts")
+	postBootScripts := component.PostBootScripts(currentBoot)
+	runAllScripts(signalChan, postBootScripts)
+
+	log.Debug("checking for cron tasks...")
+	crons := component.ScheduleTasks(currentBoot)
+	_cron := cron.New()
+	for _, cronTask := range crons {
+		_cron.AddFunc(cronTask.Frequency, cronTask.Code)
+	}
+	_cron.Start()
+
+	component.PostBoot(currentBoot)
+
+	healthz.SetReady(true)
+}
+
+// waitForHealthy waits until the component's health checks pass
+func waitForHealthy(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := healthz.Healthy()
+		if err == nil || time.Now().After(deadline) {
+			return err
+		}
+		log.Debugf("waiting for health checks: %v", err)
+		time.Sleep(time.Second)
+	}
+}
+
+// shutdown unpublishes the component from etcd, so that nothing new is sent
+// its way, then gives it and its daemons until the end of the grace period
+// to finish their in-flight work before the daemons are killed
+func shutdown(currentBoot *types.CurrentBoot) {
+	deadline := time.Now().Add(currentBoot.GracePeriod)
+	healthz.SetReady(false)
+
+	log.Debug("unpublishing the component from etcd")
+	close(stopPublishing)
+	if !runUntil(publishers.Wait, time.Now().Add(currentBoot.Timeout)) {
+		log.Warn("timed out unpublishing the component from etcd")
+	}
+
+	log.Debug("running pre shutdown code")
+	if !runUntil(func() { component.PreShutdown(currentBoot) }, deadline) {
+		log.Warnf("pre shutdown code did not finish within %v", currentBoot.GracePeriod)
+	}
+
+	log.Debug("stopping boot daemons")
+	daemonsMutex.Lock()
+	defer daemonsMutex.Unlock()
+	var wg sync.WaitGroup
+	for _, daemon := range daemons {
+		wg.Add(1)
+		go func(daemon *oswrapper.Daemon) {
+			defer wg.Done()
+			daemon.Stop(deadline.Sub(time.Now()))
+		}(daemon)
+	}
+	wg.Wait()
+}
+
+// runUntil runs fn and returns false if it did not return before the deadline
+func runUntil(fn func(), deadline time.Time) bool {
+	done := make(chan struct{})
+	go func() {
+		fn()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(deadline.Sub(time.Now())):
+		return false
+	}
+}
+
+func getConfdNodes(host, etcdCtlPeers string) []string {
+	if etcdCtlPeers != "127.0.0.1:4001" {
+		hosts := strings.Split(etcdCtlPeers, ",")
+		result := []string{}
+		for _, _host := range hosts {
+			result = append(result, _host)
+		}
+		return result
+	}
+
+	return []string{host}
+}
+
+func runAllScripts(signalChan chan os.Signal, scripts []*types.Script) {
+	for _, script := range scripts {
+		if script.Params == nil {
+			script.Params = map[string]string{}
+		}
+		// add HOME variable to avoid warning from ceph commands
+		script.Params["HOME"] = "/tmp"
+		if log.Level.String() == "debug" {
+			script.Params["DEBUG"] = "true"
+		}
+		err := oswrapper.RunScript(script.Name, script.Params, script.Content)
+		if err != nil {
+			log.Errorf("script %v execution finished with error: %v", script.Name, err)
+			signalChan <- syscall.SIGTERM
+		}
+	}
+}
@@ -1,7 +1,7 @@
 package extpoints
 
 import (
-	"github.com/deis/deis/mesos/pkg/types"
+	"github.com/deis/deis/pkg/boot/types"
 )
 
 // BootComponent interface that defines the steps
@@ -28,6 +28,12 @@ type BootComponent interface {
 	// WaitForPorts ports that must be open to indicate that the component is running
 	WaitForPorts() []int
 
+	// HealthChecks checks that must pass for the component to be healthy and ready
+	HealthChecks(currentBoot *types.CurrentBoot) []*types.HealthCheck
+
+	// PublishKeys etcd keys and values that announce the component while it is running
+	PublishKeys(currentBoot *types.CurrentBoot) map[string]string
+
 	// PostBootScripts scripts to execute after the component starts
 	PostBootScripts(currentBoot *types.CurrentBoot) []*types.Script
 
@@ -37,6 +43,10 @@ type BootComponent interface {
 	// ScheduleTasks tasks that must run during the lifecycle of the component
 	ScheduleTasks(currentBoot *types.CurrentBoot) []*types.Cron
 
+	// PreShutdown custom pre-shutdown task (custom go code), run once the
+	// component is unpublished to let it finish its in-flight work
+	PreShutdown(currentBoot *types.CurrentBoot)
+
 	// PreShutdownScripts scripts to execute before the component execution ends
 	PreShutdownScripts(currentBoot *types.CurrentBoot) []*types.Script
 }
@@ -0,0 +1,35 @@
+package types
+
+import (
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/deis/deis/pkg/etcd"
+)
+
+// CurrentBoot information about the boot
+// process related to the component
+type CurrentBoot struct {
+	ConfdDir    string
+	ConfdNodes  []string
+	EtcdClient  *etcd.Client
+	EtcdPath    string
+	EtcdPort    int
+	EtcdPeers   string
+	EtcdURL     []string
+	GracePeriod time.Duration
+	Host        net.IP
+	Port        int
+	Timeout     time.Duration
+	TTL         time.Duration
+}
+
+// HostPortKeys returns the keys that publish the component's host and
+// port under the given etcd path, as $path/host and $path/port
+func (currentBoot *CurrentBoot) HostPortKeys(path string) map[string]string {
+	return map[string]string{
+		path + "/host": currentBoot.Host.String(),
+		path + "/port": strconv.Itoa(currentBoot.Port),
+	}
+}
@@ -0,0 +1,7 @@
+package types
+
+// HealthCheck struct to check that part of a component is working
+type HealthCheck struct {
+	Name  string
+	Check func() error
+}
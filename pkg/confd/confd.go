@@ -11,8 +11,8 @@ import (
 	"syscall"
 	"time"
 
-	logger "github.com/deis/deis/mesos/pkg/log"
-	oswrapper "github.com/deis/deis/mesos/pkg/os"
+	logger "github.com/deis/deis/pkg/log"
+	oswrapper "github.com/deis/deis/pkg/os"
 )
 
 const (
@@ -26,11 +26,11 @@ var (
 	templateErrorRegex = "(\\d{4})-(\\d{2})-(\\d{2})T(\\d{2}):(\\d{2}):\\d{2}Z.*ERROR template:"
 )
 
-// WaitForInitialConf wait until the compilation of the templates is correct
-func WaitForInitialConf(etcd []string, timeout time.Duration) {
+// WaitForInitialConf wait until the compilation of the templates in confdDir is correct
+func WaitForInitialConf(etcd []string, confdDir string, timeout time.Duration) {
 	log.Info("waiting for confd to write initial templates...")
 	for {
-		cmdAsString := fmt.Sprintf("confd -onetime -node %v -confdir /app", strings.Join(etcd, ","))
+		cmdAsString := fmt.Sprintf("confd -onetime -node %v -confdir %v", strings.Join(etcd, ","), confdDir)
 		log.Debugf("running %s", cmdAsString)
 		cmd, args := oswrapper.BuildCommandFromString(cmdAsString)
 		err := oswrapper.RunCommand(cmd, args)
@@ -42,13 +42,13 @@ func WaitForInitialConf(etcd []string, timeout time.Duration) {
 	}
 }
 
-// Launch launch confd as a daemon process.
-func Launch(signalChan chan os.Signal, etcd []string) {
+// Launch launch confd as a daemon process using the templates in confdDir.
+func Launch(signalChan chan os.Signal, etcd []string, confdDir string) {
 	confdLogLevel := "error"
 	if log.Level.String() == "debug" {
 		confdLogLevel = "debug"
 	}
-	cmdAsString := fmt.Sprintf("confd -node %v -confdir /app --interval %v --log-level %v", confdInterval, strings.Join(etcd, ","), confdLogLevel)
+	cmdAsString := fmt.Sprintf("confd -node %v -confdir %v --interval %v --log-level %v", strings.Join(etcd, ","), confdDir, confdInterval, confdLogLevel)
 	cmd, args := oswrapper.BuildCommandFromString(cmdAsString)
 	go runConfdDaemon(signalChan, cmd, args)
 }
@@ -3,19 +3,16 @@ package etcd
 import (
 	"errors"
 	"path"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/coreos/go-etcd/etcd"
-	logger "github.com/deis/deis/mesos/pkg/log"
-	etcdlock "github.com/leeor/etcd-sync"
+	logger "github.com/deis/deis/pkg/log"
 )
 
 // Client etcd client
 type Client struct {
 	client *etcd.Client
-	lock   *etcdlock.EtcdMutex
 }
 
 // Error etcd error
@@ -31,7 +28,7 @@ var log = logger.New()
 // NewClient create a etcd client using the given machine list
 func NewClient(machines []string) *Client {
 	log.Debugf("connecting to %v etcd server/s", machines)
-	return &Client{etcd.NewClient(machines), nil}
+	return &Client{etcd.NewClient(machines)}
 }
 
 // SetDefault sets the value of a key without expiration
@@ -122,19 +119,50 @@ func Create(c *Client, key, value string, ttl uint64) {
 	}
 }
 
-// PublishService publish a service to etcd periodically
+// Delete deletes a key, or a directory and everything inside it
+func Delete(c *Client, key string) {
+	log.Debugf("delete %s", key)
+	_, err := c.client.Delete(key, true)
+	if err != nil {
+		log.Debugf("%v", err)
+	}
+}
+
+// PublishService publish a service to etcd periodically, setting each
+// of the keys to its value with the given ttl, until stop is closed.
+// The keys are then deleted, so the service stops being advertised
+// immediately instead of when the ttl expires
 func PublishService(
 	client *Client,
-	etcdPath string,
-	host string,
-	externalPort int,
+	keys map[string]string,
 	ttl uint64,
-	timeout time.Duration) {
+	timeout time.Duration,
+	stop <-chan struct{}) {
 
+	ticker := time.NewTicker(timeout)
+	defer ticker.Stop()
 	for {
-		Set(client, etcdPath+"/host", host, ttl)
-		Set(client, etcdPath+"/port", strconv.Itoa(externalPort), ttl)
-		time.Sleep(timeout)
+		for key, value := range keys {
+			Set(client, key, value, ttl)
+		}
+		select {
+		case <-stop:
+			for key, value := range keys {
+				CompareAndDelete(client, key, value)
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CompareAndDelete deletes a key only if it still has the given value, so
+// a value written by someone else is left alone
+func CompareAndDelete(c *Client, key, value string) {
+	log.Debugf("delete %s if %s", key, value)
+	_, err := c.client.CompareAndDelete(key, value, 0)
+	if err != nil {
+		log.Debugf("%v", err)
 	}
 }
 
@@ -108,3 +108,34 @@ func TestWaitForKeysEtcd(t *testing.T) {
 		t.Fatalf("Expected an error")
 	}
 }
+
+func TestPublishServiceUnpublishes(t *testing.T) {
+	startEtcd()
+	defer stopEtcd()
+
+	etcdClient := NewClient([]string{"http://localhost:4001"})
+	keys := map[string]string{"/service/host": "10.0.0.1", "/service/port": "80"}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		PublishService(etcdClient, keys, 20, time.Second, stop)
+		close(done)
+	}()
+
+	time.Sleep(500 * time.Millisecond)
+	if value := Get(etcdClient, "/service/host"); value != "10.0.0.1" {
+		t.Fatalf("Expected '%v' but returned '%v'", "10.0.0.1", value)
+	}
+
+	// another instance has taken over the port key
+	Set(etcdClient, "/service/port", "8080", 20)
+	close(stop)
+	<-done
+
+	if value := Get(etcdClient, "/service/host"); value != "" {
+		t.Fatalf("Expected the host to be unpublished but returned '%v'", value)
+	}
+	if value := Get(etcdClient, "/service/port"); value != "8080" {
+		t.Fatalf("Expected '%v' but returned '%v'", "8080", value)
+	}
+}
@@ -0,0 +1,317 @@
+package etcd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-etcd/etcd"
+)
+
+const (
+	// etcd error codes used to tell a held lock from a failed request
+	errorKeyNotFound  = 100
+	errorTestFailed   = 101
+	errorNodeExist    = 105
+	errorWatchCleared = 401
+)
+
+var (
+	// ErrLockTimeout is returned when a lock could not be acquired in time
+	ErrLockTimeout = errors.New("timed out waiting for the lock")
+	// ErrLockCanceled is returned when acquiring a lock was canceled
+	ErrLockCanceled = errors.New("acquiring the lock was canceled")
+	// ErrLockLost is returned by fenced writes once the lock is lost
+	ErrLockLost = errors.New("the lock was lost")
+	// ErrFenced is returned by fenced writes once a later holder of the lock
+	// has claimed the fence
+	ErrFenced = errors.New("the fence was claimed by a later holder of the lock")
+
+	lockSequence uint64
+	lockMutex    sync.Mutex
+)
+
+// Lock is a lock held in etcd. The lock key expires after its ttl unless the
+// holder keeps refreshing it, so a crashed holder can't keep the lock forever.
+type Lock struct {
+	client *Client
+	key    string
+	value  string
+	ttl    uint64
+	token  uint64
+
+	lost     chan struct{}
+	stop     chan struct{}
+	stopped  chan struct{}
+	loseOnce sync.Once
+}
+
+// AcquireLock waits until it holds the lock on key, and keeps refreshing the
+// lock with the given ttl until it is released or lost. It gives up after
+// timeout, or when cancel is closed. A timeout of 0 waits forever
+func AcquireLock(c *Client, key string, ttl uint64, timeout time.Duration, cancel <-chan struct{}) (*Lock, error) {
+	if ttl == 0 {
+		return nil, fmt.Errorf("lock %s needs a ttl", key)
+	}
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	value := lockValue()
+	for {
+		resp, err := c.client.Create(key, value, ttl)
+		if err == nil {
+			log.Debugf("acquired lock %s (token %d)", key, resp.Node.CreatedIndex)
+			lock := &Lock{
+				client:  c,
+				key:     key,
+				value:   value,
+				ttl:     ttl,
+				token:   resp.Node.CreatedIndex,
+				lost:    make(chan struct{}),
+				stop:    make(chan struct{}),
+				stopped: make(chan struct{}),
+			}
+			go lock.refresh()
+			return lock, nil
+		}
+
+		// wait for the lock to be released, or for the request to be retried
+		waitIndex := uint64(0)
+		if etcdErr, ok := err.(*etcd.EtcdError); ok && etcdErr.ErrorCode == errorNodeExist {
+			waitIndex = etcdErr.Index + 1
+		} else {
+			log.Debugf("unable to create lock %s: %v", key, err)
+		}
+
+		changed := make(chan error, 1)
+		stopWatch := make(chan bool)
+		go func() {
+			if waitIndex == 0 {
+				time.Sleep(time.Second)
+				changed <- nil
+				return
+			}
+			_, err := c.client.Watch(key, waitIndex, false, nil, stopWatch)
+			changed <- err
+		}()
+
+		select {
+		case err := <-changed:
+			if etcdErr, ok := err.(*etcd.EtcdError); ok && etcdErr.ErrorCode != errorWatchCleared {
+				log.Debugf("unable to watch lock %s: %v", key, err)
+				time.Sleep(time.Second)
+			}
+		case <-expired:
+			close(stopWatch)
+			return nil, ErrLockTimeout
+		case <-cancel:
+			close(stopWatch)
+			return nil, ErrLockCanceled
+		}
+	}
+}
+
+// Token returns the lock's fencing token. Every time the lock is acquired
+// the token is greater than the last time, so a resource that remembers the
+// greatest token it has seen can reject writes from a holder that lost the lock
+func (l *Lock) Token() uint64 {
+	return l.token
+}
+
+// Lost returns a channel that is closed if the lock is lost before it is
+// released, because it expired or was taken by someone else
+func (l *Lock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Held returns whether the lock is still held
+func (l *Lock) Held() bool {
+	select {
+	case <-l.lost:
+		return false
+	default:
+		return true
+	}
+}
+
+// Release stops refreshing the lock and deletes it, unless it was already lost
+func (l *Lock) Release() error {
+	select {
+	case <-l.stop:
+		return nil
+	default:
+	}
+	close(l.stop)
+	<-l.stopped
+
+	if !l.Held() {
+		return fmt.Errorf("lock %s was lost", l.key)
+	}
+	_, err := l.client.client.CompareAndDelete(l.key, l.value, 0)
+	if err != nil {
+		return fmt.Errorf("unable to release lock %s: %v", l.key, err)
+	}
+	log.Debugf("released lock %s (token %d)", l.key, l.token)
+	return nil
+}
+
+// refresh resets the lock's ttl until it is released. The lock is lost if
+// the key no longer holds this lock, or if it could not be refreshed before
+// the ttl passed
+func (l *Lock) refresh() {
+	defer close(l.stopped)
+
+	ttl := time.Duration(l.ttl) * time.Second
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+	refreshed := time.Now()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+		}
+
+		_, err := l.client.client.CompareAndSwap(l.key, l.value, l.ttl, l.value, 0)
+		if err == nil {
+			refreshed = time.Now()
+			continue
+		}
+
+		if etcdErr, ok := err.(*etcd.EtcdError); ok &&
+			(etcdErr.ErrorCode == errorKeyNotFound || etcdErr.ErrorCode == errorTestFailed) {
+			log.Warningf("lock %s was lost: %v", l.key, err)
+			l.lose()
+			return
+		}
+		log.Debugf("unable to refresh lock %s: %v", l.key, err)
+		if time.Since(refreshed) >= ttl {
+			log.Warningf("lock %s expired before it could be refreshed", l.key)
+			l.lose()
+			return
+		}
+	}
+}
+
+// Fence is a key holding the token of the last holder of a lock to write to
+// the keys the lock guards. Writes made through a Fence are refused as soon as
+// a later holder has claimed it, even if this holder has not noticed yet that
+// it lost the lock
+type Fence struct {
+	lock  *Lock
+	key   string
+	token string
+}
+
+// Fence claims the fence key for the lock. It fails with ErrFenced if a later
+// holder of the lock has already claimed it
+func (l *Lock) Fence(key string) (*Fence, error) {
+	token := strconv.FormatUint(l.token, 10)
+	for {
+		if !l.Held() {
+			return nil, ErrLockLost
+		}
+		resp, err := l.client.client.Get(key, false, false)
+		if etcdErr, ok := err.(*etcd.EtcdError); ok && etcdErr.ErrorCode == errorKeyNotFound {
+			_, err = l.client.client.Create(key, token, 0)
+			if etcdErr, ok := err.(*etcd.EtcdError); ok && etcdErr.ErrorCode == errorNodeExist {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("unable to claim fence %s: %v", key, err)
+			}
+			return &Fence{lock: l, key: key, token: token}, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read fence %s: %v", key, err)
+		}
+
+		last, err := strconv.ParseUint(resp.Node.Value, 10, 64)
+		if err == nil && last > l.token {
+			return nil, ErrFenced
+		}
+		_, err = l.client.client.CompareAndSwap(key, token, 0, resp.Node.Value, 0)
+		if etcdErr, ok := err.(*etcd.EtcdError); ok && etcdErr.ErrorCode == errorTestFailed {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to claim fence %s: %v", key, err)
+		}
+		log.Debugf("claimed fence %s with token %s", key, token)
+		return &Fence{lock: l, key: key, token: token}, nil
+	}
+}
+
+// Set sets the value of a key, if the fence still holds this lock's token
+func (f *Fence) Set(key, value string) error {
+	if err := f.check(); err != nil {
+		return err
+	}
+	log.Debugf("set %s -> %s (token %s)", key, value, f.token)
+	if _, err := f.lock.client.client.Set(key, value, 0); err != nil {
+		return fmt.Errorf("unable to set %s: %v", key, err)
+	}
+	return nil
+}
+
+// Delete deletes a key, or a directory and everything inside it, if the
+// fence still holds this lock's token. Deleting a missing key succeeds
+func (f *Fence) Delete(key string) error {
+	if err := f.check(); err != nil {
+		return err
+	}
+	log.Debugf("delete %s (token %s)", key, f.token)
+	_, err := f.lock.client.client.Delete(key, true)
+	if etcdErr, ok := err.(*etcd.EtcdError); ok && etcdErr.ErrorCode == errorKeyNotFound {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unable to delete %s: %v", key, err)
+	}
+	return nil
+}
+
+// check fails once the lock is lost, or once the fence no longer holds this
+// lock's token. The fence is compared and swapped with its own value, so once
+// a later holder has claimed it every write of this holder fails. Only a
+// write already past its check when the fence is claimed can still land
+func (f *Fence) check() error {
+	select {
+	case <-f.lock.Lost():
+		return ErrLockLost
+	default:
+	}
+	_, err := f.lock.client.client.CompareAndSwap(f.key, f.token, 0, f.token, 0)
+	if etcdErr, ok := err.(*etcd.EtcdError); ok &&
+		(etcdErr.ErrorCode == errorTestFailed || etcdErr.ErrorCode == errorKeyNotFound) {
+		return ErrFenced
+	}
+	if err != nil {
+		return fmt.Errorf("unable to check fence %s: %v", f.key, err)
+	}
+	return nil
+}
+
+func (l *Lock) lose() {
+	l.loseOnce.Do(func() {
+		close(l.lost)
+	})
+}
+
+// lockValue returns a value that identifies a single acquisition of a lock
+func lockValue() string {
+	lockMutex.Lock()
+	defer lockMutex.Unlock()
+	lockSequence++
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d-%d-%d", hostname, os.Getpid(), time.Now().UnixNano(), lockSequence)
+}
@@ -0,0 +1,175 @@
+package etcd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAcquireReleaseLock(t *testing.T) {
+	startEtcd()
+	defer stopEtcd()
+
+	etcdClient := NewClient([]string{"http://localhost:4001"})
+
+	lock, err := AcquireLock(etcdClient, "/lock", 10, 0, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error '%v'", err)
+	}
+
+	value := Get(etcdClient, "/lock")
+	if value == "" {
+		t.Fatalf("Expected the lock key to be set")
+	}
+	if !lock.Held() {
+		t.Fatalf("Expected the lock to be held")
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Unexpected error '%v'", err)
+	}
+
+	value = Get(etcdClient, "/lock")
+	if value != "" {
+		t.Fatalf("Expected the lock key to be deleted but returned '%v'", value)
+	}
+}
+
+func TestAcquireLockTimeout(t *testing.T) {
+	startEtcd()
+	defer stopEtcd()
+
+	etcdClient := NewClient([]string{"http://localhost:4001"})
+
+	lock, err := AcquireLock(etcdClient, "/lock", 10, 0, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error '%v'", err)
+	}
+	defer lock.Release()
+
+	_, err = AcquireLock(etcdClient, "/lock", 10, time.Second, nil)
+	if err != ErrLockTimeout {
+		t.Fatalf("Expected '%v' but returned '%v'", ErrLockTimeout, err)
+	}
+
+	cancel := make(chan struct{})
+	close(cancel)
+	_, err = AcquireLock(etcdClient, "/lock", 10, 0, cancel)
+	if err != ErrLockCanceled {
+		t.Fatalf("Expected '%v' but returned '%v'", ErrLockCanceled, err)
+	}
+}
+
+func TestLockTokenIncreases(t *testing.T) {
+	startEtcd()
+	defer stopEtcd()
+
+	etcdClient := NewClient([]string{"http://localhost:4001"})
+
+	first, err := AcquireLock(etcdClient, "/lock", 10, 0, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error '%v'", err)
+	}
+
+	acquired := make(chan *Lock)
+	go func() {
+		second, err := AcquireLock(etcdClient, "/lock", 10, 10*time.Second, nil)
+		if err != nil {
+			t.Errorf("Unexpected error '%v'", err)
+		}
+		acquired <- second
+	}()
+
+	time.Sleep(500 * time.Millisecond)
+	first.Release()
+
+	second := <-acquired
+	if second == nil {
+		t.FailNow()
+	}
+	defer second.Release()
+	if second.Token() <= first.Token() {
+		t.Fatalf("Expected token %d to be greater than %d", second.Token(), first.Token())
+	}
+}
+
+func TestLockLost(t *testing.T) {
+	startEtcd()
+	defer stopEtcd()
+
+	etcdClient := NewClient([]string{"http://localhost:4001"})
+
+	lock, err := AcquireLock(etcdClient, "/lock", 3, 0, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error '%v'", err)
+	}
+
+	// someone else takes the lock
+	Set(etcdClient, "/lock", "stolen", 0)
+
+	select {
+	case <-lock.Lost():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the lock to be lost")
+	}
+	if lock.Held() {
+		t.Fatalf("Expected the lock not to be held")
+	}
+	if err := lock.Release(); err == nil {
+		t.Fatalf("Expected an error releasing a lost lock")
+	}
+	if value := Get(etcdClient, "/lock"); value != "stolen" {
+		t.Fatalf("Expected '%v' but returned '%v'", "stolen", value)
+	}
+}
+
+func TestLockFence(t *testing.T) {
+	startEtcd()
+	defer stopEtcd()
+
+	etcdClient := NewClient([]string{"http://localhost:4001"})
+
+	first, err := AcquireLock(etcdClient, "/lock", 10, 0, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error '%v'", err)
+	}
+	stale, err := first.Fence("/fence")
+	if err != nil {
+		t.Fatalf("Unexpected error '%v'", err)
+	}
+	if err := stale.Set("/guarded", "first"); err != nil {
+		t.Fatalf("Unexpected error '%v'", err)
+	}
+
+	// the first holder stalls and a second one takes the lock over
+	Delete(etcdClient, "/lock")
+	second, err := AcquireLock(etcdClient, "/lock", 10, 0, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error '%v'", err)
+	}
+	defer second.Release()
+	fence, err := second.Fence("/fence")
+	if err != nil {
+		t.Fatalf("Unexpected error '%v'", err)
+	}
+
+	// the first holder may not have noticed it lost the lock yet, but the
+	// fence refuses its writes either way
+	if err := stale.Set("/guarded", "stale"); err == nil {
+		t.Fatalf("Expected a write through a stale fence to fail")
+	}
+	if err := stale.Delete("/guarded"); err == nil {
+		t.Fatalf("Expected a delete through a stale fence to fail")
+	}
+	if value := Get(etcdClient, "/guarded"); value != "first" {
+		t.Fatalf("Expected '%v' but returned '%v'", "first", value)
+	}
+	if _, err := first.Fence("/fence"); err == nil {
+		t.Fatalf("Expected claiming a fence with an older token to fail")
+	}
+	if err := fence.Set("/guarded", "second"); err != nil {
+		t.Fatalf("Unexpected error '%v'", err)
+	}
+	if value := Get(etcdClient, "/guarded"); value != "second" {
+		t.Fatalf("Expected '%v' but returned '%v'", "second", value)
+	}
+}
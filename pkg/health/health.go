@@ -0,0 +1,163 @@
+// Package health serves the /healthz and /readyz endpoints of platform components.
+//
+// /healthz answers 200 while all of a component's checks pass, and 503 with
+// the failing checks otherwise. /readyz also answers 503 until the component
+// is marked ready, such as when it has booted and published itself to etcd.
+package health
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultPort is the port components serve their health endpoints on.
+const DefaultPort = "8099"
+
+// checkTimeout bounds each network round trip made by the checks in this package.
+const checkTimeout = 2 * time.Second
+
+// Check returns an error if part of a component is not working.
+type Check func() error
+
+// Server serves the results of a component's checks.
+type Server struct {
+	mutex  sync.Mutex
+	checks map[string]Check
+	ready  bool
+}
+
+// NewServer returns a Server with no checks, that is not ready.
+func NewServer() *Server {
+	return &Server{checks: make(map[string]Check)}
+}
+
+// AddCheck adds a check to both /healthz and /readyz.
+func (s *Server) AddCheck(name string, check Check) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.checks[name] = check
+}
+
+// SetReady marks the component as ready, or not, to do its work.
+func (s *Server) SetReady(ready bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.ready = ready
+}
+
+// Healthy runs the checks and returns an error describing the ones that failed.
+func (s *Server) Healthy() error {
+	s.mutex.Lock()
+	names := make([]string, 0, len(s.checks))
+	for name := range s.checks {
+		names = append(names, name)
+	}
+	checks := s.checks
+	s.mutex.Unlock()
+	sort.Strings(names)
+
+	var failed []string
+	for _, name := range names {
+		if err := checks[name](); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%s", strings.Join(failed, "\n"))
+	}
+	return nil
+}
+
+// Ready returns an error if the component is not marked ready or is not healthy.
+func (s *Server) Ready() error {
+	s.mutex.Lock()
+	ready := s.ready
+	s.mutex.Unlock()
+	if !ready {
+		return fmt.Errorf("not ready")
+	}
+	return s.Healthy()
+}
+
+// ServeHTTP answers /healthz and /readyz.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var err error
+	switch r.URL.Path {
+	case "/healthz":
+		err = s.Healthy()
+	case "/readyz":
+		err = s.Ready()
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	if err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, err)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// ListenAndServe serves the health endpoints on addr.
+func (s *Server) ListenAndServe(addr string) error {
+	return http.ListenAndServe(addr, s)
+}
+
+// TCPCheck checks that something accepts connections on addr.
+func TCPCheck(addr string) Check {
+	return func() error {
+		conn, err := net.DialTimeout("tcp", addr, checkTimeout)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+}
+
+// HTTPCheck checks that url answers with a 2xx status.
+func HTTPCheck(url string) Check {
+	client := &http.Client{Timeout: checkTimeout}
+	return func() error {
+		resp, err := client.Get(url)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("%s answered %s", url, resp.Status)
+		}
+		return nil
+	}
+}
+
+// RedisCheck checks that the redis server on addr answers PING.
+func RedisCheck(addr string) Check {
+	return func() error {
+		conn, err := net.DialTimeout("tcp", addr, checkTimeout)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(checkTimeout))
+		if _, err := conn.Write([]byte("PING\r\n")); err != nil {
+			return err
+		}
+		reply, err := bufio.NewReader(conn).ReadString('\n')
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(reply) != "+PONG" {
+			return fmt.Errorf("redis answered %q to PING", strings.TrimSpace(reply))
+		}
+		return nil
+	}
+}
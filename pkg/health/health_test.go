@@ -0,0 +1,110 @@
+package health
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func get(t *testing.T, s *Server, path string) (int, string) {
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest("GET", path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.ServeHTTP(w, r)
+	return w.Code, w.Body.String()
+}
+
+func TestServer(t *testing.T) {
+	s := NewServer()
+	s.AddCheck("nginx", func() error { return nil })
+
+	if code, body := get(t, s, "/healthz"); code != http.StatusOK || body != "ok\n" {
+		t.Errorf("Expected /healthz to answer 200 ok, Got %d %q", code, body)
+	}
+	if code, body := get(t, s, "/readyz"); code != http.StatusServiceUnavailable || body != "not ready\n" {
+		t.Errorf("Expected /readyz to answer 503 until ready, Got %d %q", code, body)
+	}
+
+	s.SetReady(true)
+	if code, _ := get(t, s, "/readyz"); code != http.StatusOK {
+		t.Errorf("Expected /readyz to answer 200 once ready, Got %d", code)
+	}
+
+	s.AddCheck("docker", func() error { return errors.New("connection refused") })
+	for _, path := range []string{"/healthz", "/readyz"} {
+		code, body := get(t, s, path)
+		if code != http.StatusServiceUnavailable || body != "docker: connection refused\n" {
+			t.Errorf("Expected %s to report the failed check, Got %d %q", path, code, body)
+		}
+	}
+
+	if code, _ := get(t, s, "/metrics"); code != http.StatusNotFound {
+		t.Errorf("Expected 404, Got %d", code)
+	}
+}
+
+func TestTCPCheck(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+
+	if err := TCPCheck(addr)(); err != nil {
+		t.Errorf("Expected %s to accept connections, Got %v", addr, err)
+	}
+	l.Close()
+	if err := TCPCheck(addr)(); err == nil {
+		t.Errorf("Expected an error once %s is closed", addr)
+	}
+}
+
+func TestHTTPCheck(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health-check" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	if err := HTTPCheck(ts.URL + "/health-check")(); err != nil {
+		t.Error(err)
+	}
+	if err := HTTPCheck(ts.URL + "/missing")(); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Expected a 404 error, Got %v", err)
+	}
+}
+
+func TestRedisCheck(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	replies := []string{"+PONG\r\n", "-LOADING Redis is loading the dataset in memory\r\n"}
+	go func() {
+		for _, reply := range replies {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			bufio.NewReader(conn).ReadString('\n')
+			conn.Write([]byte(reply))
+			conn.Close()
+		}
+	}()
+
+	check := RedisCheck(l.Addr().String())
+	if err := check(); err != nil {
+		t.Error(err)
+	}
+	if err := check(); err == nil || !strings.Contains(err.Error(), "LOADING") {
+		t.Errorf("Expected redis to be reported as loading, Got %v", err)
+	}
+}
@@ -8,9 +8,11 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"sync"
 	"syscall"
+	"time"
 
-	logger "github.com/deis/deis/mesos/pkg/log"
+	logger "github.com/deis/deis/pkg/log"
 	basher "github.com/progrium/go-basher"
 )
 
@@ -30,21 +32,65 @@ func Getopt(name, dfault string) string {
 	return value
 }
 
-// RunProcessAsDaemon start a child process that will run indefinitely
-func RunProcessAsDaemon(signalChan chan os.Signal, command string, args []string) {
-	cmd := exec.Command(command, args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+// Daemon is a child process started by StartDaemon
+type Daemon struct {
+	cmd      *exec.Cmd
+	done     chan struct{}
+	stopping chan struct{}
+	stopOnce sync.Once
+}
+
+// StartDaemon start a child process that will run indefinitely. If the
+// process cannot start or exits before Stop is called, SIGKILL is sent to
+// signalChan
+func StartDaemon(signalChan chan os.Signal, command string, args []string) *Daemon {
+	d := &Daemon{
+		cmd:      exec.Command(command, args...),
+		done:     make(chan struct{}),
+		stopping: make(chan struct{}),
+	}
+	d.cmd.Stdout = os.Stdout
+	d.cmd.Stderr = os.Stderr
 
-	err := cmd.Start()
+	err := d.cmd.Start()
 	if err != nil {
 		log.Errorf("an error ocurred executing command: [%s params %v], %v", command, args, err)
+		close(d.done)
 		signalChan <- syscall.SIGKILL
+		return d
 	}
 
-	err = cmd.Wait()
-	log.Errorf("command finished with error: %v", err)
-	signalChan <- syscall.SIGKILL
+	go func() {
+		err := d.cmd.Wait()
+		close(d.done)
+		select {
+		case <-d.stopping:
+		default:
+			log.Errorf("command finished with error: %v", err)
+			signalChan <- syscall.SIGKILL
+		}
+	}()
+	return d
+}
+
+// Stop sends SIGTERM to the daemon and waits up to timeout for it to exit,
+// then kills it
+func (d *Daemon) Stop(timeout time.Duration) {
+	select {
+	case <-d.done:
+		return
+	default:
+	}
+
+	d.stopOnce.Do(func() { close(d.stopping) })
+	d.cmd.Process.Signal(syscall.SIGTERM)
+	select {
+	case <-d.done:
+	case <-time.After(timeout):
+		log.Warnf("%s did not exit within %v, killing it", d.cmd.Path, timeout)
+		d.cmd.Process.Kill()
+		<-d.done
+	}
 }
 
 // RunScript run a shell script using go-basher and if it returns an error
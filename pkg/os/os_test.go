@@ -1,7 +1,9 @@
 package os
 
 import (
+	"os"
 	"testing"
+	"time"
 )
 
 func TestGetoptEmpty(t *testing.T) {
@@ -71,3 +73,33 @@ func TestRandomError(t *testing.T) {
 		t.Fatalf("Expected an error requiring a random string of length 0 but %s returned", rnd)
 	}
 }
+
+func TestDaemonStop(t *testing.T) {
+	signalChan := make(chan os.Signal, 1)
+	d := StartDaemon(signalChan, "sleep", []string{"10"})
+
+	start := time.Now()
+	d.Stop(5 * time.Second)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Expected sleep to exit on SIGTERM but it took %v", elapsed)
+	}
+
+	select {
+	case s := <-signalChan:
+		t.Fatalf("Expected no signal when a daemon is stopped but %v was sent", s)
+	default:
+	}
+}
+
+func TestDaemonStopKills(t *testing.T) {
+	signalChan := make(chan os.Signal, 1)
+	d := StartDaemon(signalChan, "sh", []string{"-c", "trap '' TERM; while :; do :; done"})
+	// give the shell time to install its trap
+	time.Sleep(200 * time.Millisecond)
+
+	start := time.Now()
+	d.Stop(100 * time.Millisecond)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Expected the daemon to be killed after the timeout but it took %v", elapsed)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/coreos/go-etcd/etcd"
 	"github.com/fsouza/go-dockerclient"
 
+	"github.com/deis/deis/pkg/health"
 	"github.com/deis/deis/publisher/server"
 )
 
@@ -31,6 +32,7 @@ var (
 	etcdHost        = flag.String("etcd-host", defaultEtcdHost, "The etcd host.")
 	etcdPort        = flag.String("etcd-port", defaultEtcdPort, "The etcd port.")
 	logLevel        = flag.String("log-level", defaultLogLevel, "Acceptable values: error, debug")
+	healthPort      = flag.String("health-port", health.DefaultPort, "The port to serve /healthz and /readyz on.")
 )
 
 func main() {
@@ -50,6 +52,14 @@ func main() {
 		log.Println(http.ListenAndServe("localhost:6060", nil))
 	}()
 
+	healthz := health.NewServer()
+	healthz.AddCheck("docker", dockerClient.Ping)
+	healthz.AddCheck("etcd", health.HTTPCheck("http://"+*etcdHost+":"+*etcdPort+"/version"))
+	healthz.SetReady(true)
+	go func() {
+		log.Println(healthz.ListenAndServe(":" + *healthPort))
+	}()
+
 	for {
 		go server.Poll(*etcdTTL)
 		time.Sleep(*refreshDuration)
@@ -1,69 +1,78 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
 	"net"
 	"os"
 	"os/exec"
-	"os/signal"
-	"strings"
+	"strconv"
 	"syscall"
 	"time"
 
 	"github.com/ActiveState/tail"
-	"github.com/Sirupsen/logrus"
-	"github.com/coreos/go-etcd/etcd"
 
-	"github.com/deis/deis/router/logger"
+	"github.com/deis/deis/pkg/boot"
+	"github.com/deis/deis/pkg/boot/types"
+	"github.com/deis/deis/pkg/etcd"
+	"github.com/deis/deis/pkg/health"
+	logger "github.com/deis/deis/pkg/log"
+	oswrapper "github.com/deis/deis/pkg/os"
 )
 
-var log = logrus.New()
-
 const (
 	timeout        time.Duration = 10 * time.Second
-	ttl            time.Duration = timeout * 2
 	nginxAccessLog string        = "/opt/nginx/logs/access.log"
 	nginxErrorLog  string        = "/opt/nginx/logs/error.log"
+	nginxPidFile   string        = "/run/nginx.pid"
 )
 
-func main() {
-	log.Formatter = new(logger.StdOutFormatter)
-
-	logLevel := getopt("LOG", "info")
-	if level, err := logrus.ParseLevel(logLevel); err == nil {
-		log.Level = level
-	}
-
-	log.Debug("reading environment variables...")
-	host := getopt("HOST", "127.0.0.1")
-
-	etcdPort := getopt("ETCD_PORT", "4001")
+var (
+	etcdPath     = oswrapper.Getopt("ETCD_PATH", "/deis/router")
+	externalPort = oswrapper.Getopt("EXTERNAL_PORT", "80")
+	log          = logger.New()
+)
 
-	etcdPath := getopt("ETCD_PATH", "/deis/router")
+func init() {
+	boot.RegisterComponent(new(RouterBoot), "boot")
+}
 
-	hostEtcdPath := getopt("HOST_ETCD_PATH", "/deis/router/hosts/"+host)
+func main() {
+	port, err := strconv.Atoi(externalPort)
+	if err != nil {
+		log.Fatalf("invalid EXTERNAL_PORT %s: %v", externalPort, err)
+	}
+	boot.Start(etcdPath, port)
+}
 
-	externalPort := getopt("EXTERNAL_PORT", "80")
+// RouterBoot struct to boot the router.
+type RouterBoot struct{}
+
+// MkdirsEtcd returns the etcd directories the router and its templates read.
+func (rb *RouterBoot) MkdirsEtcd() []string {
+	return []string{
+		"/deis/config",
+		"/deis/controller",
+		"/deis/services",
+		"/deis/domains",
+		"/deis/builder",
+		"/deis/certs",
+		"/deis/router/hosts",
+		"/deis/router/hsts",
+		"/registry/services/specs/default",
+	}
+}
 
-	client := etcd.NewClient([]string{"http://" + host + ":" + etcdPort})
+// EtcdDefaults returns default values for etcd.
+func (rb *RouterBoot) EtcdDefaults() map[string]string {
+	return map[string]string{
+		etcdPath + "/gzip": "on",
+	}
+}
 
+// PreBoot starts nginx, so confd can reload it, and cron.
+func (rb *RouterBoot) PreBoot(currentBoot *types.CurrentBoot) {
 	// wait until etcd has discarded potentially stale values
 	time.Sleep(timeout + 1)
 
-	log.Debug("creating required defaults in etcd...")
-	mkdirEtcd(client, "/deis/config")
-	mkdirEtcd(client, "/deis/controller")
-	mkdirEtcd(client, "/deis/services")
-	mkdirEtcd(client, "/deis/domains")
-	mkdirEtcd(client, "/deis/builder")
-	mkdirEtcd(client, "/deis/certs")
-	mkdirEtcd(client, "/deis/router/hosts")
-	mkdirEtcd(client, "/deis/router/hsts")
-	mkdirEtcd(client, "/registry/services/specs/default")
-
-	setDefaultEtcd(client, etcdPath+"/gzip", "on")
-
 	log.Info("Starting Nginx...")
 
 	go tailFile(nginxAccessLog)
@@ -75,19 +84,87 @@ func main() {
 
 	// FIXME: have to launch cron first so generate-certs will generate the files nginx requires
 	go launchCron()
+}
 
-	waitForInitialConfd(host+":"+etcdPort, timeout)
+// PreBootScripts runs preboot scripts.
+func (rb *RouterBoot) PreBootScripts(currentBoot *types.CurrentBoot) []*types.Script {
+	return []*types.Script{}
+}
+
+// UseConfd writes the nginx configuration and keeps it up to date.
+func (rb *RouterBoot) UseConfd() (bool, bool) {
+	return true, true
+}
+
+// BootDaemons returns no daemons, since nginx is started before confd.
+func (rb *RouterBoot) BootDaemons(currentBoot *types.CurrentBoot) []*types.ServiceDaemon {
+	return []*types.ServiceDaemon{}
+}
 
-	go launchConfd(host + ":" + etcdPort)
+// WaitForPorts returns the port nginx listens on.
+func (rb *RouterBoot) WaitForPorts() []int {
+	return []int{80}
+}
 
-	go publishService(client, hostEtcdPath, host, externalPort, uint64(ttl.Seconds()))
+// HealthChecks checks that nginx answers on its health check location.
+func (rb *RouterBoot) HealthChecks(currentBoot *types.CurrentBoot) []*types.HealthCheck {
+	httpCheck := health.HTTPCheck("http://127.0.0.1/health-check")
+	tcpCheck := health.TCPCheck("127.0.0.1:80")
+	return []*types.HealthCheck{
+		&types.HealthCheck{Name: "nginx", Check: func() error {
+			// nginx expects the PROXY protocol header when it is enabled
+			proxyProtocol := etcd.Get(currentBoot.EtcdClient, etcdPath+"/proxyProtocol")
+			if proxyProtocol != "" && proxyProtocol != "false" {
+				return tcpCheck()
+			}
+			return httpCheck()
+		}},
+	}
+}
 
+// PublishKeys publishes the router's host and port under its IP.
+func (rb *RouterBoot) PublishKeys(currentBoot *types.CurrentBoot) map[string]string {
+	host := currentBoot.Host.String()
+	hostEtcdPath := oswrapper.Getopt("HOST_ETCD_PATH", "/deis/router/hosts/"+host)
+	return map[string]string{
+		hostEtcdPath: host + ":" + externalPort,
+	}
+}
+
+// PostBootScripts returns type script.
+func (rb *RouterBoot) PostBootScripts(currentBoot *types.CurrentBoot) []*types.Script {
+	return []*types.Script{}
+}
+
+// PostBoot logs that the router is running.
+func (rb *RouterBoot) PostBoot(currentBoot *types.CurrentBoot) {
 	log.Info("deis-router running...")
+}
+
+// ScheduleTasks returns a cron job.
+func (rb *RouterBoot) ScheduleTasks(currentBoot *types.CurrentBoot) []*types.Cron {
+	return []*types.Cron{}
+}
+
+// PreShutdown stops nginx gracefully, letting it finish the requests in flight.
+func (rb *RouterBoot) PreShutdown(currentBoot *types.CurrentBoot) {
+	log.Info("Stopping Nginx...")
+	if err := exec.Command("/opt/nginx/sbin/nginx", "-s", "quit").Run(); err != nil {
+		log.Warnf("could not stop nginx: %v", err)
+		return
+	}
+	// nginx removes its pid file once its workers have exited
+	for {
+		if _, err := os.Stat(nginxPidFile); os.IsNotExist(err) {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
 
-	exitChan := make(chan os.Signal, 2)
-	signal.Notify(exitChan, syscall.SIGTERM, syscall.SIGINT)
-	<-exitChan
-	tail.Cleanup()
+// PreShutdownScripts returns type script.
+func (rb *RouterBoot) PreShutdownScripts(currentBoot *types.CurrentBoot) []*types.Script {
+	return []*types.Script{}
 }
 
 func launchCron() {
@@ -105,45 +182,13 @@ func launchCron() {
 	}
 }
 
-// Wait until the compilation of the templates
-func waitForInitialConfd(etcd string, timeout time.Duration) {
-	for {
-		var buffer bytes.Buffer
-		output := bufio.NewWriter(&buffer)
-		log.Debugf("Connecting to etcd server %s", etcd)
-		cmd := exec.Command("confd", "-node", etcd, "-onetime", "--log-level", "error")
-		cmd.Stdout = output
-		cmd.Stderr = output
-
-		err := cmd.Run()
-		output.Flush()
-		if err == nil {
-			break
-		}
-
-		log.Info("waiting for confd to write initial templates...")
-		log.Debugf("\n%s", buffer.String())
-		time.Sleep(timeout)
-	}
-}
-
-func launchConfd(etcd string) {
-	cmd := exec.Command("confd", "-node", etcd, "--log-level", "error", "--interval", "5")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	if err := cmd.Start(); err != nil {
-		log.Warn("confd terminated by error: %v", err)
-	}
-}
-
 func launchNginx(nginxChan chan bool) {
 	cmd := exec.Command("/opt/nginx/sbin/nginx", "-c", "/opt/nginx/conf/nginx.conf")
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
 	if err := cmd.Start(); err != nil {
-		log.Warn("Nginx terminated by error: %v", err)
+		log.Warnf("Nginx terminated by error: %v", err)
 	}
 
 	// Wait until the nginx is available
@@ -177,45 +222,3 @@ func tailFile(path string) {
 		log.Info(line.Text)
 	}
 }
-
-func publishService(
-	client *etcd.Client,
-	etcdPath string,
-	host string,
-	externalPort string,
-	ttl uint64) {
-
-	for {
-		setEtcd(client, etcdPath, host+":"+externalPort, ttl)
-		time.Sleep(timeout)
-	}
-}
-
-func setEtcd(client *etcd.Client, key, value string, ttl uint64) {
-	_, err := client.Set(key, value, ttl)
-	if err != nil {
-		log.Warn(err)
-	}
-}
-
-func setDefaultEtcd(client *etcd.Client, key, value string) {
-	_, err := client.Set(key, value, 0)
-	if err != nil {
-		log.Warn(err)
-	}
-}
-
-func mkdirEtcd(client *etcd.Client, path string) {
-	_, err := client.CreateDir(path, 0)
-	if err != nil && !strings.Contains(err.Error(), "Key already exists") {
-		log.Warn(err)
-	}
-}
-
-func getopt(name, dfault string) string {
-	value := os.Getenv(name)
-	if value == "" {
-		value = dfault
-	}
-	return value
-}